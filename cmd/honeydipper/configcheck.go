@@ -11,7 +11,9 @@ package main
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/honeydipper/honeydipper/internal/api"
@@ -33,6 +35,10 @@ var (
 	ErrorNotAllowed = fmt.Errorf("not allowed without pairing field")
 	// ErrorNotAList is the message when a field is supposed to be a list.
 	ErrorNotAList = fmt.Errorf("must be a list or something interpolated into a list")
+	// ErrorNotADuration is the message when a field is supposed to be a duration.
+	ErrorNotADuration = fmt.Errorf("must be a duration or something interpolated into a duration")
+	// ErrorMissingField is the message when a required field is missing.
+	ErrorMissingField = fmt.Errorf("is required")
 )
 
 type dipperCLError struct {
@@ -123,6 +129,10 @@ func runConfigCheck(cfg *config.Config) int {
 		}
 	}
 
+	if checkDriverServices(cfg) > 0 {
+		ret = 1
+	}
+
 	if checkAuthRules(cfg) > 0 {
 		ret = 1
 	}
@@ -284,6 +294,7 @@ func checkWorkflow(w config.Workflow, cfg *config.Config) (location string, msg
 	checkObjectExists("workflow", w.Workflow, cfg.Staged.Workflows)
 	checkWorkflowFunction(w, cfg)
 	checkWorkflowDriver(w, cfg)
+	checkWorkflowLock(w)
 
 	checkIsList("contexts", w.Contexts)
 	checkIsList("iterate", w.Iterate)
@@ -326,11 +337,13 @@ func checkWorkflowFunction(w config.Workflow, cfg *config.Config) {
 		parts := strings.Split(w.CallFunction, ".")
 		checkObjectExists("system", parts[0], cfg.Staged.Systems)
 		checkObjectExists(parts[0]+" function", parts[1], cfg.Staged.Systems[parts[0]].Functions)
+		checkFunctionManifest(config.Function{Target: config.Action{System: parts[0], Function: parts[1]}}, cfg)
 	} else if w.Function.Target.System != "" && !hasInterpolation(w.Function.Target.System) {
 		f := w.Function.Target
 		checkObjectExists("system", f.System, cfg.Staged.Systems)
 		if !hasInterpolation(f.Function) {
 			checkObjectExists(f.System+" function", f.Function, cfg.Staged.Systems[f.System].Functions)
+			checkFunctionManifest(w.Function, cfg)
 		}
 	}
 }
@@ -339,9 +352,111 @@ func checkWorkflowDriver(w config.Workflow, cfg *config.Config) {
 	if w.CallDriver != "" && !hasInterpolation(w.CallDriver) {
 		parts := strings.Split(w.CallDriver, ".")
 		checkObjectExists("driver", parts[0], cfg.Staged.Drivers)
+		if params, ok := w.Local.(map[string]interface{}); len(parts) > 1 && (ok || w.Local == nil) {
+			checkDriverManifest(parts[0], parts[1], params, cfg)
+		}
 	} else if w.Function.Driver != "" && !hasInterpolation(w.Function.Driver) {
 		checkObjectExists("driver", w.Function.Driver, cfg.Staged.Drivers)
+		checkFunctionManifest(w.Function, cfg)
+	}
+}
+
+// checkFunctionManifest collapses the function and validates the raw action and the parameters against the driver manifest.
+func checkFunctionManifest(f config.Function, cfg *config.Config) {
+	chain := []config.Function{f}
+	visited := map[string]bool{}
+	for f.Driver == "" {
+		key := f.Target.System + "." + f.Target.Function
+		if hasInterpolation(key) || visited[key] {
+			return
+		}
+		visited[key] = true
+		child, ok := cfg.Staged.Systems[f.Target.System].Functions[f.Target.Function]
+		if !ok {
+			return
+		}
+		f = child
+		chain = append(chain, f)
+	}
+
+	params := map[string]interface{}{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i].Parameters {
+			params[k] = v
+		}
 	}
+	checkDriverManifest(f.Driver, f.RawAction, params, cfg)
+}
+
+func checkDriverManifest(driverName string, rawAction string, params map[string]interface{}, cfg *config.Config) {
+	if hasInterpolation(driverName) || hasInterpolation(rawAction) || strings.HasPrefix(driverName, "feature:") {
+		return
+	}
+	manifest, err := config.GetDriverManifest(cfg.Staged, driverName)
+	if err != nil {
+		panic(err)
+	}
+	if manifest != nil {
+		if err := manifest.CheckCommand(rawAction, params); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// checkDriverServices makes sure the drivers are only loaded in the services listed in their manifests.
+func checkDriverServices(cfg *config.Config) int {
+	errMsgs := []string{}
+	features, _ := dipper.GetMapData(cfg.Staged.Drivers, "daemon.features")
+	featureServices, _ := features.(map[string]interface{})
+	for service, list := range featureServices {
+		if service == "global" {
+			continue
+		}
+		featureList, _ := list.([]interface{})
+		for _, item := range featureList {
+			name, _ := dipper.GetMapDataStr(item, "name")
+			driverName := strings.TrimPrefix(name, "driver:")
+			if driverName == name {
+				if driverName, _ = dipper.GetMapDataStr(cfg.Staged.Drivers, "daemon.featureMap."+service+"."+name); driverName == "" {
+					driverName, _ = dipper.GetMapDataStr(cfg.Staged.Drivers, "daemon.featureMap.global."+name)
+				}
+			}
+			if driverName == "" {
+				continue
+			}
+			manifest, err := config.GetDriverManifest(cfg.Staged, driverName)
+			switch {
+			case err != nil:
+				errMsgs = append(errMsgs, fmt.Sprintf("driver(%s): %s", driverName, aurora.Red(err.Error())))
+			case manifest != nil && !manifest.SupportsService(service):
+				errMsgs = append(errMsgs, fmt.Sprintf("driver(%s): %s", driverName, aurora.Red(fmt.Sprintf("not supported in service %s", service))))
+			}
+		}
+	}
+
+	if len(errMsgs) > 0 {
+		sort.Strings(errMsgs)
+		fmt.Printf("\nFound errors in driver features:\n")
+		fmt.Println("─────────────────────────────────────────────────────────────")
+		for _, msg := range errMsgs {
+			fmt.Println(msg)
+		}
+	}
+
+	return len(errMsgs)
+}
+
+// make sure the lock is well formed.
+func checkWorkflowLock(w config.Workflow) {
+	if w.Lock == nil {
+		return
+	}
+
+	if strings.TrimSpace(w.Lock.Name) == "" {
+		panic(fmt.Errorf("field \"lock.name\" %w", ErrorMissingField))
+	}
+	checkIsDuration("lock.ttl", w.Lock.TTL)
+	checkIsDuration("lock.wait_timeout", w.Lock.WaitTimeout)
 }
 
 // make sure there aint multiple actions declared.
@@ -396,6 +511,16 @@ func checkIsList(name string, f interface{}) {
 	}
 }
 
+func checkIsDuration(name string, s string) {
+	if s == "" || hasInterpolation(s) {
+		return
+	}
+
+	if _, err := time.ParseDuration(s); err != nil {
+		panic(fmt.Errorf("field \"%s\" %w", name, ErrorNotADuration))
+	}
+}
+
 func checkObjectExists(t, name string, m interface{}) {
 	if name != "" && !hasInterpolation(name) {
 		if !reflect.ValueOf(m).MapIndex(reflect.ValueOf(name)).IsValid() {
@@ -263,6 +263,21 @@ func TestCheckIsListNil(t *testing.T) {
 	checkIsList("test", nil)
 }
 
+func TestCheckWorkflowLockMissingName(t *testing.T) {
+	defer recoverAssertion(`field "lock.name" is required`, t)
+	checkWorkflowLock(config.Workflow{Lock: &config.WorkflowLock{TTL: "1m"}})
+}
+
+func TestCheckWorkflowLockInvalidTTL(t *testing.T) {
+	defer recoverAssertion(`field "lock.ttl" must be a duration or something interpolated into a duration`, t)
+	checkWorkflowLock(config.Workflow{Lock: &config.WorkflowLock{Name: "test", TTL: "forever"}})
+}
+
+func TestCheckWorkflowLock(t *testing.T) {
+	defer recoverAssertion("", t)
+	checkWorkflowLock(config.Workflow{Lock: &config.WorkflowLock{Name: "test-{{ .ctx.env }}", TTL: "$ctx.ttl", WaitTimeout: "30s"}})
+}
+
 var hasLiteralTestCases = []struct {
 	in  string
 	out bool
@@ -321,3 +336,121 @@ func recoverAssertion(out string, t *testing.T) {
 		t.Errorf("Expected: %s, Got: %s instead", out, msg)
 	}
 }
+
+func testManifestConfig() *config.Config {
+	return &config.Config{Staged: &config.DataSet{
+		Drivers: map[string]interface{}{
+			"kubernetes": map[string]interface{}{},
+			"daemon": map[string]interface{}{
+				"drivers": map[string]interface{}{
+					"kubernetes": map[string]interface{}{
+						"name": "kubernetes",
+						"manifest": map[string]interface{}{
+							"services": []interface{}{"operator"},
+							"commands": map[string]interface{}{
+								"createJob": map[string]interface{}{
+									"parameters": []interface{}{
+										map[string]interface{}{"name": "job", "type": "map", "required": true},
+									},
+								},
+							},
+						},
+					},
+				},
+				"features": map[string]interface{}{
+					"operator": []interface{}{map[string]interface{}{"name": "driver:kubernetes"}},
+				},
+			},
+		},
+		Systems: map[string]config.System{
+			"kubernetes": {Functions: map[string]config.Function{
+				"createJob": {Driver: "kubernetes", RawAction: "createJob", Parameters: map[string]interface{}{"job": "$ctx.job"}},
+				"createJb":  {Driver: "kubernetes", RawAction: "createJb"},
+				"noJob":     {Driver: "kubernetes", RawAction: "createJob"},
+			}},
+			"myapp": {Functions: map[string]config.Function{
+				"deploy": {Target: config.Action{System: "kubernetes", Function: "noJob"}, Parameters: map[string]interface{}{"job": map[string]interface{}{}}},
+			}},
+		},
+	}}
+}
+
+func TestCheckWorkflowManifest(t *testing.T) {
+	cfg := testManifestConfig()
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "kubernetes.createJob"}, cfg, "")
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "myapp.deploy"}, cfg, "")
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "kubernetes.createJb"}, cfg,
+		"not matching driver manifest: driver kubernetes has no command createJb")
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "kubernetes.noJob"}, cfg,
+		"not matching driver manifest: kubernetes.createJob requires parameter job")
+
+	testCheckWorkflowDriverHelper(t, config.Workflow{CallDriver: "kubernetes.createJob", Local: map[string]interface{}{"job": "$ctx.job"}}, cfg, "")
+	testCheckWorkflowDriverHelper(t, config.Workflow{CallDriver: "kubernetes.createJob"}, cfg,
+		"not matching driver manifest: kubernetes.createJob requires parameter job")
+	testCheckWorkflowDriverHelper(t, config.Workflow{CallDriver: "kubernetes.createJob", Local: "$ctx.params"}, cfg, "")
+}
+
+func testCheckWorkflowDriverHelper(t *testing.T, wf config.Workflow, cfg *config.Config, out string) {
+	defer recoverAssertion(out, t)
+	checkWorkflowDriver(wf, cfg)
+}
+
+func TestCheckDriverServices(t *testing.T) {
+	cfg := testManifestConfig()
+	assert.Equal(t, 0, checkDriverServices(cfg))
+
+	features := cfg.Staged.Drivers["daemon"].(map[string]interface{})["features"].(map[string]interface{})
+	features["receiver"] = []interface{}{map[string]interface{}{"name": "driver:kubernetes"}}
+	assert.Equal(t, 1, checkDriverServices(cfg), "kubernetes driver should not be loaded in receiver")
+}
+
+func TestCheckWorkflowShippedManifest(t *testing.T) {
+	cfg := &config.Config{Staged: &config.DataSet{
+		Drivers: map[string]interface{}{
+			"kubernetes": map[string]interface{}{},
+			"daemon": map[string]interface{}{
+				"drivers": map[string]interface{}{
+					"kubernetes": map[string]interface{}{
+						"name":        "kubernetes",
+						"type":        "builtin",
+						"handlerData": map[string]interface{}{"shortName": "kubernetes"},
+					},
+				},
+				"features": map[string]interface{}{
+					"engine": []interface{}{map[string]interface{}{"name": "driver:kubernetes"}},
+				},
+			},
+		},
+		Systems: map[string]config.System{
+			"kubernetes": {Functions: map[string]config.Function{
+				"scale": {
+					Driver:     "kubernetes",
+					RawAction:  "scale",
+					Parameters: map[string]interface{}{"source": "$sysData.source", "kind": "Deployment", "name": "$ctx.name", "replicas": "3"},
+				},
+				"scaleTypo":     {Driver: "kubernetes", RawAction: "scal"},
+				"scaleNoSource": {Driver: "kubernetes", RawAction: "scale", Parameters: map[string]interface{}{"kind": "Deployment", "name": "web", "replicas": 3}},
+				"scaleBadCount": {
+					Driver:     "kubernetes",
+					RawAction:  "scale",
+					Parameters: map[string]interface{}{"source": "$sysData.source", "kind": "Deployment", "name": "web", "replicas": "three"},
+				},
+			}},
+		},
+	}}
+
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "kubernetes.scale"}, cfg, "")
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "kubernetes.scaleTypo"}, cfg,
+		"not matching driver manifest: driver kubernetes has no command scal")
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "kubernetes.scaleNoSource"}, cfg,
+		"not matching driver manifest: kubernetes.scale requires parameter source")
+	testCheckWorkflowFunctionHelper(t, config.Workflow{CallFunction: "kubernetes.scaleBadCount"}, cfg,
+		"not matching driver manifest: parameter replicas of kubernetes.scale should be int")
+	testCheckWorkflowDriverHelper(t, config.Workflow{CallDriver: "kubernetes.rolloutStatus", Local: map[string]interface{}{
+		"source": map[string]interface{}{"type": "local"},
+		"name":   "web",
+		"wait":   false,
+	}}, cfg, "")
+
+	assert.Equal(t, 1, checkDriverServices(cfg), "the shipped kubernetes manifest does not list engine")
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/honeydipper/honeydipper/drivers/pkg/membus"
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// DevService is the name of the single process development mode.
+const DevService = "dev"
+
+// ErrInvalidEvent is the error when an injected event can not be parsed.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// injectDevEvents waits for the services to be ready, then injects the events from the DEV_EVENTS file
+// and the stdin.
+func injectDevEvents() {
+	for {
+		if locker := cfg.Locker; locker != nil {
+			locker.Lock()
+			stage := cfg.Stage
+			locker.Unlock()
+			if stage >= config.StageServing {
+				break
+			}
+		}
+		time.Sleep(time.Second)
+	}
+
+	if file, ok := os.LookupEnv("DEV_EVENTS"); ok {
+		if f, err := os.Open(file); err != nil {
+			dipper.Logger.Errorf("[dev] unable to read events: %v", err)
+		} else {
+			readDevEvents(f)
+			f.Close()
+		}
+	}
+	readDevEvents(os.Stdin)
+}
+
+func readDevEvents(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if id, err := injectDevEvent(line); err != nil {
+			dipper.Logger.Warningf("[dev] %v", err)
+		} else {
+			dipper.Logger.Infof("[dev] injected event %s: %s", id, line)
+		}
+	}
+}
+
+// injectDevEvent parses an event in the format of "<driver>.<rawEvent> [data]", with the data in yaml flow style,
+// e.g. webhook {url: /health, method: GET}.  The rawEvent can be omitted for the drivers like webhook.
+func injectDevEvent(line string) (string, error) {
+	name, body := splitStatement(line)
+	if !strings.Contains(name, ".") {
+		name += "."
+	}
+
+	var data interface{}
+	if body != "" {
+		if err := yaml.Unmarshal([]byte(body), &data); err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrInvalidEvent, line, err)
+		}
+	}
+	if _, ok := data.(map[string]interface{}); !ok && data != nil {
+		return "", fmt.Errorf("%w: %s: event data should be a map", ErrInvalidEvent, line)
+	}
+
+	return membus.InjectEvent([]interface{}{name}, data), nil
+}
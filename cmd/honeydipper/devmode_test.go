@@ -0,0 +1,31 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectDevEvent(t *testing.T) {
+	id, err := injectDevEvent("webhook {url: /health, method: GET}")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, id, "should return the event ID")
+
+	_, err = injectDevEvent("kubernetes.job_finished")
+	assert.Nil(t, err, "event data is optional")
+
+	_, err = injectDevEvent("webhook {url")
+	assert.True(t, errors.Is(err, ErrInvalidEvent), "should reject invalid yaml")
+	_, err = injectDevEvent("webhook [a, b]")
+	assert.True(t, errors.Is(err, ErrInvalidEvent), "should reject data that is not a map")
+}
@@ -11,6 +11,7 @@ package main
 
 import (
 	"context"
+	"embed"
 	"io"
 	"net/http"
 	"os"
@@ -32,6 +33,9 @@ const (
 
 	// DocGenService is the name of the DocGen service.
 	DocGenService = "docgen"
+
+	// BuiltinTemplatePrefix is the prefix for using the templates shipped with Honeydipper, e.g. builtin:manifest.md.
+	BuiltinTemplatePrefix = "builtin:"
 )
 
 // DocItem describe a item or a group of items in the document output.
@@ -54,6 +58,9 @@ type DocGenConfig struct {
 var (
 	IncludePattern = regexp.MustCompile(`\{\{\s*include\s+"([\w\.\/-]+)"\s+\}\}`)
 	tmplCache      = map[string]string{}
+
+	//go:embed templates
+	builtinTemplates embed.FS
 )
 
 func runDocGen(cfg *config.Config) {
@@ -70,7 +77,8 @@ func runDocGen(cfg *config.Config) {
 	}
 
 	envData := map[string]interface{}{
-		"repos": dgCfg.Repos,
+		"repos":     dgCfg.Repos,
+		"manifests": config.GetShippedManifests(),
 	}
 
 	for _, item := range dgCfg.Items {
@@ -195,7 +203,11 @@ func createItem(item DocItem, envData map[string]interface{}, cfg *config.Config
 	dipper.Logger.Infof("Generating file %s from template %s", name, item.Template)
 	tmpl, ok := tmplCache[item.Template]
 	if !ok {
-		tmpl = readFile(cfg.DocSrc, item.Template)
+		if builtin := strings.TrimPrefix(item.Template, BuiltinTemplatePrefix); builtin != item.Template {
+			tmpl = string(dipper.Must(builtinTemplates.ReadFile(path.Join("templates", builtin))).([]byte))
+		} else {
+			tmpl = readFile(cfg.DocSrc, item.Template)
+		}
 		tmplCache[item.Template] = tmpl
 	}
 
@@ -210,6 +222,7 @@ func createItem(item DocItem, envData map[string]interface{}, cfg *config.Config
 			currentRepo.AdvanceStage(DocGenService, config.StageBooting)
 			currentRepo.AdvanceStage(DocGenService, config.StageDiscovering)
 			envData["current_repo"] = currentRepo.Staged
+			envData["current_manifests"] = config.GetDriverManifests(currentRepo.Staged)
 		}
 	}
 
@@ -0,0 +1,43 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateItemBuiltinManifest(t *testing.T) {
+	var kubernetes *config.DriverManifest
+	for _, m := range config.GetShippedManifests() {
+		if m.Name == "kubernetes" {
+			kubernetes = m
+		}
+	}
+	assert.NotNil(t, kubernetes, "kubernetes driver should ship a manifest")
+
+	cfg := &config.Config{DocDst: t.TempDir()}
+	createItem(DocItem{Name: "drivers/{{ .current.Name }}.md", Template: BuiltinTemplatePrefix + "manifest.md"},
+		map[string]interface{}{"current": kubernetes}, cfg)
+
+	content, err := os.ReadFile(path.Join(cfg.DocDst, "drivers", "kubernetes.md"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "# kubernetes\n")
+	assert.Contains(t, string(content), "Available in services: `operator`, `receiver`")
+	assert.Contains(t, string(content), "### createJob\n")
+	assert.Contains(t, string(content), "| `source` | map | yes |  | the cluster to connect to")
+	assert.Contains(t, string(content), "| `namespace` | string |  | `default` |  |")
+	assert.Contains(t, string(content), "| `metadata` | map |  |")
+	assert.NotContains(t, string(content), "## RPCs", "kubernetes driver offers no rpcs")
+}
@@ -0,0 +1,423 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/honeydipper/honeydipper/internal/driver"
+	"github.com/honeydipper/honeydipper/internal/service"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// DriverHarnessService is the name of the driver harness mode.
+	DriverHarnessService = "driver-harness"
+
+	// DefaultHarnessExpectTimeout is the default time to wait for an expected message.
+	DefaultHarnessExpectTimeout = 10 * time.Second
+
+	// HarnessStopTimeout is the time to wait for the driver to exit before killing it.
+	HarnessStopTimeout = time.Second
+)
+
+var (
+	// ErrHarness is the error when the harness is unable to run a statement.
+	ErrHarness = errors.New("harness error")
+	// ErrExpectationFailed is the error when an expected message is not received.
+	ErrExpectationFailed = errors.New("expectation failed")
+)
+
+const harnessHelp = `statements:
+  command <method> [params]                 call a command with the params in yaml flow style, e.g. {name: foo}
+  rpc <method> [params]                     call a rpc method
+  send <channel>:<subject> [message]        send a raw message, e.g. send rpc:return {labels: {rpcID: "1"}, payload: {}}
+  options [file]                            send the options again, optionally from another file, to reload the driver
+  expect <channel>:<subject> [path=value ...] [within <duration>]
+                                            wait for a message, e.g. expect eventbus:return labels.status=success
+  sleep <duration>                          wait while printing the received messages
+  help                                      print this message
+  quit                                      stop the driver and quit
+`
+
+// driverHarness runs a driver outside of the daemon, and talks to it through the driver runtime.
+type driverHarness struct {
+	runtime  *driver.Runtime
+	service  string
+	out      io.Writer
+	lock     sync.Mutex
+	received []*dipper.Message
+	notify   chan struct{}
+	done     chan struct{}
+	seq      int
+	failures int
+}
+
+// runDriverHarness starts the driver, then runs the statements from the DRIVER_SCRIPT file or from the stdin.
+func runDriverHarness(args []string) int {
+	if len(args) != 2 {
+		fmt.Printf("Usage: %s %s <executable> <service>\n", os.Args[0], DriverHarnessService)
+
+		return 1
+	}
+
+	var options interface{}
+	if file, ok := os.LookupEnv("DRIVER_OPTIONS"); ok {
+		var err error
+		if options, err = loadHarnessOptions(file); err != nil {
+			fmt.Println(err)
+
+			return 1
+		}
+	}
+
+	in := io.Reader(os.Stdin)
+	interactive := true
+	if file, ok := os.LookupEnv("DRIVER_SCRIPT"); ok {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Println(err)
+
+			return 1
+		}
+		defer f.Close()
+		in = f
+		interactive = false
+	}
+
+	h := newDriverHarness(args[0], args[1], options, os.Stdout)
+	if err := h.start(); err != nil {
+		fmt.Fprintln(h.out, err)
+		h.stop()
+
+		return 1
+	}
+	h.run(in, interactive)
+	h.stop()
+
+	if h.failures > 0 {
+		fmt.Fprintf(h.out, "%d expectation(s) failed\n", h.failures)
+
+		return 1
+	}
+
+	return 0
+}
+
+func loadHarnessOptions(file string) (interface{}, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var options interface{}
+	if err := yaml.Unmarshal(content, &options); err != nil {
+		return nil, fmt.Errorf("%w: invalid options in %s: %v", ErrHarness, file, err)
+	}
+
+	return options, nil
+}
+
+// newDriverHarness creates the driver runtime for the executable.  A file name ending with .wasm is loaded as a
+// wasm driver, and inproc:<name> loads a driver compiled into the daemon.
+func newDriverHarness(executable string, svc string, options interface{}, out io.Writer) *driverHarness {
+	meta := map[string]interface{}{}
+	switch {
+	case strings.HasPrefix(executable, "inproc:"):
+		name := strings.TrimPrefix(executable, "inproc:")
+		meta["name"] = name
+		meta["type"] = "inproc"
+		meta["handlerData"] = map[string]interface{}{"shortName": name}
+	case strings.HasSuffix(executable, ".wasm"):
+		meta["name"] = strings.TrimSuffix(filepath.Base(executable), ".wasm")
+		meta["type"] = "wasm"
+		meta["handlerData"] = map[string]interface{}{"path": executable}
+	default:
+		driver.BuiltinPath = filepath.Dir(executable)
+		meta["name"] = filepath.Base(executable)
+		meta["type"] = "builtin"
+		meta["handlerData"] = map[string]interface{}{"shortName": filepath.Base(executable)}
+	}
+
+	return &driverHarness{
+		runtime: driver.NewDriver("driver:"+meta["name"].(string), meta, options, nil),
+		service: svc,
+		out:     out,
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// start launches the driver and waits for it to be alive.
+func (h *driverHarness) start() error {
+	go h.receive()
+	h.runtime.Start(h.service)
+
+	return h.expect("state:alive", nil, service.DriverReadyTimeout*time.Second)
+}
+
+// stop closes the driver and waits for it to exit.
+func (h *driverHarness) stop() {
+	h.runtime.Handler.Close()
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		h.runtime.Handler.Wait()
+	}()
+	select {
+	case <-exited:
+	case <-time.After(HarnessStopTimeout):
+		if killer, ok := h.runtime.Handler.(driver.Killer); ok {
+			killer.Kill()
+		}
+	}
+}
+
+func (h *driverHarness) receive() {
+	defer close(h.done)
+	for msg := range h.runtime.Stream {
+		msg = dipper.DeserializePayload(msg)
+		h.print("<<", msg)
+
+		h.lock.Lock()
+		h.received = append(h.received, msg)
+		h.lock.Unlock()
+		select {
+		case h.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *driverHarness) print(direction string, msg *dipper.Message) {
+	body := map[string]interface{}{}
+	if len(msg.Labels) > 0 {
+		body["labels"] = msg.Labels
+	}
+	if msg.Payload != nil {
+		body["payload"] = msg.Payload
+	}
+	content, _ := json.Marshal(body)
+	fmt.Fprintf(h.out, "%s %s:%s %s\n", direction, msg.Channel, msg.Subject, content)
+}
+
+func (h *driverHarness) send(msg *dipper.Message) {
+	h.print(">>", msg)
+	h.runtime.SendMessage(msg)
+}
+
+// run executes the statements line by line until the end of the input or quit.
+func (h *driverHarness) run(in io.Reader, interactive bool) {
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			fmt.Fprint(h.out, "> ")
+		}
+		if !scanner.Scan() {
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "quit" || line == "exit" {
+			return
+		}
+		if err := h.execute(line); err != nil {
+			fmt.Fprintln(h.out, err)
+			if errors.Is(err, ErrExpectationFailed) {
+				h.failures++
+			}
+		}
+	}
+}
+
+// execute runs a single statement.
+func (h *driverHarness) execute(line string) error {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	verb, rest := splitStatement(line)
+
+	switch verb {
+	case "help":
+		fmt.Fprint(h.out, harnessHelp)
+	case "command", "rpc":
+		method, params := splitStatement(rest)
+		if method == "" {
+			return fmt.Errorf("%w: method is required", ErrHarness)
+		}
+		payload, err := parseHarnessYaml(params)
+		if err != nil {
+			return err
+		}
+		h.seq++
+		msg := &dipper.Message{Payload: payload, Labels: map[string]string{"method": method}}
+		if verb == "command" {
+			msg.Channel, msg.Subject = dipper.ChannelEventbus, dipper.EventbusCommand
+			msg.Labels["sessionID"] = "harness-" + strconv.Itoa(h.seq)
+		} else {
+			msg.Channel, msg.Subject = dipper.ChannelRPC, "call"
+			msg.Labels["rpcID"] = strconv.Itoa(h.seq)
+			msg.Labels["caller"] = "-"
+		}
+		h.send(msg)
+	case "send":
+		return h.sendRaw(rest)
+	case "options":
+		if rest != "" {
+			options, err := loadHarnessOptions(rest)
+			if err != nil {
+				return err
+			}
+			h.runtime.Data = options
+		}
+		h.runtime.SendOptions()
+	case "expect":
+		return h.executeExpect(rest)
+	case "sleep":
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrHarness, err)
+		}
+		time.Sleep(d)
+	default:
+		return fmt.Errorf("%w: unknown statement %s, try help", ErrHarness, verb)
+	}
+
+	return nil
+}
+
+func (h *driverHarness) sendRaw(rest string) error {
+	target, body := splitStatement(rest)
+	parts := strings.SplitN(target, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("%w: send requires <channel>:<subject>", ErrHarness)
+	}
+	parsed, err := parseHarnessYaml(body)
+	if err != nil {
+		return err
+	}
+	msg := &dipper.Message{Channel: parts[0], Subject: parts[1]}
+	if parsed != nil {
+		raw, _ := json.Marshal(parsed)
+		if err := json.Unmarshal(raw, &struct {
+			Labels  *map[string]string `json:"labels"`
+			Payload *interface{}       `json:"payload"`
+		}{&msg.Labels, &msg.Payload}); err != nil {
+			return fmt.Errorf("%w: invalid message: %v", ErrHarness, err)
+		}
+	}
+	h.send(msg)
+
+	return nil
+}
+
+func (h *driverHarness) executeExpect(rest string) error {
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: expect requires <channel>:<subject>", ErrHarness)
+	}
+	timeout := DefaultHarnessExpectTimeout
+	conditions := map[string]string{}
+	for i := 1; i < len(fields); i++ {
+		if fields[i] == "within" && i+1 < len(fields) {
+			d, err := time.ParseDuration(fields[i+1])
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrHarness, err)
+			}
+			timeout = d
+			i++
+
+			continue
+		}
+		kv := strings.SplitN(fields[i], "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%w: condition should be path=value: %s", ErrHarness, fields[i])
+		}
+		conditions[kv[0]] = kv[1]
+	}
+
+	return h.expect(fields[0], conditions, timeout)
+}
+
+// expect waits for a received message matching the channel:subject and the conditions, and consumes it.
+func (h *driverHarness) expect(pattern string, conditions map[string]string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		if h.consume(pattern, conditions) {
+			fmt.Fprintf(h.out, "expectation met: %s\n", pattern)
+
+			return nil
+		}
+		select {
+		case <-h.notify:
+		case <-h.done:
+			if h.consume(pattern, conditions) {
+				return nil
+			}
+
+			return fmt.Errorf("%w: driver exited before receiving %s", ErrExpectationFailed, pattern)
+		case <-deadline:
+			return fmt.Errorf("%w: no %s matching %v received within %s", ErrExpectationFailed, pattern, conditions, timeout)
+		}
+	}
+}
+
+func (h *driverHarness) consume(pattern string, conditions map[string]string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for i, msg := range h.received {
+		if msg.Channel+":"+msg.Subject != pattern {
+			continue
+		}
+		data := map[string]interface{}{"labels": msg.Labels, "payload": msg.Payload}
+		matched := true
+		for path, expected := range conditions {
+			if v, ok := dipper.GetMapData(data, path); !ok || fmt.Sprint(v) != expected {
+				matched = false
+
+				break
+			}
+		}
+		if matched {
+			h.received = append(h.received[:i], h.received[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitStatement(line string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], strings.TrimSpace(parts[1])
+}
+
+func parseHarnessYaml(s string) (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ret interface{}
+	if err := yaml.Unmarshal([]byte(s), &ret); err != nil {
+		return nil, fmt.Errorf("%w: invalid yaml %s: %v", ErrHarness, s, err)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	dipper.RegisterInProcDriver("test-harness", func(service string) *dipper.Driver {
+		d := dipper.NewDriver(service, "test-harness")
+		d.RPCHandlers["echo"] = func(msg *dipper.Message) {
+			msg.Reply <- dipper.Message{Payload: msg.Payload}
+		}
+		d.Commands["greet"] = func(msg *dipper.Message) {
+			msg = dipper.DeserializePayload(msg)
+			name, _ := dipper.GetMapDataStr(msg.Payload, "name")
+			msg.Reply <- dipper.Message{Payload: map[string]interface{}{"greeting": "hello-" + name}}
+		}
+
+		return d
+	})
+}
+
+func TestDriverHarness(t *testing.T) {
+	out := &bytes.Buffer{}
+	h := newDriverHarness("inproc:test-harness", "operator", map[string]interface{}{"key": "val"}, out)
+	assert.Nil(t, h.start(), "driver should be alive")
+	defer h.stop()
+
+	assert.Nil(t, h.execute("# comments are ignored"))
+	assert.Nil(t, h.execute("rpc echo {foo: bar}"))
+	assert.Nil(t, h.execute("expect rpc:return labels.rpcID=1 payload.foo=bar within 5s"))
+	assert.Nil(t, h.execute("command greet {name: world}"))
+	assert.Nil(t, h.execute("expect eventbus:return labels.sessionID=harness-2 payload.greeting=hello-world within 5s"))
+
+	err := h.execute("expect rpc:return labels.rpcID=3 within 100ms")
+	assert.True(t, errors.Is(err, ErrExpectationFailed), "should fail when the message is not received")
+
+	assert.True(t, errors.Is(h.execute("unknown"), ErrHarness), "should reject unknown statements")
+	assert.True(t, errors.Is(h.execute("send rpc"), ErrHarness), "should require channel and subject")
+	assert.True(t, errors.Is(h.execute("rpc echo {foo"), ErrHarness), "should reject invalid yaml")
+
+	assert.Contains(t, out.String(), ">> rpc:call")
+	assert.Contains(t, out.String(), `<< rpc:return {"labels":{"caller":"-","rpcID":"1"},"payload":{"foo":"bar"}}`)
+}
+
+func TestDriverHarnessRun(t *testing.T) {
+	out := &bytes.Buffer{}
+	h := newDriverHarness("inproc:test-harness", "operator", nil, out)
+	assert.Nil(t, h.start(), "driver should be alive")
+	defer h.stop()
+
+	h.run(strings.NewReader("rpc echo {foo: bar}\nexpect rpc:return payload.foo=baz within 100ms\nquit\nrpc echo\n"), false)
+	assert.Equal(t, 1, h.failures, "should count the failed expectations")
+	assert.NotContains(t, out.String(), "rpcID\":\"2\"", "should stop at quit")
+}
@@ -0,0 +1,14 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	// drivers linked into the daemon, so they can be loaded with the inproc type.
+	_ "github.com/honeydipper/honeydipper/drivers/pkg/authsimple"
+	_ "github.com/honeydipper/honeydipper/drivers/pkg/membus"
+	_ "github.com/honeydipper/honeydipper/drivers/pkg/rediscache"
+)
@@ -0,0 +1,39 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"testing"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInProcDriversLinked(t *testing.T) {
+	rpcs := map[string][]string{
+		"auth-simple": {"auth_web_request"},
+		"redis-cache": {"save", "load", "delete"},
+		"memqueue":    {},
+		"mempubsub":   {},
+	}
+
+	for name, methods := range rpcs {
+		factory, ok := dipper.GetInProcDriver(name)
+		assert.True(t, ok, "%s should be registered as inproc driver", name)
+		if !ok {
+			continue
+		}
+		d := factory("operator")
+		assert.Equal(t, name, d.Name)
+		for _, method := range methods {
+			assert.Contains(t, d.RPCHandlers, method, "%s should handle rpc %s", name, method)
+		}
+	}
+}
@@ -16,16 +16,24 @@ import (
 
 	"github.com/honeydipper/honeydipper/internal/config"
 	"github.com/honeydipper/honeydipper/internal/daemon"
+	"github.com/honeydipper/honeydipper/internal/driver"
 	"github.com/honeydipper/honeydipper/internal/service"
 	"github.com/honeydipper/honeydipper/pkg/dipper"
 )
 
-var cfg config.Config
+var (
+	cfg config.Config
+
+	// harnessArgs keeps the arguments for the driver-harness mode.
+	harnessArgs []string
+)
 
 func initFlags() {
 	flag.Usage = func() {
 		msg := `
 Usage:  %v [ -h ] service1 service2 ...
+        %[1]v dev
+        %[1]v driver-harness <executable> <service>
 
   -h            print this help message and quit
 
@@ -41,10 +49,22 @@ Configcheck service is used for validating local uncommitted config files.
 
 Docgen service is used for generating documents input for sphinx.
 
+Dev runs all non-auxiliary services in one process for local development. The
+services talk to each other through in-memory eventbus and broadcast drivers
+instead of redis, the local config files are reloaded as soon as they change,
+and events can be injected from stdin, one per line, e.g.
+
+  webhook {url: /health, method: GET}
+
+Driver-harness runs a driver without the daemon for driver development. The
+executable can be a path to the driver, a .wasm module, or inproc:<name>. The
+statements are read from stdin interactively, type help for the statements.
+
 See below for a listed environment variables that can be used.
 
-REPO:           required, the bootstrap config repo or directory
-BRANCH:         defaults to master, the branch to use in the bootstrap repo
+REPO:           required, the bootstrap config repo or directory, defaults to . in dev
+BRANCH:         defaults to master, the branch to use in the bootstrap repo, in dev
+                the uncommitted files are used if not specified
 BOOTSTRAP_PATH: defaults to /, the path from where to load init.yaml
 
 CHECK_REMOTE:   defaults to false, when running config check specify if load and check remote repos
@@ -52,6 +72,11 @@ CHECK_REMOTE:   defaults to false, when running config check specify if load and
 DOCSRC:         defaults to docs/src, specify the source files for docgen
 DOCDST:         defaults to docs/dst, specify the directory to store generated files for docgen
 
+DEV_EVENTS:     a file with the events to inject before reading stdin in dev
+
+DRIVER_OPTIONS: a yaml file with the driver data to be sent as options in driver-harness
+DRIVER_SCRIPT:  a file with the statements to run instead of stdin in driver-harness
+
 `
 		fmt.Printf(msg, os.Args[0])
 	}
@@ -60,6 +85,12 @@ DOCDST:         defaults to docs/dst, specify the directory to store generated f
 func initEnv() {
 	initFlags()
 	flag.Parse()
+	if flag.Arg(0) == DriverHarnessService {
+		harnessArgs = flag.Args()[1:]
+		getLogger()
+
+		return
+	}
 	cfg = config.Config{InitRepo: config.RepoInfo{}, Services: flag.Args()}
 	if len(cfg.Services) == 0 {
 		cfg.Services = []string{"engine", "receiver", "operator", "api"}
@@ -90,6 +121,11 @@ loop:
 				cfg.DocDst = "docs/dst"
 			}
 
+			break loop
+		case DevService:
+			cfg.Services = []string{"engine", "receiver", "operator", "api"}
+			cfg.IsDevMode = true
+
 			break loop
 		}
 	}
@@ -97,10 +133,12 @@ loop:
 
 	if !cfg.IsDocGen {
 		var ok bool
-		if cfg.InitRepo.Repo, ok = os.LookupEnv("REPO"); !ok {
+		if cfg.InitRepo.Repo, ok = os.LookupEnv("REPO"); !ok && cfg.IsDevMode {
+			cfg.InitRepo.Repo = "."
+		} else if !ok {
 			log.Fatal("REPO environment variable is required to bootstrap honeydipper")
 		}
-		if cfg.InitRepo.Branch, ok = os.LookupEnv("BRANCH"); !ok {
+		if cfg.InitRepo.Branch, ok = os.LookupEnv("BRANCH"); !ok && !cfg.IsDevMode {
 			cfg.InitRepo.Branch = "master"
 		}
 		if cfg.InitRepo.Path, ok = os.LookupEnv("BOOTSTRAP_PATH"); !ok {
@@ -127,6 +165,10 @@ func start() {
 			dipper.Logger.Fatalf("'%v' service is not implemented", s)
 		}
 	}
+
+	if cfg.IsDevMode {
+		go injectDevEvents()
+	}
 }
 
 func reload() {
@@ -155,8 +197,11 @@ func getLogger() {
 }
 
 func main() {
+	driver.SandboxExec()
 	initEnv()
 	switch {
+	case harnessArgs != nil:
+		os.Exit(runDriverHarness(harnessArgs))
 	case cfg.IsConfigCheck:
 		exitCode := 1
 		defer func() {
@@ -0,0 +1,476 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+const (
+	// StrategyFirst selects the first ready pod, sorted by name.
+	StrategyFirst = "first"
+	// StrategyRandom selects a random ready pod.
+	StrategyRandom = "random"
+	// StrategyAll selects all the ready pods.
+	StrategyAll = "all"
+
+	// DefaultExecTimeout is the default timeout in seconds for exec and port-forward commands.
+	DefaultExecTimeout time.Duration = 30
+
+	// DefaultOutputLimit is the default max number of bytes kept from the output of each pod.
+	DefaultOutputLimit = 1 << 20
+)
+
+// ErrPortForward is the error when a port-forward can not be established.
+var ErrPortForward = errors.New("port-forward error")
+
+// cancelableUpgrader closes the upgraded connection when the context is done, so the streams on it are torn down.
+type cancelableUpgrader struct {
+	spdy.Upgrader
+	ctx context.Context
+}
+
+// NewConnection creates the connection and closes it when the context is done.
+func (u *cancelableUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := u.Upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-u.ctx.Done():
+			conn.Close()
+		case <-conn.CloseChan():
+		}
+	}()
+
+	return conn, nil
+}
+
+// replace the func variable with mock during testing.
+var execInPod = func(
+	ctx context.Context,
+	m *dipper.Message,
+	namespace string,
+	pod string,
+	opts *corev1.PodExecOptions,
+	streams remotecommand.StreamOptions,
+) error {
+	kubeConfig := getKubeConfig(m)
+	client, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(kubeConfig)
+	if err != nil {
+		return err
+	}
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(opts, scheme.ParameterCodec)
+	upgrader = &cancelableUpgrader{Upgrader: upgrader, ctx: ctx}
+	executor, err := remotecommand.NewSPDYExecutorForTransports(transport, upgrader, http.MethodPost, req.URL())
+	if err != nil {
+		return err
+	}
+
+	return executor.Stream(streams)
+}
+
+// replace the func variable with mock during testing.
+var forwardToPod = func(m *dipper.Message, namespace string, pod string, port int, stop <-chan struct{}) (uint16, error) {
+	kubeConfig := getKubeConfig(m)
+	client, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return 0, err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(kubeConfig)
+	if err != nil {
+		return 0, err
+	}
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+
+	ready := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stop, ready, io.Discard, io.Discard)
+	if err != nil {
+		return 0, err
+	}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case <-ready:
+	case err = <-failed:
+		return 0, fmt.Errorf("%w: %v", ErrPortForward, err)
+	case <-stop:
+		return 0, fmt.Errorf("%w: stopped before ready", ErrPortForward)
+	}
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		return 0, fmt.Errorf("%w: no local port: %v", ErrPortForward, err)
+	}
+
+	return ports[0].Local, nil
+}
+
+// limitedBuffer keeps the written bytes up to the limit, and records if anything is dropped.  It is safe to read while
+// the streams are still writing to it.
+type limitedBuffer struct {
+	lock      sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+// String returns the bytes kept in the buffer.
+func (b *limitedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
+
+// Truncated tells if any bytes are dropped.
+func (b *limitedBuffer) Truncated() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.truncated
+}
+
+// getOutputLimit reads the max number of bytes kept from the output of each pod.
+func getOutputLimit(m *dipper.Message) int {
+	if limit, ok := dipper.GetMapData(m.Payload, "outputLimit"); ok {
+		l, err := strconv.Atoi(fmt.Sprint(limit))
+		if err != nil || l <= 0 {
+			log.Panicf("[%s] outputLimit should be a positive integer", driver.Service)
+		}
+
+		return l
+	}
+
+	return DefaultOutputLimit
+}
+
+// getExecTimeout reads the timeout for exec and port-forward from the labels.
+func getExecTimeout(m *dipper.Message) time.Duration {
+	timeout := DefaultExecTimeout
+	if timeoutStr, ok := m.Labels["timeout"]; ok {
+		timeoutInt, _ := strconv.Atoi(timeoutStr)
+		timeout = time.Duration(timeoutInt)
+	}
+
+	return timeout
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// selectPods finds the pods by name or by labelSelector and strategy.
+func selectPods(m *dipper.Message, namespace string) []string {
+	if name, ok := dipper.GetMapDataStr(m.Payload, "pod"); ok {
+		return []string{name}
+	}
+
+	labelSelector, ok := dipper.GetMapDataStr(m.Payload, "labelSelector")
+	if !ok {
+		log.Panicf("[%s] either pod or labelSelector is required", driver.Service)
+	}
+	strategy, ok := dipper.GetMapDataStr(m.Payload, "strategy")
+	if !ok {
+		strategy = StrategyFirst
+	}
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	pods, err := newKubeClient(m).CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		log.Panicf("[%s] unable to list pods with %s: %+v", driver.Service, labelSelector, err)
+	}
+	ready := []string{}
+	for i := range pods.Items {
+		if isPodReady(&pods.Items[i]) {
+			ready = append(ready, pods.Items[i].Name)
+		}
+	}
+	if len(ready) == 0 {
+		log.Panicf("[%s] no ready pod found with %s", driver.Service, labelSelector)
+	}
+	sort.Strings(ready)
+
+	switch strategy {
+	case StrategyFirst:
+		return ready[:1]
+	case StrategyRandom:
+		//nolint:gosec
+		return []string{ready[rand.Intn(len(ready))]}
+	case StrategyAll:
+		return ready
+	default:
+		log.Panicf("[%s] unsupported strategy %s, should be one of first, random or all", driver.Service, strategy)
+	}
+
+	return nil
+}
+
+// forEachPod runs the function for each of the pods in parallel, and collects the results in the order of pods.
+func forEachPod(pods []string, f func(pod string) map[string]interface{}) ([]interface{}, []string) {
+	results := make([]interface{}, len(pods))
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		wg.Add(1)
+		go func(i int, pod string) {
+			defer wg.Done()
+			defer dipper.SafeExitOnError("[%s] failed on pod %s", driver.Service, pod, func(r interface{}) {
+				results[i] = map[string]interface{}{"pod": pod, "error": fmt.Sprint(r)}
+			})
+			results[i] = f(pod)
+			results[i].(map[string]interface{})["pod"] = pod
+		}(i, pod)
+	}
+	wg.Wait()
+
+	failures := []string{}
+	for _, r := range results {
+		if e, ok := r.(map[string]interface{})["error"]; ok {
+			failures = append(failures, fmt.Sprintf("%s: %s", r.(map[string]interface{})["pod"], e))
+		}
+	}
+
+	return results, failures
+}
+
+// replyPodResults sends the results of the pods, with failure status if any of the pods failed.
+func replyPodResults(m *dipper.Message, results []interface{}, failures []string) {
+	ret := dipper.Message{
+		Payload: map[string]interface{}{"results": results},
+	}
+	if len(results) == 1 {
+		ret.Payload = dipper.MergeMap(map[string]interface{}{"results": results}, results[0].(map[string]interface{}))
+	}
+	if len(failures) > 0 {
+		ret.Labels = map[string]string{
+			"status": StatusFailure,
+			"reason": strings.Join(failures, "\n"),
+		}
+	}
+	m.Reply <- ret
+}
+
+func podExec(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	nameSpace, ok := dipper.GetMapDataStr(m.Payload, "namespace")
+	if !ok {
+		nameSpace = DefaultNamespace
+	}
+
+	var command []string
+	switch cmd := dipper.MustGetMapData(m.Payload, "command").(type) {
+	case string:
+		command = []string{"sh", "-c", cmd}
+	case []interface{}:
+		for _, arg := range cmd {
+			command = append(command, fmt.Sprint(arg))
+		}
+	default:
+		log.Panicf("[%s] command should be a string or a list of strings", driver.Service)
+	}
+	container, _ := dipper.GetMapDataStr(m.Payload, "container")
+	stdin, hasStdin := dipper.GetMapDataStr(m.Payload, "stdin")
+	limit := getOutputLimit(m)
+	timeout := getExecTimeout(m)
+
+	results, failures := forEachPod(selectPods(m, nameSpace), func(pod string) map[string]interface{} {
+		stdout := &limitedBuffer{limit: limit}
+		stderr := &limitedBuffer{limit: limit}
+		streams := remotecommand.StreamOptions{Stdout: stdout, Stderr: stderr}
+		if hasStdin {
+			streams.Stdin = strings.NewReader(stdin)
+		}
+		opts := &corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     hasStdin,
+			Stdout:    true,
+			Stderr:    true,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout*time.Second)
+		defer cancel()
+		done := make(chan error, 1)
+		go func() {
+			done <- execInPod(ctx, m, nameSpace, pod, opts, streams)
+		}()
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = fmt.Errorf("timeout after %d seconds", timeout)
+		}
+
+		result := map[string]interface{}{
+			"exitCode":  0,
+			"stdout":    stdout.String(),
+			"stderr":    stderr.String(),
+			"truncated": stdout.Truncated() || stderr.Truncated(),
+		}
+		var exitErr utilexec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			result["exitCode"] = exitErr.ExitStatus()
+			result["error"] = fmt.Sprintf("command exited with code %d", exitErr.ExitStatus())
+		case err != nil:
+			result["exitCode"] = -1
+			result["error"] = err.Error()
+		}
+
+		return result
+	})
+
+	replyPodResults(m, results, failures)
+}
+
+func portForwardRequest(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	nameSpace, ok := dipper.GetMapDataStr(m.Payload, "namespace")
+	if !ok {
+		nameSpace = DefaultNamespace
+	}
+
+	port, err := strconv.Atoi(fmt.Sprint(dipper.MustGetMapData(m.Payload, "port")))
+	if err != nil || port <= 0 {
+		log.Panicf("[%s] port should be a positive integer", driver.Service)
+	}
+	path, ok := dipper.GetMapDataStr(m.Payload, "path")
+	if !ok {
+		path = "/"
+	}
+	method, ok := dipper.GetMapDataStr(m.Payload, "method")
+	if !ok {
+		method = http.MethodGet
+	}
+	var body []byte
+	switch b := m.Payload.(map[string]interface{})["body"].(type) {
+	case nil:
+	case string:
+		body = []byte(b)
+	default:
+		body = dipper.Must(json.Marshal(b)).([]byte)
+	}
+	headers, _ := dipper.GetMapData(m.Payload, "headers")
+	limit := getOutputLimit(m)
+	timeout := getExecTimeout(m)
+
+	results, failures := forEachPod(selectPods(m, nameSpace), func(pod string) map[string]interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout*time.Second)
+		defer cancel()
+		localPort, err := forwardToPod(m, nameSpace, pod, port, ctx.Done())
+		if err != nil {
+			panic(err)
+		}
+
+		target := url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", localPort), Path: path}
+		if i := strings.Index(path, "?"); i >= 0 {
+			target.Path, target.RawQuery = path[:i], path[i+1:]
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			panic(err)
+		}
+		if h, ok := headers.(map[string]interface{}); ok {
+			for k, v := range h {
+				req.Header.Set(k, fmt.Sprint(v))
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			panic(err)
+		}
+		defer resp.Body.Close()
+
+		content := &limitedBuffer{limit: limit}
+		if _, err = io.Copy(content, resp.Body); err != nil {
+			panic(err)
+		}
+		respHeaders := map[string]interface{}{}
+		for k := range resp.Header {
+			respHeaders[k] = resp.Header.Get(k)
+		}
+		result := map[string]interface{}{
+			"statusCode": resp.StatusCode,
+			"headers":    respHeaders,
+			"body":       content.String(),
+			"truncated":  content.Truncated(),
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			result["error"] = resp.Status
+		}
+
+		return result
+	})
+
+	replyPodResults(m, results, failures)
+}
@@ -0,0 +1,231 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func newTestPod(name string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestSelectPods(t *testing.T) {
+	useFakeKubeClient(t, newTestPod("web-c", true), newTestPod("web-a", false), newTestPod("web-b", true))
+
+	assert.Equal(t, []string{"named"}, selectPods(&dipper.Message{Payload: map[string]interface{}{"pod": "named"}}, "default"))
+	assert.Equal(t, []string{"web-b"}, selectPods(&dipper.Message{Payload: map[string]interface{}{"labelSelector": "app=web"}}, "default"), "should pick the first ready pod")
+	assert.Equal(t, []string{"web-b", "web-c"}, selectPods(&dipper.Message{Payload: map[string]interface{}{"labelSelector": "app=web", "strategy": "all"}}, "default"))
+	assert.Contains(t, []string{"web-b", "web-c"}, selectPods(&dipper.Message{Payload: map[string]interface{}{"labelSelector": "app=web", "strategy": "random"}}, "default")[0])
+	assert.Panics(t, func() {
+		selectPods(&dipper.Message{Payload: map[string]interface{}{"labelSelector": "app=db"}}, "default")
+	}, "should panic when no ready pod found")
+	assert.Panics(t, func() {
+		selectPods(&dipper.Message{Payload: map[string]interface{}{"labelSelector": "app=web", "strategy": "best"}}, "default")
+	}, "should panic with unknown strategy")
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+	n, err := b.Write([]byte("abc"))
+	assert.Equal(t, 3, n)
+	assert.Nil(t, err)
+	assert.False(t, b.Truncated())
+	n, _ = b.Write([]byte("defg"))
+	assert.Equal(t, 4, n, "should accept all the bytes")
+	assert.Equal(t, "abcde", b.String())
+	assert.True(t, b.Truncated())
+}
+
+func TestPodExec(t *testing.T) {
+	useFakeKubeClient(t, newTestPod("web-a", true), newTestPod("web-b", true))
+	orig := execInPod
+	defer func() { execInPod = orig }()
+	execInPod = func(
+		ctx context.Context,
+		m *dipper.Message,
+		namespace string,
+		pod string,
+		opts *corev1.PodExecOptions,
+		streams remotecommand.StreamOptions,
+	) error {
+		assert.Equal(t, []string{"sh", "-c", "cat"}, opts.Command)
+		assert.Equal(t, "app", opts.Container)
+		input, _ := io.ReadAll(streams.Stdin)
+		fmt.Fprintf(streams.Stdout, "%s from %s", input, pod)
+		if pod == "web-b" {
+			return utilexec.CodeExitError{Err: fmt.Errorf("exit"), Code: 2}
+		}
+
+		return nil
+	}
+
+	ret := callCommand(podExec, map[string]interface{}{
+		"labelSelector": "app=web",
+		"strategy":      "all",
+		"container":     "app",
+		"command":       "cat",
+		"stdin":         "hello",
+		"outputLimit":   17,
+	})
+	results := ret.Payload.(map[string]interface{})["results"].([]interface{})
+	assert.Len(t, results, 2)
+	assert.Equal(t, "hello from web-a", dipper.MustGetMapDataStr(results[0], "stdout"))
+	assert.Equal(t, 0, dipper.MustGetMapData(results[0], "exitCode"))
+	assert.Equal(t, 2, dipper.MustGetMapData(results[1], "exitCode"))
+	assert.Equal(t, StatusFailure, ret.Labels["status"], "should fail if any pod fails")
+	assert.Contains(t, ret.Labels["reason"], "web-b")
+
+	ret = callCommand(podExec, map[string]interface{}{"pod": "web-a", "container": "app", "command": "cat", "stdin": "hello world"})
+	assert.Equal(t, "hello world from web-a", dipper.MustGetMapDataStr(ret.Payload, "stdout"), "should return the single result at top level")
+	assert.Nil(t, ret.Labels)
+}
+
+func TestPodExecTimeout(t *testing.T) {
+	useFakeKubeClient(t, newTestPod("web-a", true))
+	orig := execInPod
+	defer func() { execInPod = orig }()
+	stopped := make(chan struct{})
+	execInPod = func(
+		ctx context.Context,
+		m *dipper.Message,
+		namespace string,
+		pod string,
+		opts *corev1.PodExecOptions,
+		streams remotecommand.StreamOptions,
+	) error {
+		defer close(stopped)
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				fmt.Fprint(streams.Stdout, "y")
+				fmt.Fprint(streams.Stderr, "n")
+			}
+		}
+	}
+
+	m := &dipper.Message{
+		Payload: map[string]interface{}{"pod": "web-a", "command": "yes", "outputLimit": 10},
+		Labels:  map[string]string{"timeout": "1"},
+		Reply:   make(chan dipper.Message, 1),
+	}
+	podExec(m)
+	ret := <-m.Reply
+	assert.Equal(t, "timeout after 1 seconds", dipper.MustGetMapDataStr(ret.Payload, "error"))
+	assert.Equal(t, -1, dipper.MustGetMapData(ret.Payload, "exitCode"))
+	assert.Equal(t, "yyyyyyyyyy", dipper.MustGetMapDataStr(ret.Payload, "stdout"))
+	assert.True(t, dipper.MustGetMapData(ret.Payload, "truncated").(bool))
+	assert.Eventually(t, func() bool {
+		<-stopped
+
+		return true
+	}, time.Second, 10*time.Millisecond, "should stop the streams after timeout")
+}
+
+type fakeUpgrader struct {
+	conn httpstream.Connection
+}
+
+func (u *fakeUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	return u.conn, nil
+}
+
+type fakeConnection struct {
+	httpstream.Connection
+	closed chan bool
+}
+
+func (c *fakeConnection) Close() error {
+	close(c.closed)
+
+	return nil
+}
+
+func (c *fakeConnection) CloseChan() <-chan bool {
+	return c.closed
+}
+
+func TestCancelableUpgrader(t *testing.T) {
+	conn := &fakeConnection{closed: make(chan bool)}
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &cancelableUpgrader{Upgrader: &fakeUpgrader{conn: conn}, ctx: ctx}
+	ret, err := u.NewConnection(&http.Response{})
+	assert.Nil(t, err)
+	assert.Same(t, conn, ret)
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		<-conn.CloseChan()
+
+		return true
+	}, time.Second, 10*time.Millisecond, "should close the connection when the context is done")
+}
+
+func TestPortForwardRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Test", r.Header.Get("X-Test"))
+		fmt.Fprintf(w, "%s %s?%s %s", r.Method, r.URL.Path, r.URL.RawQuery, body)
+	}))
+	defer server.Close()
+	_, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	localPort, _ := strconv.Atoi(portStr)
+
+	orig := forwardToPod
+	defer func() { forwardToPod = orig }()
+	forwardToPod = func(m *dipper.Message, namespace string, pod string, port int, stop <-chan struct{}) (uint16, error) {
+		assert.Equal(t, "web-a", pod)
+		assert.Equal(t, 8081, port)
+
+		return uint16(localPort), nil
+	}
+
+	ret := callCommand(portForwardRequest, map[string]interface{}{
+		"pod":     "web-a",
+		"port":    8081,
+		"method":  "POST",
+		"path":    "/admin/cache?flush=all",
+		"headers": map[string]interface{}{"X-Test": "yes"},
+		"body":    map[string]interface{}{"key": "val"},
+	})
+	assert.Nil(t, ret.Labels)
+	assert.Equal(t, 200, dipper.MustGetMapData(ret.Payload, "statusCode"))
+	assert.Equal(t, `POST /admin/cache?flush=all {"key":"val"}`, dipper.MustGetMapDataStr(ret.Payload, "body"))
+	assert.Equal(t, "yes", dipper.MustGetMapDataStr(ret.Payload, "headers.X-Test"))
+}
@@ -9,7 +9,6 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
 	"flag"
 	"fmt"
 	"io"
@@ -32,7 +31,6 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	batchv1client "k8s.io/client-go/kubernetes/typed/batch/v1"
-	"k8s.io/client-go/rest"
 )
 
 const (
@@ -61,6 +59,7 @@ func initFlags() {
 	flag.Usage = func() {
 		fmt.Printf("%s [ -h ] <service name>\n", os.Args[0])
 		fmt.Println("    This driver supports services including receiver, workflow, operator etc")
+		fmt.Println("    In receiver service, it watches the resources and emits events on the changes")
 		fmt.Println("  This program provides honeydipper with capability of interacting with kuberntes")
 	}
 }
@@ -76,11 +75,35 @@ func main() {
 	driver.Commands["recycleDeployment"] = recycleDeployment
 	driver.Commands["createJob"] = createJob
 	driver.Commands["waitForJob"] = waitForJob
+	driver.Commands["runJob"] = runJob
 	driver.Commands["getJobLog"] = getJobLog
 	driver.Commands["deleteJob"] = deleteJob
 	driver.Commands["createPVC"] = createPVC
 	driver.Commands["deletePVC"] = deletePVC
-	driver.Reload = func(*dipper.Message) {}
+	driver.Commands["apply"] = applyResource
+	driver.Commands["get"] = getResource
+	driver.Commands["list"] = listResources
+	driver.Commands["patch"] = patchResource
+	driver.Commands["delete"] = deleteResource
+	driver.Commands["scale"] = scaleResource
+	driver.Commands["rolloutStatus"] = rolloutStatus
+	driver.Commands["rolloutUndo"] = rolloutUndo
+	driver.Commands["rolloutPause"] = rolloutPause
+	driver.Commands["rolloutResume"] = rolloutResume
+	driver.Commands["restart"] = restartWorkload
+	driver.Commands["exec"] = podExec
+	driver.Commands["portForwardRequest"] = portForwardRequest
+	if driver.Service == "receiver" {
+		driver.Start = func(m *dipper.Message) {
+			loadOptions(m)
+			startWatches(m)
+		}
+		driver.Reload = driver.Start
+		driver.Stop = stopWatches
+	} else {
+		driver.Start = loadOptions
+		driver.Reload = loadOptions
+	}
 	driver.Run()
 }
 
@@ -202,40 +225,53 @@ func waitForJob(m *dipper.Message) {
 
 	jobName := dipper.MustGetMapDataStr(m.Payload, "job")
 
+	ctxWatch, cancelWatch := context.WithTimeout(context.Background(), getJobWaitTimeout(m)*time.Second)
+	defer cancelWatch()
+	if job := watchJob(ctxWatch, m, nameSpace, jobName); job != nil {
+		m.Reply <- jobStatusMessage(job, map[string]interface{}{
+			"status": job.Status,
+		})
+	}
+}
+
+// getJobWaitTimeout reads the timeout for waiting the job from the labels.
+func getJobWaitTimeout(m *dipper.Message) time.Duration {
 	timeout := DefaultJobWaitTimeout
 	if timeoutStr, ok := m.Labels["timeout"]; ok {
 		timeoutInt, _ := strconv.Atoi(timeoutStr)
 		timeout = time.Duration(timeoutInt)
 	}
 
-	returnJobStatus := func(job *batchv1.Job) {
-		var (
-			jobStatus = StatusSuccess
-			reason    []string
-		)
-		if job.Status.Failed > 0 {
-			jobStatus = StatusFailure
-			for _, condition := range job.Status.Conditions {
-				reason = append(reason, condition.Reason)
-			}
-		}
-		m.Reply <- dipper.Message{
-			Payload: map[string]interface{}{
-				"status": job.Status,
-			},
-			Labels: map[string]string{
-				"status": jobStatus,
-				"reason": strings.Join(reason, "\n"),
-			},
+	return timeout
+}
+
+// jobStatusMessage builds the reply with the status of a finished job.
+func jobStatusMessage(job *batchv1.Job, payload map[string]interface{}) dipper.Message {
+	var (
+		jobStatus = StatusSuccess
+		reason    []string
+	)
+	if job.Status.Failed > 0 {
+		jobStatus = StatusFailure
+		for _, condition := range job.Status.Conditions {
+			reason = append(reason, condition.Reason)
 		}
 	}
 
-	ctxWatch, cancelWatch := context.WithTimeout(context.Background(), timeout*time.Second)
-	defer cancelWatch()
-	for EOW := false; !EOW; {
+	return dipper.Message{
+		Payload: payload,
+		Labels: map[string]string{
+			"status": jobStatus,
+			"reason": strings.Join(reason, "\n"),
+		},
+	}
+}
+
+// watchJob waits for the job to finish, returns the finished job, or nil if the context is done before that.
+func watchJob(ctxWatch context.Context, m *dipper.Message, nameSpace string, jobName string) *batchv1.Job {
+	for {
 		var job *batchv1.Job
-		k8client := prepareKubeConfig(m)
-		jobclient := k8client.BatchV1().Jobs(nameSpace)
+		jobclient := newKubeClient(m).BatchV1().Jobs(nameSpace)
 
 		watchOption := metav1.ListOptions{
 			FieldSelector: "metadata.name==" + jobName,
@@ -254,9 +290,7 @@ func waitForJob(m *dipper.Message) {
 		}()
 
 		if len(job.Status.Conditions) > 0 && job.Status.Active == 0 {
-			returnJobStatus(job)
-
-			break
+			return job
 		}
 
 		jobstatus, err := jobclient.Watch(ctxWatch, watchOption)
@@ -264,6 +298,10 @@ func waitForJob(m *dipper.Message) {
 			log.Panicf("[%s] unable to watch the job %+v", driver.Service, err)
 		}
 
+		var (
+			finished *batchv1.Job
+			EOW      bool
+		)
 		func() {
 			defer jobstatus.Stop()
 
@@ -292,7 +330,7 @@ func waitForJob(m *dipper.Message) {
 						job := evt.Object.(*batchv1.Job)
 						log.Debugf("[%s] receiving a event when watching for job [%s] %s: %+v", driver.Service, jobName, evt.Type, job.Status)
 						if len(job.Status.Conditions) > 0 && job.Status.Active == 0 {
-							returnJobStatus(job)
+							finished = job
 							EOW = true
 
 							break loop
@@ -301,6 +339,10 @@ func waitForJob(m *dipper.Message) {
 				}
 			}
 		}()
+
+		if EOW {
+			return finished
+		}
 	}
 }
 
@@ -328,13 +370,24 @@ func getExistingJob(jobSpec *batchv1.Job, jobclient batchv1client.JobInterface)
 }
 
 func createJob(m *dipper.Message) {
-	k8client := prepareKubeConfig(m)
+	k8client := newKubeClient(m)
 
 	nameSpace, ok := dipper.GetMapDataStr(m.Payload, "namespace")
 	if !ok {
 		nameSpace = DefaultNamespace
 	}
 
+	jobResult := launchJob(m, nameSpace, k8client)
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{
+			"metadata": jobResult.ObjectMeta,
+			"status":   jobResult.Status,
+		},
+	}
+}
+
+// launchJob creates the job, or reuses the active job with the same unique identifier.
+func launchJob(m *dipper.Message, nameSpace string, k8client kubernetes.Interface) *batchv1.Job {
 	job := constructJob(m, nameSpace, k8client)
 	jobclient := k8client.BatchV1().Jobs(nameSpace)
 	jobResult := getExistingJob(&job, jobclient)
@@ -347,15 +400,10 @@ func createJob(m *dipper.Message) {
 		}
 	}
 
-	m.Reply <- dipper.Message{
-		Payload: map[string]interface{}{
-			"metadata": jobResult.ObjectMeta,
-			"status":   jobResult.Status,
-		},
-	}
+	return jobResult
 }
 
-func constructJob(m *dipper.Message, namespace string, client *kubernetes.Clientset) batchv1.Job {
+func constructJob(m *dipper.Message, namespace string, client kubernetes.Interface) batchv1.Job {
 	job := batchv1.Job{}
 
 	if fromCronJob, ok := dipper.GetMapDataStr(m.Payload, "fromCronJob"); ok {
@@ -482,65 +530,3 @@ func recycleDeployment(m *dipper.Message) {
 	log.Infof("[%s] deployment recycled %s.%s", driver.Service, nameSpace, rsName)
 	m.Reply <- dipper.Message{}
 }
-
-func prepareKubeConfig(m *dipper.Message) *kubernetes.Clientset {
-	if log == nil {
-		log = driver.GetLogger()
-	}
-	m = dipper.DeserializePayload(m)
-
-	source, ok := dipper.GetMapData(m.Payload, "source")
-	if !ok {
-		log.Panicf("[%s] source is missing in parameters", driver.Service)
-	}
-	stype, ok := dipper.GetMapDataStr(source, "type")
-	if !ok {
-		log.Panicf("[%s] source type is missing in parameters", driver.Service)
-	}
-	log.Debugf("[%s] fetching k8config from source", driver.Service)
-	var kubeConfig *rest.Config
-	switch stype {
-	case "gcloud-gke":
-		kubeConfig = getGKEConfig(source.(map[string]interface{}))
-	case "local":
-		kubeConfig, err = rest.InClusterConfig()
-		if err != nil {
-			log.Panicf("[%s] unable to load default account for kubernetes %+v", driver.Service, err)
-		}
-	default:
-		log.Panicf("[%s] unsupported kubernetes source type: %s", driver.Service, stype)
-	}
-	if kubeConfig == nil {
-		log.Panicf("[%s] unable to get kubeconfig", driver.Service)
-	}
-
-	k8client, err := kubernetes.NewForConfig(kubeConfig)
-	if err != nil {
-		log.Panicf("[%s] unable to create k8 client", driver.Service)
-	}
-
-	return k8client
-}
-
-func getGKEConfig(cfg map[string]interface{}) *rest.Config {
-	retbytes, err := driver.Call("driver:gcloud-gke", "getKubeCfg", cfg)
-	if err != nil {
-		log.Panicf("[%s] failed call gcloud to get kubeconfig %+v", driver.Service, err)
-	}
-
-	ret := dipper.DeserializeContent(retbytes)
-
-	host, _ := dipper.GetMapDataStr(ret, "Host")
-	token, _ := dipper.GetMapDataStr(ret, "Token")
-	cacert, _ := dipper.GetMapDataStr(ret, "CACert")
-
-	cadata, _ := base64.StdEncoding.DecodeString(cacert)
-
-	k8cfg := &rest.Config{
-		Host:        host,
-		BearerToken: token,
-	}
-	k8cfg.CAData = cadata
-
-	return k8cfg
-}
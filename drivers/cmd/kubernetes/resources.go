@@ -0,0 +1,312 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ghodss/yaml"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// FieldManager is the name of the field manager recorded in the resources changed by the driver.
+const FieldManager = "honeydipper"
+
+// resourceClient operates on arbitrary kinds of resources using a dynamic client.
+type resourceClient struct {
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+}
+
+// replace the func variable with fake clients during testing.
+var newResourceClient = func(m *dipper.Message) *resourceClient {
+	c := getClusterClients(m)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.resources != nil {
+		return c.resources
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(c.config)
+	if err != nil {
+		log.Panicf("[%s] unable to create dynamic client: %+v", driver.Service, err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(c.config)
+	if err != nil {
+		log.Panicf("[%s] unable to create discovery client: %+v", driver.Service, err)
+	}
+	c.resources = &resourceClient{
+		dynamic: dynamicClient,
+		mapper:  restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+	}
+
+	return c.resources
+}
+
+// mappingFor finds the resource and its scope for the apiVersion and kind.
+func (c *resourceClient) mappingFor(apiVersion string, kind string) *meta.RESTMapping {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		log.Panicf("[%s] invalid apiVersion %s: %+v", driver.Service, apiVersion, err)
+	}
+	mapping, err := c.mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		log.Panicf("[%s] unknown resource %s %s: %+v", driver.Service, apiVersion, kind, err)
+	}
+
+	return mapping
+}
+
+// resourceFor finds the resource for the apiVersion and kind, returns the client for the namespace if the
+// resource is namespaced, and whether it is namespaced.
+func (c *resourceClient) resourceFor(apiVersion string, kind string, namespace string) (dynamic.ResourceInterface, bool) {
+	mapping := c.mappingFor(apiVersion, kind)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return c.dynamic.Resource(mapping.Resource).Namespace(namespace), true
+	}
+
+	return c.dynamic.Resource(mapping.Resource), false
+}
+
+// getResourceTarget finds the resource using the apiVersion, kind and namespace in the parameters.
+func getResourceTarget(m *dipper.Message) dynamic.ResourceInterface {
+	client := newResourceClient(m)
+	apiVersion, ok := dipper.GetMapDataStr(m.Payload, "apiVersion")
+	if !ok {
+		apiVersion = "v1"
+	}
+	kind := dipper.MustGetMapDataStr(m.Payload, "kind")
+	nameSpace, ok := dipper.GetMapDataStr(m.Payload, "namespace")
+	if !ok {
+		nameSpace = DefaultNamespace
+	}
+	resource, _ := client.resourceFor(apiVersion, kind, nameSpace)
+
+	return resource
+}
+
+// getDryRun returns the dryRun option for the changes if dryRun is set in the parameters.
+func getDryRun(m *dipper.Message) []string {
+	if dryRun, _ := dipper.GetMapDataBool(m.Payload, "dryRun"); dryRun {
+		return []string{metav1.DryRunAll}
+	}
+
+	return nil
+}
+
+// getManifest reads the resource manifest from the parameters, either as a map or as a yaml string.
+func getManifest(m *dipper.Message) *unstructured.Unstructured {
+	source := dipper.MustGetMapData(m.Payload, "manifest")
+	obj := &unstructured.Unstructured{}
+	if str, ok := source.(string); ok {
+		if err := yaml.Unmarshal([]byte(str), &obj.Object); err != nil {
+			log.Panicf("[%s] invalid manifest %+v", driver.Service, err)
+		}
+	} else {
+		content, ok := source.(map[string]interface{})
+		if !ok {
+			log.Panicf("[%s] manifest should be a map or a yaml string", driver.Service)
+		}
+		obj.Object = content
+	}
+	if obj.GetKind() == "" || obj.GetAPIVersion() == "" || obj.GetName() == "" {
+		log.Panicf("[%s] apiVersion, kind and metadata.name are required in manifest", driver.Service)
+	}
+
+	return obj
+}
+
+func applyResource(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	client := newResourceClient(m)
+	obj := getManifest(m)
+	nameSpace := obj.GetNamespace()
+	if nameSpace == "" {
+		if nameSpace, _ = dipper.GetMapDataStr(m.Payload, "namespace"); nameSpace == "" {
+			nameSpace = DefaultNamespace
+		}
+	}
+	resource, namespaced := client.resourceFor(obj.GetAPIVersion(), obj.GetKind(), nameSpace)
+	if namespaced {
+		obj.SetNamespace(nameSpace)
+	}
+
+	content, err := json.Marshal(obj.Object)
+	if err != nil {
+		log.Panicf("[%s] unable to marshal manifest %+v", driver.Service, err)
+	}
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+
+	var result *unstructured.Unstructured
+	if serverSide, _ := dipper.GetMapDataBool(m.Payload, "serverSide"); serverSide {
+		force, _ := dipper.GetMapDataBool(m.Payload, "force")
+		result, err = resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, content, metav1.PatchOptions{
+			FieldManager: FieldManager,
+			Force:        &force,
+			DryRun:       getDryRun(m),
+		})
+	} else {
+		result, err = resource.Create(ctx, obj, metav1.CreateOptions{FieldManager: FieldManager, DryRun: getDryRun(m)})
+		if errors.IsAlreadyExists(err) {
+			result, err = resource.Patch(ctx, obj.GetName(), types.MergePatchType, content, metav1.PatchOptions{
+				FieldManager: FieldManager,
+				DryRun:       getDryRun(m),
+			})
+		}
+	}
+	if err != nil {
+		log.Panicf("[%s] failed to apply %s %s: %+v", driver.Service, obj.GetKind(), obj.GetName(), err)
+	}
+
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{"resource": result.Object},
+	}
+}
+
+func getResource(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	resource := getResourceTarget(m)
+	name := dipper.MustGetMapDataStr(m.Payload, "name")
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	result, err := resource.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		log.Panicf("[%s] unable to get %s: %+v", driver.Service, name, err)
+	}
+
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{"resource": result.Object},
+	}
+}
+
+func listResources(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	resource := getResourceTarget(m)
+	opts := metav1.ListOptions{}
+	opts.LabelSelector, _ = dipper.GetMapDataStr(m.Payload, "labelSelector")
+	opts.FieldSelector, _ = dipper.GetMapDataStr(m.Payload, "fieldSelector")
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	result, err := resource.List(ctx, opts)
+	if err != nil {
+		log.Panicf("[%s] unable to list resources: %+v", driver.Service, err)
+	}
+
+	items := make([]interface{}, len(result.Items))
+	for i, item := range result.Items {
+		items[i] = item.Object
+	}
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{"items": items},
+	}
+}
+
+func patchResource(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	resource := getResourceTarget(m)
+	name := dipper.MustGetMapDataStr(m.Payload, "name")
+
+	patchType := types.MergePatchType
+	if pt, ok := dipper.GetMapDataStr(m.Payload, "patchType"); ok {
+		switch pt {
+		case "merge":
+		case "strategic":
+			patchType = types.StrategicMergePatchType
+		case "json":
+			patchType = types.JSONPatchType
+		default:
+			log.Panicf("[%s] unsupported patchType %s, should be one of merge, strategic or json", driver.Service, pt)
+		}
+	}
+
+	var (
+		content []byte
+		result  *unstructured.Unstructured
+		err     error
+	)
+	switch patch := dipper.MustGetMapData(m.Payload, "patch").(type) {
+	case string:
+		content = []byte(patch)
+	default:
+		content, err = json.Marshal(patch)
+		if err != nil {
+			log.Panicf("[%s] unable to marshal patch %+v", driver.Service, err)
+		}
+	}
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	result, err = resource.Patch(ctx, name, patchType, content, metav1.PatchOptions{FieldManager: FieldManager, DryRun: getDryRun(m)})
+	if err != nil {
+		log.Panicf("[%s] failed to patch %s: %+v", driver.Service, name, err)
+	}
+
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{"resource": result.Object},
+	}
+}
+
+func deleteResource(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	resource := getResourceTarget(m)
+	name := dipper.MustGetMapDataStr(m.Payload, "name")
+
+	propagation := metav1.DeletePropagationBackground
+	if policy, ok := dipper.GetMapDataStr(m.Payload, "propagationPolicy"); ok {
+		propagation = metav1.DeletionPropagation(policy)
+	}
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	err := resource.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation, DryRun: getDryRun(m)})
+	if err != nil {
+		log.Panicf("[%s] failed to delete %s: %+v", driver.Service, name, err)
+	}
+
+	m.Reply <- dipper.Message{}
+}
+
+func scaleResource(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	resource := getResourceTarget(m)
+	name := dipper.MustGetMapDataStr(m.Payload, "name")
+	replicas, err := strconv.Atoi(fmt.Sprint(dipper.MustGetMapData(m.Payload, "replicas")))
+	if err != nil || replicas < 0 {
+		log.Panicf("[%s] replicas should be a non-negative integer", driver.Service)
+	}
+
+	content := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	result, err := resource.Patch(ctx, name, types.MergePatchType, content, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		DryRun:       getDryRun(m),
+	}, "scale")
+	if err != nil {
+		log.Panicf("[%s] failed to scale %s: %+v", driver.Service, name, err)
+	}
+	log.Infof("[%s] %s scaled to %d", driver.Service, name, replicas)
+
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{"resource": result.Object},
+	}
+}
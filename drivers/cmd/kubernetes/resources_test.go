@@ -0,0 +1,181 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	configMapsGVR  = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	nodesGVR       = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+)
+
+func newTestObject(apiVersion, kind, namespace, name string, labels map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name, "labels": labels}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   metadata,
+	}}
+}
+
+// useFakeResourceClient replaces the resource client with a fake dynamic client loaded with the objects.
+func useFakeResourceClient(t *testing.T, objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, meta.RESTScopeRoot)
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		deploymentsGVR: "DeploymentList",
+		configMapsGVR:  "ConfigMapList",
+		nodesGVR:       "NodeList",
+	}, objects...)
+
+	orig := newResourceClient
+	newResourceClient = func(*dipper.Message) *resourceClient {
+		return &resourceClient{dynamic: client, mapper: mapper}
+	}
+	t.Cleanup(func() { newResourceClient = orig })
+
+	return client
+}
+
+func callCommand(f func(*dipper.Message), payload map[string]interface{}) dipper.Message {
+	m := &dipper.Message{Payload: payload, Reply: make(chan dipper.Message, 1)}
+	f(m)
+
+	return <-m.Reply
+}
+
+func TestApplyResource(t *testing.T) {
+	client := useFakeResourceClient(t, newTestObject("v1", "ConfigMap", "default", "existing", nil))
+
+	ret := callCommand(applyResource, map[string]interface{}{
+		"manifest": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: created\ndata:\n  key: val\n",
+	})
+	assert.Equal(t, "default", dipper.MustGetMapDataStr(ret.Payload, "resource.metadata.namespace"), "should use default namespace")
+	assert.Equal(t, "val", dipper.MustGetMapDataStr(ret.Payload, "resource.data.key"))
+
+	ret = callCommand(applyResource, map[string]interface{}{
+		"manifest": map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "existing"},
+			"data":       map[string]interface{}{"key": "updated"},
+		},
+	})
+	assert.Equal(t, "updated", dipper.MustGetMapDataStr(ret.Payload, "resource.data.key"), "should update the existing resource")
+	actions := client.Actions()
+	assert.Equal(t, "patch", actions[len(actions)-1].GetVerb(), "should patch the existing resource")
+
+	assert.Panics(t, func() {
+		callCommand(applyResource, map[string]interface{}{"manifest": map[string]interface{}{"kind": "ConfigMap"}})
+	}, "should require apiVersion, kind and name")
+	assert.Panics(t, func() {
+		callCommand(applyResource, map[string]interface{}{"manifest": "apiVersion: v1\nkind: Unknown\nmetadata:\n  name: test\n"})
+	}, "should fail on unknown kinds")
+}
+
+func TestGetListResources(t *testing.T) {
+	useFakeResourceClient(t,
+		newTestObject("apps/v1", "Deployment", "web", "frontend", map[string]interface{}{"tier": "web"}),
+		newTestObject("apps/v1", "Deployment", "web", "backend", map[string]interface{}{"tier": "api"}),
+		newTestObject("v1", "Node", "", "node1", nil),
+	)
+
+	ret := callCommand(getResource, map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "namespace": "web", "name": "frontend"})
+	assert.Equal(t, "frontend", dipper.MustGetMapDataStr(ret.Payload, "resource.metadata.name"))
+
+	ret = callCommand(getResource, map[string]interface{}{"kind": "Node", "name": "node1", "namespace": "ignored"})
+	assert.Equal(t, "node1", dipper.MustGetMapDataStr(ret.Payload, "resource.metadata.name"), "should get cluster scoped resources")
+
+	ret = callCommand(listResources, map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "namespace": "web", "labelSelector": "tier=api"})
+	items := dipper.MustGetMapData(ret.Payload, "items").([]interface{})
+	assert.Len(t, items, 1, "should filter with the label selector")
+	assert.Equal(t, "backend", dipper.MustGetMapDataStr(items[0], "metadata.name"))
+
+	assert.Panics(t, func() {
+		callCommand(getResource, map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "namespace": "web", "name": "missing"})
+	})
+}
+
+func TestPatchResource(t *testing.T) {
+	useFakeResourceClient(t, newTestObject("v1", "Node", "", "node1", nil))
+
+	ret := callCommand(patchResource, map[string]interface{}{
+		"kind":  "Node",
+		"name":  "node1",
+		"patch": map[string]interface{}{"spec": map[string]interface{}{"unschedulable": true}},
+	})
+	unschedulable, _ := dipper.GetMapDataBool(ret.Payload, "resource.spec.unschedulable")
+	assert.True(t, unschedulable, "should merge the patch")
+
+	ret = callCommand(patchResource, map[string]interface{}{
+		"kind":      "Node",
+		"name":      "node1",
+		"patchType": "json",
+		"patch":     `[{"op": "add", "path": "/metadata/labels", "value": {"cordoned": "true"}}]`,
+	})
+	assert.Equal(t, "true", dipper.MustGetMapDataStr(ret.Payload, "resource.metadata.labels.cordoned"), "should apply json patch")
+
+	assert.Panics(t, func() {
+		callCommand(patchResource, map[string]interface{}{"kind": "Node", "name": "node1", "patchType": "unknown", "patch": "{}"})
+	})
+}
+
+func TestDeleteResource(t *testing.T) {
+	client := useFakeResourceClient(t, newTestObject("v1", "ConfigMap", "default", "test", nil))
+
+	callCommand(deleteResource, map[string]interface{}{"kind": "ConfigMap", "name": "test", "propagationPolicy": "Foreground"})
+	assert.Equal(t, "delete", client.Actions()[0].GetVerb())
+	_, err := client.Resource(configMapsGVR).Namespace("default").Get(context.Background(), "test", metav1.GetOptions{})
+	assert.NotNil(t, err, "should delete the resource")
+	assert.Panics(t, func() {
+		callCommand(deleteResource, map[string]interface{}{"kind": "ConfigMap", "name": "test"})
+	}, "should fail if the resource doesn't exist")
+}
+
+func TestGetDryRun(t *testing.T) {
+	assert.Nil(t, getDryRun(&dipper.Message{Payload: map[string]interface{}{}}))
+	assert.Equal(t, []string{metav1.DryRunAll}, getDryRun(&dipper.Message{Payload: map[string]interface{}{"dryRun": true}}))
+	assert.Equal(t, []string{metav1.DryRunAll}, getDryRun(&dipper.Message{Payload: map[string]interface{}{"dryRun": "true"}}))
+}
+
+func TestScaleResource(t *testing.T) {
+	client := useFakeResourceClient(t, newTestObject("apps/v1", "Deployment", "default", "web", nil))
+
+	ret := callCommand(scaleResource, map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web", "replicas": 3})
+	assert.NotNil(t, ret.Payload)
+	action := client.Actions()[0].(k8stesting.PatchAction)
+	assert.Equal(t, "scale", action.GetSubresource(), "should patch the scale subresource")
+	assert.JSONEq(t, `{"spec":{"replicas":3}}`, string(action.GetPatch()))
+
+	assert.Panics(t, func() {
+		callCommand(scaleResource, map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web", "replicas": "-1"})
+	})
+}
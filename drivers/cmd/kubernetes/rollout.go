@@ -0,0 +1,445 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// KindDeployment is the kind of the deployment resources.
+	KindDeployment = "Deployment"
+	// KindStatefulSet is the kind of the statefulset resources.
+	KindStatefulSet = "StatefulSet"
+	// KindDaemonSet is the kind of the daemonset resources.
+	KindDaemonSet = "DaemonSet"
+
+	// DefaultRolloutWaitTimeout is the default timeout in seconds for waiting a rollout to be complete.
+	DefaultRolloutWaitTimeout time.Duration = 600
+
+	// AnnotationRevision is the annotation recording the revision of deployments and replicasets.
+	AnnotationRevision = "deployment.kubernetes.io/revision"
+
+	// AnnotationRestartedAt is the annotation used for restarting the pods, same as kubectl.
+	AnnotationRestartedAt = "kubectl.kubernetes.io/restartedAt"
+)
+
+// RolloutPollInterval is the interval for checking the rollout status.
+var RolloutPollInterval = 2 * time.Second
+
+// replace the func variable with fake clientset during testing.
+var newKubeClient = func(m *dipper.Message) kubernetes.Interface {
+	return prepareKubeConfig(m)
+}
+
+// rolloutTarget is a deployment, statefulset or daemonset being rolled out.
+type rolloutTarget struct {
+	client    kubernetes.Interface
+	kind      string
+	name      string
+	namespace string
+}
+
+// getRolloutTarget reads the kind, name and namespace of the workload from the parameters.
+func getRolloutTarget(m *dipper.Message) *rolloutTarget {
+	t := &rolloutTarget{
+		client: newKubeClient(m),
+		kind:   KindDeployment,
+		name:   dipper.MustGetMapDataStr(m.Payload, "name"),
+	}
+	if kind, ok := dipper.GetMapDataStr(m.Payload, "kind"); ok {
+		switch strings.ToLower(kind) {
+		case "deployment":
+		case "statefulset":
+			t.kind = KindStatefulSet
+		case "daemonset":
+			t.kind = KindDaemonSet
+		default:
+			log.Panicf("[%s] unsupported kind %s, should be one of Deployment, StatefulSet or DaemonSet", driver.Service, kind)
+		}
+	}
+	var ok bool
+	if t.namespace, ok = dipper.GetMapDataStr(m.Payload, "namespace"); !ok {
+		t.namespace = DefaultNamespace
+	}
+
+	return t
+}
+
+// patch sends the patch to the workload.
+func (t *rolloutTarget) patch(ctx context.Context, patchType types.PatchType, content []byte) {
+	var err error
+	switch t.kind {
+	case KindDeployment:
+		_, err = t.client.AppsV1().Deployments(t.namespace).Patch(ctx, t.name, patchType, content, metav1.PatchOptions{FieldManager: FieldManager})
+	case KindStatefulSet:
+		_, err = t.client.AppsV1().StatefulSets(t.namespace).Patch(ctx, t.name, patchType, content, metav1.PatchOptions{FieldManager: FieldManager})
+	case KindDaemonSet:
+		_, err = t.client.AppsV1().DaemonSets(t.namespace).Patch(ctx, t.name, patchType, content, metav1.PatchOptions{FieldManager: FieldManager})
+	}
+	if err != nil {
+		log.Panicf("[%s] failed to patch %s %s: %+v", driver.Service, t.kind, t.name, err)
+	}
+}
+
+// status checks the progress of the rollout, in the same way as kubectl rollout status.
+func (t *rolloutTarget) status(ctx context.Context) map[string]interface{} {
+	status := map[string]interface{}{
+		"kind":      t.kind,
+		"name":      t.name,
+		"namespace": t.namespace,
+		"done":      false,
+		"failed":    false,
+	}
+	setMessage := func(format string, args ...interface{}) {
+		status["message"] = fmt.Sprintf(format, args...)
+	}
+
+	switch t.kind {
+	case KindDeployment:
+		d, err := t.client.AppsV1().Deployments(t.namespace).Get(ctx, t.name, metav1.GetOptions{})
+		if err != nil {
+			log.Panicf("[%s] unable to get deployment %s: %+v", driver.Service, t.name, err)
+		}
+		status["revision"] = d.Annotations[AnnotationRevision]
+		status["replicas"] = d.Status.Replicas
+		status["updatedReplicas"] = d.Status.UpdatedReplicas
+		status["readyReplicas"] = d.Status.ReadyReplicas
+		status["availableReplicas"] = d.Status.AvailableReplicas
+
+		switch {
+		case d.Generation > d.Status.ObservedGeneration:
+			setMessage("waiting for deployment spec update to be observed")
+		case d.Spec.Paused:
+			status["failed"] = true
+			setMessage("deployment %s is paused", t.name)
+		case hasProgressDeadlineExceeded(d):
+			status["failed"] = true
+			setMessage("deployment %s exceeded its progress deadline", t.name)
+		case d.Spec.Replicas != nil && d.Status.UpdatedReplicas < *d.Spec.Replicas:
+			setMessage("%d out of %d new replicas have been updated", d.Status.UpdatedReplicas, *d.Spec.Replicas)
+		case d.Status.Replicas > d.Status.UpdatedReplicas:
+			setMessage("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+		case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+			setMessage("%d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+		default:
+			status["done"] = true
+			setMessage("deployment %s successfully rolled out", t.name)
+		}
+
+	case KindStatefulSet:
+		s, err := t.client.AppsV1().StatefulSets(t.namespace).Get(ctx, t.name, metav1.GetOptions{})
+		if err != nil {
+			log.Panicf("[%s] unable to get statefulset %s: %+v", driver.Service, t.name, err)
+		}
+		status["revision"] = s.Status.UpdateRevision
+		status["replicas"] = s.Status.Replicas
+		status["updatedReplicas"] = s.Status.UpdatedReplicas
+		status["readyReplicas"] = s.Status.ReadyReplicas
+		status["availableReplicas"] = s.Status.AvailableReplicas
+
+		var partition int32
+		if ru := s.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil {
+			partition = *ru.Partition
+		}
+		switch {
+		case s.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType:
+			status["failed"] = true
+			setMessage("rollout status is only available for %s strategy", appsv1.RollingUpdateStatefulSetStrategyType)
+		case s.Status.ObservedGeneration == 0 || s.Generation > s.Status.ObservedGeneration:
+			setMessage("waiting for statefulset spec update to be observed")
+		case s.Spec.Replicas != nil && s.Status.ReadyReplicas < *s.Spec.Replicas:
+			setMessage("waiting for %d pods to be ready", *s.Spec.Replicas-s.Status.ReadyReplicas)
+		case partition > 0 && s.Spec.Replicas != nil && s.Status.UpdatedReplicas < *s.Spec.Replicas-partition:
+			setMessage("waiting for partitioned rollout to finish: %d out of %d new pods have been updated", s.Status.UpdatedReplicas, *s.Spec.Replicas-partition)
+		case partition == 0 && s.Status.UpdateRevision != s.Status.CurrentRevision:
+			setMessage("waiting for statefulset rolling update to complete %d pods at revision %s", s.Status.UpdatedReplicas, s.Status.UpdateRevision)
+		default:
+			status["done"] = true
+			setMessage("statefulset %s successfully rolled out", t.name)
+		}
+
+	case KindDaemonSet:
+		d, err := t.client.AppsV1().DaemonSets(t.namespace).Get(ctx, t.name, metav1.GetOptions{})
+		if err != nil {
+			log.Panicf("[%s] unable to get daemonset %s: %+v", driver.Service, t.name, err)
+		}
+		status["replicas"] = d.Status.DesiredNumberScheduled
+		status["updatedReplicas"] = d.Status.UpdatedNumberScheduled
+		status["readyReplicas"] = d.Status.NumberReady
+		status["availableReplicas"] = d.Status.NumberAvailable
+
+		switch {
+		case d.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType:
+			status["failed"] = true
+			setMessage("rollout status is only available for %s strategy", appsv1.RollingUpdateDaemonSetStrategyType)
+		case d.Generation > d.Status.ObservedGeneration:
+			setMessage("waiting for daemonset spec update to be observed")
+		case d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled:
+			setMessage("%d out of %d new pods have been updated", d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled)
+		case d.Status.NumberAvailable < d.Status.DesiredNumberScheduled:
+			setMessage("%d of %d updated pods are available", d.Status.NumberAvailable, d.Status.DesiredNumberScheduled)
+		default:
+			status["done"] = true
+			setMessage("daemonset %s successfully rolled out", t.name)
+		}
+	}
+
+	return status
+}
+
+func hasProgressDeadlineExceeded(d *appsv1.Deployment) bool {
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func rolloutStatus(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	target := getRolloutTarget(m)
+
+	timeout := DefaultRolloutWaitTimeout
+	if timeoutStr, ok := m.Labels["timeout"]; ok {
+		timeoutInt, _ := strconv.Atoi(timeoutStr)
+		timeout = time.Duration(timeoutInt)
+	}
+	wait := true
+	if w, ok := dipper.GetMapDataBool(m.Payload, "wait"); ok {
+		wait = w
+	}
+
+	ctxWait, cancelWait := context.WithTimeout(context.Background(), timeout*time.Second)
+	defer cancelWait()
+	var status map[string]interface{}
+	for {
+		func() {
+			ctx, cancel := driver.GetContext()
+			defer cancel()
+			status = target.status(ctx)
+		}()
+		if status["done"].(bool) || status["failed"].(bool) || !wait {
+			break
+		}
+		log.Debugf("[%s] waiting for rollout of %s %s: %s", driver.Service, target.kind, target.name, status["message"])
+
+		select {
+		case <-ctxWait.Done():
+			status["failed"] = true
+			status["message"] = fmt.Sprintf("timed out waiting for the rollout: %s", status["message"])
+		case <-time.After(RolloutPollInterval):
+			continue
+		}
+
+		break
+	}
+
+	ret := dipper.Message{
+		Payload: map[string]interface{}{"status": status},
+	}
+	if status["failed"].(bool) {
+		ret.Labels = map[string]string{
+			"status": StatusFailure,
+			"reason": status["message"].(string),
+		}
+	}
+	m.Reply <- ret
+}
+
+func rolloutUndo(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	target := getRolloutTarget(m)
+	var toRevision int64
+	if r, ok := dipper.GetMapData(m.Payload, "toRevision"); ok {
+		var err error
+		if toRevision, err = strconv.ParseInt(fmt.Sprint(r), 10, 64); err != nil || toRevision < 0 {
+			log.Panicf("[%s] toRevision should be a non-negative integer", driver.Service)
+		}
+	}
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	var revision int64
+	if target.kind == KindDeployment {
+		revision = target.undoDeployment(ctx, toRevision)
+	} else {
+		revision = target.undoControllerRevision(ctx, toRevision)
+	}
+	log.Infof("[%s] %s %s.%s rolled back to revision %d", driver.Service, target.kind, target.namespace, target.name, revision)
+
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{"revision": revision},
+	}
+}
+
+// findRevision picks the requested revision, or the one before the current revision when not specified.
+func findRevision(revisions map[int64]interface{}, toRevision int64) (int64, interface{}) {
+	if toRevision > 0 {
+		return toRevision, revisions[toRevision]
+	}
+
+	var current, previous int64
+	for r := range revisions {
+		if r > current {
+			current, previous = r, current
+		} else if r > previous {
+			previous = r
+		}
+	}
+
+	return previous, revisions[previous]
+}
+
+// undoDeployment rolls the pod template back to the one in the replicaset of the revision.
+func (t *rolloutTarget) undoDeployment(ctx context.Context, toRevision int64) int64 {
+	deployments := t.client.AppsV1().Deployments(t.namespace)
+	d, err := deployments.Get(ctx, t.name, metav1.GetOptions{})
+	if err != nil {
+		log.Panicf("[%s] unable to get deployment %s: %+v", driver.Service, t.name, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		log.Panicf("[%s] invalid selector in deployment %s: %+v", driver.Service, t.name, err)
+	}
+	rsList, err := t.client.AppsV1().ReplicaSets(t.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		log.Panicf("[%s] unable to list replicasets for deployment %s: %+v", driver.Service, t.name, err)
+	}
+
+	revisions := map[int64]interface{}{}
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		if !metav1.IsControlledBy(rs, d) {
+			continue
+		}
+		if r, err := strconv.ParseInt(rs.Annotations[AnnotationRevision], 10, 64); err == nil {
+			revisions[r] = rs
+		}
+	}
+	revision, found := findRevision(revisions, toRevision)
+	if found == nil {
+		log.Panicf("[%s] unable to find the revision to roll back for deployment %s", driver.Service, t.name)
+	}
+
+	template := found.(*appsv1.ReplicaSet).Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	d.Spec.Template = *template
+	if _, err = deployments.Update(ctx, d, metav1.UpdateOptions{FieldManager: FieldManager}); err != nil {
+		log.Panicf("[%s] failed to roll back deployment %s: %+v", driver.Service, t.name, err)
+	}
+
+	return revision
+}
+
+// undoControllerRevision rolls the statefulset or daemonset back by patching the saved controller revision.
+func (t *rolloutTarget) undoControllerRevision(ctx context.Context, toRevision int64) int64 {
+	var (
+		owner    metav1.Object
+		selector *metav1.LabelSelector
+	)
+	switch t.kind {
+	case KindStatefulSet:
+		s, err := t.client.AppsV1().StatefulSets(t.namespace).Get(ctx, t.name, metav1.GetOptions{})
+		if err != nil {
+			log.Panicf("[%s] unable to get statefulset %s: %+v", driver.Service, t.name, err)
+		}
+		owner, selector = s, s.Spec.Selector
+	case KindDaemonSet:
+		d, err := t.client.AppsV1().DaemonSets(t.namespace).Get(ctx, t.name, metav1.GetOptions{})
+		if err != nil {
+			log.Panicf("[%s] unable to get daemonset %s: %+v", driver.Service, t.name, err)
+		}
+		owner, selector = d, d.Spec.Selector
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		log.Panicf("[%s] invalid selector in %s %s: %+v", driver.Service, t.kind, t.name, err)
+	}
+	history, err := t.client.AppsV1().ControllerRevisions(t.namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		log.Panicf("[%s] unable to list revisions for %s %s: %+v", driver.Service, t.kind, t.name, err)
+	}
+
+	revisions := map[int64]interface{}{}
+	for i := range history.Items {
+		if metav1.IsControlledBy(&history.Items[i], owner) {
+			revisions[history.Items[i].Revision] = &history.Items[i]
+		}
+	}
+	revision, found := findRevision(revisions, toRevision)
+	if found == nil {
+		log.Panicf("[%s] unable to find the revision to roll back for %s %s", driver.Service, t.kind, t.name)
+	}
+
+	t.patch(ctx, types.StrategicMergePatchType, found.(*appsv1.ControllerRevision).Data.Raw)
+
+	return revision
+}
+
+func setPaused(m *dipper.Message, paused bool) {
+	m = dipper.DeserializePayload(m)
+	target := getRolloutTarget(m)
+	if target.kind != KindDeployment {
+		log.Panicf("[%s] pausing and resuming are only supported for deployments", driver.Service)
+	}
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	target.patch(ctx, types.MergePatchType, []byte(fmt.Sprintf(`{"spec":{"paused":%t}}`, paused)))
+	log.Infof("[%s] deployment %s.%s paused: %t", driver.Service, target.namespace, target.name, paused)
+
+	m.Reply <- dipper.Message{}
+}
+
+func rolloutPause(m *dipper.Message) {
+	setPaused(m, true)
+}
+
+func rolloutResume(m *dipper.Message) {
+	setPaused(m, false)
+}
+
+func restartWorkload(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	target := getRolloutTarget(m)
+	restartedAt := time.Now().Format(time.RFC3339)
+	content, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{AnnotationRestartedAt: restartedAt},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Panicf("[%s] unable to marshal patch %+v", driver.Service, err)
+	}
+
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	target.patch(ctx, types.StrategicMergePatchType, content)
+	log.Infof("[%s] %s %s.%s restarted", driver.Service, target.kind, target.namespace, target.name)
+
+	m.Reply <- dipper.Message{
+		Payload: map[string]interface{}{"restartedAt": restartedAt},
+	}
+}
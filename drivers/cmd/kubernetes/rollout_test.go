@@ -0,0 +1,253 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// useFakeKubeClient replaces the clientset with a fake one loaded with the objects.
+func useFakeKubeClient(t *testing.T, objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	orig := newKubeClient
+	newKubeClient = func(*dipper.Message) kubernetes.Interface { return client }
+	t.Cleanup(func() { newKubeClient = orig })
+
+	return client
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func newTestDeployment(generation int64, status appsv1.DeploymentStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			UID:         "web-uid",
+			Generation:  generation,
+			Annotations: map[string]string{AnnotationRevision: "3"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: int32Ptr(2),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:3"}}},
+			},
+		},
+		Status: status,
+	}
+}
+
+func TestDeploymentRolloutStatus(t *testing.T) {
+	cases := []struct {
+		deployment *appsv1.Deployment
+		done       bool
+		failed     bool
+		message    string
+	}{
+		{
+			newTestDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 1}),
+			false, false, "waiting for deployment spec update to be observed",
+		},
+		{
+			newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 1}),
+			false, false, "1 out of 2 new replicas have been updated",
+		},
+		{
+			newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 2}),
+			false, false, "1 old replicas are pending termination",
+		},
+		{
+			newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}),
+			false, false, "1 of 2 updated replicas are available",
+		},
+		{
+			newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"},
+			}}),
+			false, true, "deployment web exceeded its progress deadline",
+		},
+		{
+			newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			true, false, "deployment web successfully rolled out",
+		},
+	}
+
+	for _, c := range cases {
+		useFakeKubeClient(t, c.deployment)
+		target := getRolloutTarget(&dipper.Message{Payload: map[string]interface{}{"name": "web"}})
+		status := target.status(context.Background())
+		assert.Equal(t, c.done, status["done"], c.message)
+		assert.Equal(t, c.failed, status["failed"], c.message)
+		assert.Equal(t, c.message, status["message"])
+	}
+}
+
+func TestStatefulSetAndDaemonSetRolloutStatus(t *testing.T) {
+	useFakeKubeClient(t,
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", Generation: 1},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:       int32Ptr(3),
+				UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+			},
+			Status: appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 3, UpdatedReplicas: 1, CurrentRevision: "db-1", UpdateRevision: "db-2"},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "default", Generation: 1},
+			Spec:       appsv1.DaemonSetSpec{UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType}},
+			Status:     appsv1.DaemonSetStatus{ObservedGeneration: 1},
+		},
+	)
+
+	status := getRolloutTarget(&dipper.Message{Payload: map[string]interface{}{"name": "db", "kind": "statefulset"}}).status(context.Background())
+	assert.Equal(t, false, status["done"])
+	assert.Equal(t, "waiting for statefulset rolling update to complete 1 pods at revision db-2", status["message"])
+	assert.Equal(t, "db-2", status["revision"])
+
+	status = getRolloutTarget(&dipper.Message{Payload: map[string]interface{}{"name": "agent", "kind": "DaemonSet"}}).status(context.Background())
+	assert.Equal(t, true, status["failed"], "should fail for OnDelete strategy")
+
+	assert.Panics(t, func() {
+		getRolloutTarget(&dipper.Message{Payload: map[string]interface{}{"name": "job", "kind": "Job"}})
+	}, "should reject unsupported kinds")
+}
+
+func TestRolloutStatus(t *testing.T) {
+	client := useFakeKubeClient(t, newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 1}))
+	orig := RolloutPollInterval
+	RolloutPollInterval = 10 * time.Millisecond
+	defer func() { RolloutPollInterval = orig }()
+
+	ret := callCommand(rolloutStatus, map[string]interface{}{"name": "web", "wait": false})
+	assert.Equal(t, false, dipper.MustGetMapData(ret.Payload, "status.done"), "should return the status without waiting")
+	assert.Nil(t, ret.Labels)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		d := newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2})
+		_, err := client.AppsV1().Deployments("default").UpdateStatus(context.Background(), d, metav1.UpdateOptions{})
+		assert.Nil(t, err)
+	}()
+	ret = callCommand(rolloutStatus, map[string]interface{}{"name": "web"})
+	assert.Equal(t, true, dipper.MustGetMapData(ret.Payload, "status.done"), "should wait for the rollout")
+	assert.Equal(t, "3", dipper.MustGetMapData(ret.Payload, "status.revision"))
+
+	m := &dipper.Message{
+		Payload: map[string]interface{}{"name": "web"},
+		Labels:  map[string]string{"timeout": "0"},
+		Reply:   make(chan dipper.Message, 1),
+	}
+	_, _ = client.AppsV1().Deployments("default").UpdateStatus(context.Background(), newTestDeployment(1, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 1}), metav1.UpdateOptions{})
+	rolloutStatus(m)
+	ret = <-m.Reply
+	assert.Equal(t, StatusFailure, ret.Labels["status"], "should fail when timed out")
+	assert.Contains(t, ret.Labels["reason"], "timed out")
+}
+
+func TestRolloutUndo(t *testing.T) {
+	current := newTestDeployment(1, appsv1.DeploymentStatus{})
+	replicaSet := func(revision string, image string) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "web-" + revision,
+				Namespace:       "default",
+				Labels:          map[string]string{"app": "web"},
+				Annotations:     map[string]string{AnnotationRevision: revision},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(current, appsv1.SchemeGroupVersion.WithKind(KindDeployment))},
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: "hash-" + revision}},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: image}}},
+				},
+			},
+		}
+	}
+	client := useFakeKubeClient(t, current, replicaSet("1", "web:1"), replicaSet("2", "web:2"), replicaSet("3", "web:3"))
+
+	ret := callCommand(rolloutUndo, map[string]interface{}{"name": "web"})
+	assert.Equal(t, int64(2), ret.Payload.(map[string]interface{})["revision"], "should roll back to the previous revision")
+	d, _ := client.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	assert.Equal(t, "web:2", d.Spec.Template.Spec.Containers[0].Image)
+	assert.NotContains(t, d.Spec.Template.Labels, appsv1.DefaultDeploymentUniqueLabelKey, "should remove the pod-template-hash label")
+
+	ret = callCommand(rolloutUndo, map[string]interface{}{"name": "web", "toRevision": "1"})
+	assert.Equal(t, int64(1), ret.Payload.(map[string]interface{})["revision"])
+	d, _ = client.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	assert.Equal(t, "web:1", d.Spec.Template.Spec.Containers[0].Image)
+
+	assert.Panics(t, func() {
+		callCommand(rolloutUndo, map[string]interface{}{"name": "web", "toRevision": 9})
+	}, "should panic when the revision is not found")
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", UID: "db-uid"},
+		Spec: appsv1.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "db", Image: "db:2"}}}},
+		},
+	}
+	controllerRevision := func(revision int64, data string) *appsv1.ControllerRevision {
+		return &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "db-" + strconv.FormatInt(revision, 10),
+				Namespace:       "default",
+				Labels:          map[string]string{"app": "db"},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(sts, appsv1.SchemeGroupVersion.WithKind(KindStatefulSet))},
+			},
+			Revision: revision,
+			Data:     runtime.RawExtension{Raw: []byte(data)},
+		}
+	}
+	client = useFakeKubeClient(t, sts,
+		controllerRevision(1, `{"spec":{"template":{"spec":{"containers":[{"name":"db","image":"db:1"}]}}}}`),
+		controllerRevision(2, `{"spec":{"template":{"spec":{"containers":[{"name":"db","image":"db:2"}]}}}}`),
+	)
+	ret = callCommand(rolloutUndo, map[string]interface{}{"name": "db", "kind": "StatefulSet"})
+	assert.Equal(t, int64(1), ret.Payload.(map[string]interface{})["revision"])
+	s, _ := client.AppsV1().StatefulSets("default").Get(context.Background(), "db", metav1.GetOptions{})
+	assert.Equal(t, "db:1", s.Spec.Template.Spec.Containers[0].Image, "should apply the saved revision")
+}
+
+func TestRolloutPauseResumeAndRestart(t *testing.T) {
+	client := useFakeKubeClient(t, newTestDeployment(1, appsv1.DeploymentStatus{}), &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+	})
+
+	callCommand(rolloutPause, map[string]interface{}{"name": "web"})
+	d, _ := client.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	assert.True(t, d.Spec.Paused)
+	callCommand(rolloutResume, map[string]interface{}{"name": "web"})
+	d, _ = client.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	assert.False(t, d.Spec.Paused)
+	assert.Panics(t, func() {
+		callCommand(rolloutPause, map[string]interface{}{"name": "db", "kind": "StatefulSet"})
+	}, "should only pause deployments")
+
+	ret := callCommand(restartWorkload, map[string]interface{}{"name": "db", "kind": "StatefulSet"})
+	s, _ := client.AppsV1().StatefulSets("default").Get(context.Background(), "db", metav1.GetOptions{})
+	assert.Equal(t, ret.Payload.(map[string]interface{})["restartedAt"], s.Spec.Template.Annotations[AnnotationRestartedAt])
+}
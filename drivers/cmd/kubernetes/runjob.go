@@ -0,0 +1,237 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// JobPodPollInterval is the interval for looking for new pods of a running job.
+	JobPodPollInterval = 2 * time.Second
+
+	// JobLogFlushInterval is the interval for sending the collected log lines as a progress update.
+	JobLogFlushInterval = time.Second
+
+	// JobLogChunkLines is the max number of log lines sent in one progress update.
+	JobLogChunkLines = 100
+
+	// JobLogGracePeriod is the time to wait for the log streams to end after the job is finished.
+	JobLogGracePeriod = 5 * time.Second
+)
+
+// jobLogFollower follows the logs of the containers in the pods of a job, and sends them as progress updates.
+type jobLogFollower struct {
+	m         *dipper.Message
+	client    kubernetes.Interface
+	namespace string
+	job       string
+	following map[string]bool
+	wg        sync.WaitGroup
+}
+
+// run looks for the new pods and containers of the job until the polling context is done, the logs are
+// streamed until the streaming context is done.
+func (f *jobLogFollower) run(ctxPoll context.Context, ctxStream context.Context) {
+	for {
+		f.followNewContainers(ctxStream)
+
+		select {
+		case <-ctxPoll.Done():
+			return
+		case <-time.After(JobPodPollInterval):
+		}
+	}
+}
+
+// followNewContainers starts following the logs for the containers that are started.
+func (f *jobLogFollower) followNewContainers(ctx context.Context) {
+	defer dipper.SafeExitOnError("[%s] unable to follow the logs for job %s", driver.Service, f.job)
+
+	listCtx, cancel := driver.GetContext()
+	defer cancel()
+	pods, err := f.client.CoreV1().Pods(f.namespace).List(listCtx, metav1.ListOptions{LabelSelector: "job-name==" + f.job})
+	if err != nil {
+		log.Warningf("[%s] unable to list the pods for job %s: %+v", driver.Service, f.job, err)
+
+		return
+	}
+
+	for _, pod := range pods.Items {
+		for _, c := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			key := pod.Name + "/" + c.Name
+			if f.following[key] || (c.State.Running == nil && c.State.Terminated == nil) {
+				continue
+			}
+			f.following[key] = true
+			f.wg.Add(1)
+			go f.follow(ctx, pod.Name, c.Name)
+		}
+	}
+}
+
+// follow streams the logs of a container, sending the lines in chunks.
+func (f *jobLogFollower) follow(ctx context.Context, pod string, container string) {
+	defer f.wg.Done()
+	defer dipper.SafeExitOnError("[%s] stop following the logs for %s.%s", driver.Service, pod, container)
+
+	stream, err := f.client.CoreV1().Pods(f.namespace).GetLogs(pod, &corev1.PodLogOptions{Container: container, Follow: true}).Stream(ctx)
+	if err != nil {
+		log.Warningf("[%s] unable to follow the logs for the pod %s container %s: %+v", driver.Service, pod, container, err)
+
+		return
+	}
+	defer stream.Close()
+
+	lines := make(chan string)
+	go readLines(stream, lines)
+
+	var chunk []string
+	flush := func() {
+		if len(chunk) > 0 {
+			driver.Progress(f.m, map[string]interface{}{
+				"job":       f.job,
+				"pod":       pod,
+				"container": container,
+				"log":       strings.Join(chunk, "\n"),
+			})
+			chunk = nil
+		}
+	}
+	defer flush()
+
+	ticker := time.NewTicker(JobLogFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			chunk = append(chunk, line)
+			if len(chunk) >= JobLogChunkLines {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// readLines sends the lines read from the stream to the channel, and closes the channel at the end of the stream.  The
+// lines can be of any length, unlike using a bufio.Scanner with the default buffer.
+func readLines(stream io.Reader, lines chan<- string) {
+	defer close(lines)
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSuffix(line, "\n"); line != "" || err == nil {
+			lines <- line
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Debugf("[%s] stop reading the logs: %+v", driver.Service, err)
+			}
+
+			return
+		}
+	}
+}
+
+// getExitCodes collects the exit codes of the terminated containers in the pods of the job.
+func getExitCodes(client kubernetes.Interface, namespace string, job string) map[string]interface{} {
+	ctx, cancel := driver.GetContext()
+	defer cancel()
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name==" + job})
+	if err != nil {
+		log.Panicf("[%s] unable to find the pods for the job %s: %+v", driver.Service, job, err)
+	}
+
+	exitCodes := map[string]interface{}{}
+	for _, pod := range pods.Items {
+		codes := map[string]interface{}{}
+		for _, c := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if c.State.Terminated != nil {
+				codes[c.Name] = c.State.Terminated.ExitCode
+			}
+		}
+		exitCodes[pod.Name] = codes
+	}
+
+	return exitCodes
+}
+
+func runJob(m *dipper.Message) {
+	m = dipper.DeserializePayload(m)
+	k8client := newKubeClient(m)
+	nameSpace, ok := dipper.GetMapDataStr(m.Payload, "namespace")
+	if !ok {
+		nameSpace = DefaultNamespace
+	}
+
+	ctxWatch, cancelWatch := context.WithTimeout(context.Background(), getJobWaitTimeout(m)*time.Second)
+	defer cancelWatch()
+
+	job := launchJob(m, nameSpace, k8client)
+	log.Infof("[%s] running job %s.%s", driver.Service, nameSpace, job.Name)
+
+	follower := &jobLogFollower{
+		m:         m,
+		client:    k8client,
+		namespace: nameSpace,
+		job:       job.Name,
+		following: map[string]bool{},
+	}
+	ctxStream, cancelStream := context.WithCancel(ctxWatch)
+	defer cancelStream()
+	ctxPoll, cancelPoll := context.WithCancel(ctxStream)
+	defer cancelPoll()
+	pollDone := make(chan struct{})
+	go func() {
+		defer close(pollDone)
+		follower.run(ctxPoll, ctxStream)
+	}()
+
+	finished := watchJob(ctxWatch, m, nameSpace, job.Name)
+	cancelPoll()
+	<-pollDone
+	if finished == nil {
+		// leave the timeout to be handled by the command wrapper
+		return
+	}
+
+	// pick up the containers that finished too quickly to be found, then wait for the streams to end
+	follower.followNewContainers(ctxStream)
+	streamDone := make(chan struct{})
+	go func() {
+		follower.wg.Wait()
+		close(streamDone)
+	}()
+	select {
+	case <-streamDone:
+	case <-time.After(JobLogGracePeriod):
+		cancelStream()
+		<-streamDone
+	}
+
+	m.Reply <- jobStatusMessage(finished, map[string]interface{}{
+		"metadata":  finished.ObjectMeta,
+		"status":    finished.Status,
+		"exitCodes": getExitCodes(k8client, nameSpace, finished.Name),
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunJob(t *testing.T) {
+	useFakeKubeClient(t, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "maintenance-abc", Namespace: "default", Labels: map[string]string{"job-name": "maintenance"}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "main", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 3}}},
+			},
+		},
+	})
+	progress := make(chan *dipper.Message, 10)
+	driver.CommandProvider.ReturnWriter = dipper.NewMessagePipe(progress)
+	defer func() { driver.CommandProvider.ReturnWriter = nil }()
+
+	m := &dipper.Message{
+		Labels: map[string]string{"sessionID": "s1", "timeout": "10"},
+		Payload: map[string]interface{}{
+			"job": map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "maintenance"},
+				"status": map[string]interface{}{
+					"failed":     1,
+					"conditions": []interface{}{map[string]interface{}{"type": "Failed", "status": "True", "reason": "BackoffLimitExceeded"}},
+				},
+			},
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	runJob(m)
+	ret := <-m.Reply
+
+	assert.Equal(t, StatusFailure, ret.Labels["status"])
+	assert.Equal(t, "BackoffLimitExceeded", ret.Labels["reason"])
+	assert.Equal(t, map[string]interface{}{"maintenance-abc": map[string]interface{}{"main": int32(3)}}, ret.Payload.(map[string]interface{})["exitCodes"])
+
+	select {
+	case msg := <-progress:
+		assert.Equal(t, "true", msg.Labels[dipper.LabelProgress])
+		assert.Equal(t, "s1", msg.Labels["sessionID"])
+		assert.Equal(t, "fake logs", dipper.MustGetMapDataStr(msg.Payload, "log"), "should send the logs as progress")
+		assert.Equal(t, "maintenance-abc", dipper.MustGetMapDataStr(msg.Payload, "pod"))
+	case <-time.After(time.Second):
+		assert.Fail(t, "expecting logs sent as progress")
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	lines := make(chan string)
+	go readLines(strings.NewReader("first\n"+long+"\n\nlast"), lines)
+
+	got := []string{}
+	for line := range lines {
+		got = append(got, line)
+	}
+	assert.Equal(t, []string{"first", long, "", "last"}, got, "should keep following after a line longer than 64KB")
+}
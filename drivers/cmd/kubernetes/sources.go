@@ -0,0 +1,336 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// SourceGKE gets the cluster credential by calling the gcloud-gke driver.
+	SourceGKE = "gcloud-gke"
+
+	// SourceLocal uses the service account of the pod that the driver is running in.
+	SourceLocal = "local"
+
+	// SourceInCluster is an alias of SourceLocal.
+	SourceInCluster = "in-cluster"
+
+	// SourceKubeConfig loads the cluster credential from a kubeconfig, inline or from a file.
+	SourceKubeConfig = "kubeconfig"
+
+	// SourceExec gets the cluster credential from an exec credential plugin.
+	SourceExec = "exec"
+
+	// DefaultClientTTLSeconds is the default TTL for the cached clients of a cluster.
+	DefaultClientTTLSeconds = 600
+
+	// DefaultExecAPIVersion is the default API version for exec credential plugins.
+	DefaultExecAPIVersion = "client.authentication.k8s.io/v1beta1"
+)
+
+// clusterClients caches the config and the clients for a cluster.
+type clusterClients struct {
+	lock      sync.Mutex
+	loaded    chan struct{} // closed when the config is loaded or failed to load
+	config    *rest.Config
+	clientset *kubernetes.Clientset
+	resources *resourceClient
+	expires   time.Time
+}
+
+var (
+	clientCache     = map[string]*clusterClients{}
+	clientCacheLock sync.Mutex
+	clientTTL       = DefaultClientTTLSeconds * time.Second
+)
+
+// loadOptions resets the client cache, and picks up the TTL for the cached clients.
+func loadOptions(m *dipper.Message) {
+	clientCacheLock.Lock()
+	defer clientCacheLock.Unlock()
+
+	clientTTL = DefaultClientTTLSeconds * time.Second
+	if ttlStr, ok := driver.GetOptionStr("data.clientTTL"); ok {
+		clientTTL = dipper.Must(time.ParseDuration(ttlStr)).(time.Duration)
+	}
+	clientCache = map[string]*clusterClients{}
+}
+
+// getClusterClients finds the cached clients for the source in the parameters, the config is loaded if the
+// source is not cached or the cached clients are expired.
+func getClusterClients(m *dipper.Message) *clusterClients {
+	if log == nil {
+		log = driver.GetLogger()
+	}
+	m = dipper.DeserializePayload(m)
+
+	source, ok := dipper.GetMapData(m.Payload, "source")
+	if !ok {
+		log.Panicf("[%s] source is missing in parameters", driver.Service)
+	}
+	// hash the source so the credentials are not kept in the keys
+	sum := sha256.Sum256(dipper.Must(json.Marshal(source)).([]byte))
+	key := hex.EncodeToString(sum[:])
+
+	// the config is loaded outside of the cache lock, so a slow source does not block the other clusters
+	clientCacheLock.Lock()
+	c, ok := clientCache[key]
+	if ok && time.Now().Before(c.expires) {
+		clientCacheLock.Unlock()
+		<-c.loaded
+	} else {
+		c = &clusterClients{
+			loaded:  make(chan struct{}),
+			expires: time.Now().Add(clientTTL),
+		}
+		clientCache[key] = c
+		clientCacheLock.Unlock()
+		c.load(key, source)
+	}
+
+	if c.config == nil {
+		log.Panicf("[%s] unable to get kubeconfig", driver.Service)
+	}
+
+	return c
+}
+
+// load builds the config for the cached clients, the entry is removed from the cache if the config can not be
+// loaded, so the next call can try again.
+func (c *clusterClients) load(key string, source interface{}) {
+	defer func() {
+		if c.config == nil {
+			clientCacheLock.Lock()
+			if clientCache[key] == c {
+				delete(clientCache, key)
+			}
+			clientCacheLock.Unlock()
+		}
+		close(c.loaded)
+	}()
+
+	c.config = loadKubeConfig(source)
+}
+
+// getKubeConfig returns the client config for the source in the parameters.
+func getKubeConfig(m *dipper.Message) *rest.Config {
+	return getClusterClients(m).config
+}
+
+// prepareKubeConfig returns the clientset for the source in the parameters.
+func prepareKubeConfig(m *dipper.Message) *kubernetes.Clientset {
+	c := getClusterClients(m)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.clientset == nil {
+		k8client, err := kubernetes.NewForConfig(c.config)
+		if err != nil {
+			log.Panicf("[%s] unable to create k8 client", driver.Service)
+		}
+		c.clientset = k8client
+	}
+
+	return c.clientset
+}
+
+// loadKubeConfig builds the client config from the source.
+var loadKubeConfig = func(source interface{}) *rest.Config {
+	stype, ok := dipper.GetMapDataStr(source, "type")
+	if !ok {
+		log.Panicf("[%s] source type is missing in parameters", driver.Service)
+	}
+	log.Debugf("[%s] fetching k8config from source", driver.Service)
+	var kubeConfig *rest.Config
+	switch stype {
+	case SourceGKE:
+		kubeConfig = getGKEConfig(source.(map[string]interface{}))
+	case SourceLocal, SourceInCluster:
+		var err error
+		kubeConfig, err = rest.InClusterConfig()
+		if err != nil {
+			log.Panicf("[%s] unable to load default account for kubernetes %+v", driver.Service, err)
+		}
+	case SourceKubeConfig:
+		kubeConfig = getKubeConfigFileConfig(source)
+	case SourceExec:
+		kubeConfig = getExecConfig(source)
+	default:
+		log.Panicf("[%s] unsupported kubernetes source type: %s", driver.Service, stype)
+	}
+	if kubeConfig == nil {
+		log.Panicf("[%s] unable to get kubeconfig", driver.Service)
+	}
+
+	return kubeConfig
+}
+
+func getGKEConfig(cfg map[string]interface{}) *rest.Config {
+	retbytes, err := driver.Call("driver:gcloud-gke", "getKubeCfg", cfg)
+	if err != nil {
+		log.Panicf("[%s] failed call gcloud to get kubeconfig %+v", driver.Service, err)
+	}
+
+	ret := dipper.DeserializeContent(retbytes)
+
+	host, _ := dipper.GetMapDataStr(ret, "Host")
+	token, _ := dipper.GetMapDataStr(ret, "Token")
+	cacert, _ := dipper.GetMapDataStr(ret, "CACert")
+
+	cadata, _ := base64.StdEncoding.DecodeString(cacert)
+
+	k8cfg := &rest.Config{
+		Host:        host,
+		BearerToken: token,
+	}
+	k8cfg.CAData = cadata
+
+	return k8cfg
+}
+
+// getKubeConfigFileConfig loads the config from a kubeconfig in the source, either inline with the "kubeconfig" key
+// or from a file with the "path" key, e.g. a mounted secret. The encrypted values in the kubeconfig are decrypted.
+func getKubeConfigFileConfig(source interface{}) *rest.Config {
+	var (
+		content []byte
+		err     error
+	)
+	if inline, ok := dipper.GetMapData(source, "kubeconfig"); ok {
+		if str, ok := inline.(string); ok {
+			content = []byte(str)
+		} else {
+			content = dipper.Must(yaml.Marshal(inline)).([]byte)
+		}
+	} else if path, ok := dipper.GetMapDataStr(source, "path"); ok {
+		content, err = os.ReadFile(path)
+		if err != nil {
+			log.Panicf("[%s] unable to read kubeconfig file %s: %+v", driver.Service, path, err)
+		}
+	} else {
+		log.Panicf("[%s] kubeconfig or path is required for kubeconfig source", driver.Service)
+	}
+
+	if strings.Contains(string(content), "ENC[") {
+		content = decryptKubeConfig(content)
+	}
+
+	apiConfig, err := clientcmd.Load(content)
+	if err != nil {
+		log.Panicf("[%s] unable to parse kubeconfig: %+v", driver.Service, err)
+	}
+	overrides := &clientcmd.ConfigOverrides{}
+	overrides.CurrentContext, _ = dipper.GetMapDataStr(source, "context")
+	kubeConfig, err := clientcmd.NewNonInteractiveClientConfig(*apiConfig, overrides.CurrentContext, overrides, nil).ClientConfig()
+	if err != nil {
+		log.Panicf("[%s] unable to load kubeconfig: %+v", driver.Service, err)
+	}
+
+	return kubeConfig
+}
+
+// decryptKubeConfig decrypts the encrypted values in the kubeconfig, or the kubeconfig itself if it is encrypted
+// as a whole.
+func decryptKubeConfig(content []byte) []byte {
+	var data interface{}
+	if err := yaml.Unmarshal(content, &data); err != nil {
+		log.Panicf("[%s] unable to parse kubeconfig: %+v", driver.Service, err)
+	}
+	if str, ok := data.(string); ok {
+		decrypted, _ := dipper.GetDecryptFunc(driver)("kubeconfig", str)
+		if decryptedStr, ok := decrypted.(string); ok {
+			return []byte(decryptedStr)
+		}
+
+		return content
+	}
+	dipper.DecryptAll(driver, data)
+
+	return dipper.Must(yaml.Marshal(data)).([]byte)
+}
+
+// getExecConfig builds the config for a cluster using an exec credential plugin, e.g. "aws eks get-token".
+func getExecConfig(source interface{}) *rest.Config {
+	server, ok := dipper.GetMapDataStr(source, "server")
+	if !ok {
+		log.Panicf("[%s] server is required for exec source", driver.Service)
+	}
+	command, ok := dipper.GetMapDataStr(source, "command")
+	if !ok {
+		log.Panicf("[%s] command is required for exec source", driver.Service)
+	}
+
+	execConfig := &clientcmdapi.ExecConfig{
+		Command:         command,
+		APIVersion:      DefaultExecAPIVersion,
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+	if apiVersion, ok := dipper.GetMapDataStr(source, "apiVersion"); ok {
+		execConfig.APIVersion = apiVersion
+	}
+	if v, ok := dipper.GetMapData(source, "args"); ok {
+		args, ok := v.([]interface{})
+		if !ok {
+			log.Panicf("[%s] args should be a list for exec source", driver.Service)
+		}
+		for i, v := range args {
+			arg, ok := v.(string)
+			if !ok {
+				log.Panicf("[%s] args[%d] should be a string for exec source", driver.Service, i)
+			}
+			execConfig.Args = append(execConfig.Args, arg)
+		}
+	}
+	if v, ok := dipper.GetMapData(source, "env"); ok {
+		env, ok := v.(map[string]interface{})
+		if !ok {
+			log.Panicf("[%s] env should be a map for exec source", driver.Service)
+		}
+		names := []string{}
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, ok := env[name].(string)
+			if !ok {
+				log.Panicf("[%s] env.%s should be a string for exec source", driver.Service, name)
+			}
+			execConfig.Env = append(execConfig.Env, clientcmdapi.ExecEnvVar{Name: name, Value: value})
+		}
+	}
+
+	kubeConfig := &rest.Config{
+		Host:         server,
+		ExecProvider: execConfig,
+	}
+	if cacert, ok := dipper.GetMapDataStr(source, "caData"); ok {
+		kubeConfig.CAData, err = base64.StdEncoding.DecodeString(cacert)
+		if err != nil {
+			log.Panicf("[%s] caData should be base64 encoded: %+v", driver.Service, err)
+		}
+	}
+	if insecure, ok := dipper.GetMapDataBool(source, "insecure"); ok {
+		kubeConfig.Insecure = insecure
+	}
+
+	return kubeConfig
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+)
+
+const testKubeConfig = `
+apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com
+- name: onprem
+  cluster:
+    server: https://onprem.example.com
+    insecure-skip-tls-verify: true
+users:
+- name: admin
+  user:
+    token: secret-token
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+- name: onprem
+  context:
+    cluster: onprem
+    user: admin
+`
+
+func sourceMessage(source map[string]interface{}) *dipper.Message {
+	return &dipper.Message{Payload: map[string]interface{}{"source": source}}
+}
+
+func TestKubeConfigSource(t *testing.T) {
+	cfg := getKubeConfig(sourceMessage(map[string]interface{}{"type": "kubeconfig", "kubeconfig": testKubeConfig}))
+	assert.Equal(t, "https://prod.example.com", cfg.Host, "should use the current context")
+	assert.Equal(t, "secret-token", cfg.BearerToken)
+
+	file := filepath.Join(t.TempDir(), "config")
+	assert.Nil(t, os.WriteFile(file, []byte(testKubeConfig), 0o600))
+	cfg = getKubeConfig(sourceMessage(map[string]interface{}{"type": "kubeconfig", "path": file, "context": "onprem"}))
+	assert.Equal(t, "https://onprem.example.com", cfg.Host, "should use the given context")
+	assert.True(t, cfg.Insecure)
+
+	assert.Panics(t, func() {
+		getKubeConfig(sourceMessage(map[string]interface{}{"type": "kubeconfig", "kubeconfig": testKubeConfig, "context": "missing"}))
+	}, "should panic with unknown context")
+	assert.Panics(t, func() {
+		getKubeConfig(sourceMessage(map[string]interface{}{"type": "kubeconfig"}))
+	}, "should panic without kubeconfig or path")
+}
+
+func TestExecSource(t *testing.T) {
+	cfg := getKubeConfig(sourceMessage(map[string]interface{}{
+		"type":    "exec",
+		"server":  "https://eks.example.com",
+		"caData":  "Y2FjZXJ0",
+		"command": "aws",
+		"args":    []interface{}{"eks", "get-token", "--cluster-name", "prod"},
+		"env":     map[string]interface{}{"AWS_REGION": "us-west-2", "AWS_PROFILE": "ops"},
+	}))
+	assert.Equal(t, "https://eks.example.com", cfg.Host)
+	assert.Equal(t, []byte("cacert"), cfg.CAData)
+	assert.Equal(t, "aws", cfg.ExecProvider.Command)
+	assert.Equal(t, []string{"eks", "get-token", "--cluster-name", "prod"}, cfg.ExecProvider.Args)
+	assert.Equal(t, DefaultExecAPIVersion, cfg.ExecProvider.APIVersion)
+	assert.Len(t, cfg.ExecProvider.Env, 2)
+	assert.Equal(t, "AWS_PROFILE", cfg.ExecProvider.Env[0].Name)
+
+	assert.Panics(t, func() {
+		getKubeConfig(sourceMessage(map[string]interface{}{"type": "exec", "command": "aws"}))
+	}, "should panic without server")
+	for _, c := range []struct {
+		field string
+		value interface{}
+		msg   string
+	}{
+		{"args", "eks get-token", "args should be a list"},
+		{"args", []interface{}{"eks", 1}, "args[1] should be a string"},
+		{"env", []interface{}{"AWS_REGION=us-west-2"}, "env should be a map"},
+		{"env", map[string]interface{}{"A": 1}, "env.A should be a string"},
+	} {
+		source := map[string]interface{}{"type": "exec", "server": "https://eks.example.com", "command": "aws", c.field: c.value}
+		assert.PanicsWithValue(t, "[test] "+c.msg+" for exec source", func() {
+			getKubeConfig(sourceMessage(source))
+		}, "should panic with invalid %s", c.field)
+	}
+	assert.Panics(t, func() {
+		getKubeConfig(sourceMessage(map[string]interface{}{"type": "unknown"}))
+	}, "should panic with unsupported source type")
+}
+
+func TestClientCache(t *testing.T) {
+	defer func() {
+		clientTTL = DefaultClientTTLSeconds * time.Second
+		clientCache = map[string]*clusterClients{}
+	}()
+
+	prod := map[string]interface{}{"type": "kubeconfig", "kubeconfig": testKubeConfig}
+	onprem := map[string]interface{}{"type": "kubeconfig", "kubeconfig": testKubeConfig, "context": "onprem"}
+	client := prepareKubeConfig(sourceMessage(prod))
+	assert.Same(t, client, prepareKubeConfig(sourceMessage(prod)), "should reuse the client for the same cluster")
+	assert.NotSame(t, client, prepareKubeConfig(sourceMessage(onprem)), "should not share the client across clusters")
+
+	clientTTL = 0
+	clientCache = map[string]*clusterClients{}
+	client = prepareKubeConfig(sourceMessage(prod))
+	assert.NotSame(t, client, prepareKubeConfig(sourceMessage(prod)), "should rebuild the client after expired")
+}
+
+func TestClientCacheLoadOutsideLock(t *testing.T) {
+	defer func(orig func(interface{}) *rest.Config) {
+		loadKubeConfig = orig
+		clientCache = map[string]*clusterClients{}
+	}(loadKubeConfig)
+
+	release := make(chan struct{})
+	loads := int32(0)
+	loadKubeConfig = func(source interface{}) *rest.Config {
+		host := dipper.MustGetMapDataStr(source, "host")
+		if host == "slow" {
+			atomic.AddInt32(&loads, 1)
+			<-release
+		}
+		if host == "broken" {
+			log.Panicf("broken source")
+		}
+
+		return &rest.Config{Host: host}
+	}
+
+	slow := make(chan *rest.Config, 2)
+	for i := 0; i < 2; i++ {
+		go func() { slow <- getKubeConfig(sourceMessage(map[string]interface{}{"host": "slow"})) }()
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&loads) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "fast", getKubeConfig(sourceMessage(map[string]interface{}{"host": "fast"})).Host,
+		"should not be blocked by a cluster that is still loading")
+
+	close(release)
+	cfg := <-slow
+	assert.Same(t, cfg, <-slow, "should share the config being loaded")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads), "should load the config only once")
+
+	assert.Panics(t, func() { getKubeConfig(sourceMessage(map[string]interface{}{"host": "broken"})) })
+	assert.Len(t, clientCache, 2, "should not cache the failed config")
+}
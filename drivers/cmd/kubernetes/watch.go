@@ -0,0 +1,234 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// EventAdded is the raw event when a watched resource is created.
+	EventAdded = "added"
+	// EventUpdated is the raw event when a watched resource is changed.
+	EventUpdated = "updated"
+	// EventDeleted is the raw event when a watched resource is deleted.
+	EventDeleted = "deleted"
+)
+
+// Watcher keeps an informer on a kind of resources in a cluster, and emits events on the changes matching the conditions.
+type Watcher struct {
+	Source        interface{}
+	APIVersion    string
+	Kind          string
+	Namespace     string
+	LabelSelector string
+	FieldSelector string
+	Conditions    []interface{}
+
+	started time.Time
+	stop    chan struct{}
+}
+
+var (
+	watchers     map[string]*Watcher
+	watchersLock sync.Mutex
+)
+
+// loadWatchers builds the watchers from the collapsed events, the rules watching the same resources share one watcher.
+func loadWatchers() map[string]*Watcher {
+	events, ok := driver.GetOption("dynamicData.collapsedEvents")
+	if !ok {
+		log.Warningf("[%s] no rules defined for kubernetes events", driver.Service)
+
+		return nil
+	}
+	collapsedEvents, ok := events.(map[string]interface{})
+	if !ok {
+		log.Panicf("[%s] kubernetes event data should be a map of event to conditions", driver.Service)
+	}
+
+	ret := map[string]*Watcher{}
+	for name, collapsedEvent := range collapsedEvents {
+		for _, branch := range collapsedEvent.([]interface{}) {
+			params, _ := dipper.GetMapData(branch, "parameters")
+			w := &Watcher{}
+			w.Source, _ = dipper.GetMapData(params, "source")
+			w.Kind, _ = dipper.GetMapDataStr(params, "kind")
+			if w.Source == nil || w.Kind == "" {
+				log.Warningf("[%s] source and kind are required in parameters for watching %s", driver.Service, name)
+
+				continue
+			}
+			if w.APIVersion, ok = dipper.GetMapDataStr(params, "apiVersion"); !ok {
+				w.APIVersion = "v1"
+			}
+			w.Namespace, _ = dipper.GetMapDataStr(params, "namespace")
+			w.LabelSelector, _ = dipper.GetMapDataStr(params, "labelSelector")
+			w.FieldSelector, _ = dipper.GetMapDataStr(params, "fieldSelector")
+
+			key := string(dipper.Must(json.Marshal(w)).([]byte))
+			if existing, ok := ret[key]; ok {
+				w = existing
+			} else {
+				ret[key] = w
+			}
+			condition, _ := dipper.GetMapData(branch, "match")
+			w.Conditions = append(w.Conditions, condition)
+		}
+	}
+
+	return ret
+}
+
+func startWatches(m *dipper.Message) {
+	log = driver.GetLogger()
+	newWatchers := loadWatchers()
+
+	watchersLock.Lock()
+	defer watchersLock.Unlock()
+	for _, w := range watchers {
+		close(w.stop)
+	}
+	watchers = newWatchers
+	for _, w := range watchers {
+		w.start()
+	}
+}
+
+func stopWatches(m *dipper.Message) {
+	watchersLock.Lock()
+	defer watchersLock.Unlock()
+	for _, w := range watchers {
+		close(w.stop)
+	}
+	watchers = nil
+}
+
+// start runs the informer in the background until the watcher is stopped.
+func (w *Watcher) start() {
+	w.stop = make(chan struct{})
+	defer dipper.SafeExitOnError("[%s] unable to watch %s %s", driver.Service, w.APIVersion, w.Kind)
+
+	client := newResourceClient(&dipper.Message{Payload: map[string]interface{}{"source": w.Source}})
+	mapping := client.mappingFor(w.APIVersion, w.Kind)
+	namespace := w.Namespace
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		namespace = metav1.NamespaceAll
+	}
+
+	informer := dynamicinformer.NewFilteredDynamicInformer(client.dynamic, mapping.Resource, namespace, 0, cache.Indexers{}, func(opts *metav1.ListOptions) {
+		opts.LabelSelector = w.LabelSelector
+		opts.FieldSelector = w.FieldSelector
+	}).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			// skip the existing resources received in the initial listing
+			if u, ok := obj.(*unstructured.Unstructured); ok && u.GetCreationTimestamp().Time.Before(w.started) {
+				return
+			}
+			w.handle(EventAdded, nil, obj)
+		},
+		UpdateFunc: func(oldObj, obj interface{}) {
+			w.handle(EventUpdated, oldObj, obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			w.handle(EventDeleted, nil, obj)
+		},
+	})
+
+	w.started = time.Now().Truncate(time.Second)
+	go informer.Run(w.stop)
+	log.Infof("[%s] watching %s %s in namespace [%s]", driver.Service, w.APIVersion, w.Kind, namespace)
+}
+
+// handle emits an event if the change matches any of the conditions.
+func (w *Watcher) handle(eventType string, oldObj interface{}, obj interface{}) {
+	defer dipper.SafeExitOnError("[%s] failed to handle %s event for %s", driver.Service, eventType, w.Kind)
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	data := map[string]interface{}{
+		"type":       eventType,
+		"apiVersion": u.GetAPIVersion(),
+		"kind":       u.GetKind(),
+		"namespace":  u.GetNamespace(),
+		"name":       u.GetName(),
+		"object":     u.Object,
+	}
+	if old, ok := oldObj.(*unstructured.Unstructured); ok {
+		diff := diffObjects(old.Object, u.Object)
+		if diff == nil {
+			// nothing but the resourceVersion changed, e.g. resync
+			return
+		}
+		data["oldObject"] = old.Object
+		data["diff"] = diff
+	}
+
+	for _, condition := range w.Conditions {
+		if dipper.CompareAll(data, condition) {
+			driver.EmitEvent(map[string]interface{}{
+				"events": []interface{}{"kubernetes." + eventType, "kubernetes."},
+				"data":   data,
+			})
+
+			return
+		}
+	}
+}
+
+// diffObjects returns the fields changed in the new object, with nil for the removed fields.  The fields that
+// always change, like metadata.resourceVersion and metadata.managedFields, are ignored.
+func diffObjects(old map[string]interface{}, current map[string]interface{}) map[string]interface{} {
+	return diffMaps(old, current, "")
+}
+
+func diffMaps(old map[string]interface{}, current map[string]interface{}, path string) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for k, v := range current {
+		switch p := path + "." + k; p {
+		case ".metadata.resourceVersion", ".metadata.managedFields", ".metadata.generation":
+			continue
+		default:
+			oldMap, oldIsMap := old[k].(map[string]interface{})
+			newMap, newIsMap := v.(map[string]interface{})
+			if oldIsMap && newIsMap {
+				if sub := diffMaps(oldMap, newMap, p); sub != nil {
+					ret[k] = sub
+				}
+			} else if !reflect.DeepEqual(old[k], v) {
+				ret[k] = v
+			}
+		}
+	}
+	for k := range old {
+		if _, ok := current[k]; !ok {
+			ret[k] = nil
+		}
+	}
+
+	if len(ret) == 0 {
+		return nil
+	}
+
+	return ret
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffObjects(t *testing.T) {
+	old := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "test", "resourceVersion": "1", "labels": map[string]interface{}{"app": "a", "tier": "web"}},
+		"spec":     map[string]interface{}{"replicas": 1, "paused": false},
+	}
+	current := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "test", "resourceVersion": "2", "labels": map[string]interface{}{"app": "b"}},
+		"spec":     map[string]interface{}{"replicas": 1, "paused": false},
+		"status":   map[string]interface{}{"ready": true},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "b", "tier": nil}},
+		"status":   map[string]interface{}{"ready": true},
+	}, diffObjects(old, current), "should return the changed, added and removed fields")
+
+	current = dipper.MustDeepCopyMap(old)
+	current["metadata"].(map[string]interface{})["resourceVersion"] = "3"
+	assert.Nil(t, diffObjects(old, current), "should ignore resourceVersion")
+}
+
+func TestLoadWatchers(t *testing.T) {
+	defer func() { driver.Options = nil }()
+	driver.Options = map[string]interface{}{
+		"dynamicData": map[string]interface{}{
+			"collapsedEvents": map[string]interface{}{
+				"kubernetes.updated": []interface{}{
+					map[string]interface{}{
+						"parameters": map[string]interface{}{"source": map[string]interface{}{"type": "local"}, "kind": "ConfigMap"},
+						"match":      map[string]interface{}{"name": "first"},
+					},
+					map[string]interface{}{
+						"parameters": map[string]interface{}{"source": map[string]interface{}{"type": "local"}, "kind": "ConfigMap"},
+						"match":      map[string]interface{}{"name": "second"},
+					},
+					map[string]interface{}{
+						"parameters": map[string]interface{}{"source": map[string]interface{}{"type": "local"}, "kind": "Deployment", "apiVersion": "apps/v1"},
+					},
+					map[string]interface{}{
+						"parameters": map[string]interface{}{"kind": "Deployment"},
+					},
+				},
+			},
+		},
+	}
+
+	loaded := loadWatchers()
+	assert.Len(t, loaded, 2, "should share the watcher for the same resources and skip the invalid ones")
+	for _, w := range loaded {
+		switch w.Kind {
+		case "ConfigMap":
+			assert.Equal(t, "v1", w.APIVersion, "should default apiVersion to v1")
+			assert.Equal(t, []interface{}{map[string]interface{}{"name": "first"}, map[string]interface{}{"name": "second"}}, w.Conditions)
+		case "Deployment":
+			assert.Equal(t, "apps/v1", w.APIVersion)
+			assert.Equal(t, []interface{}{nil}, w.Conditions)
+		}
+	}
+}
+
+func TestWatches(t *testing.T) {
+	client := useFakeResourceClient(t, newTestObject("v1", "ConfigMap", "default", "existing", nil))
+	events := make(chan *dipper.Message, 10)
+	driver.Out = dipper.NewMessagePipe(events)
+	driver.Options = map[string]interface{}{
+		"dynamicData": map[string]interface{}{
+			"collapsedEvents": map[string]interface{}{
+				"kubernetes.": []interface{}{
+					map[string]interface{}{
+						"parameters": map[string]interface{}{"source": map[string]interface{}{"type": "local"}, "kind": "ConfigMap", "namespace": "default"},
+						"match":      map[string]interface{}{"name": "watched"},
+					},
+				},
+			},
+		},
+	}
+	defer func() {
+		stopWatches(nil)
+		driver.Out = nil
+		driver.Options = nil
+	}()
+	startWatches(nil)
+
+	fetchEvent := func() map[string]interface{} {
+		select {
+		case msg := <-events:
+			return msg.Payload.(map[string]interface{})
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "expecting event from watcher")
+		}
+
+		return nil
+	}
+
+	ctx := context.Background()
+	resource := client.Resource(configMapsGVR).Namespace("default")
+	obj := newTestObject("v1", "ConfigMap", "default", "watched", nil)
+	obj.SetCreationTimestamp(metav1.NewTime(time.Now().Add(time.Minute)))
+	_, err := resource.Create(ctx, obj, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	ignored := newTestObject("v1", "ConfigMap", "default", "ignored", nil)
+	ignored.SetCreationTimestamp(metav1.NewTime(time.Now().Add(time.Minute)))
+	_, err = resource.Create(ctx, ignored, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	evt := fetchEvent()
+	assert.Equal(t, []interface{}{"kubernetes.added", "kubernetes."}, evt["events"])
+	assert.Equal(t, "watched", dipper.MustGetMapDataStr(evt, "data.name"), "should only emit events matching the conditions")
+
+	obj.SetLabels(map[string]string{"app": "test"})
+	_, err = resource.Update(ctx, obj, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	evt = fetchEvent()
+	assert.Equal(t, "updated", dipper.MustGetMapDataStr(evt, "data.type"))
+	assert.Equal(t, map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "test"}}}, dipper.MustGetMapData(evt, "data.diff"))
+
+	assert.Nil(t, resource.Delete(ctx, "watched", metav1.DeleteOptions{}))
+	evt = fetchEvent()
+	assert.Equal(t, "deleted", dipper.MustGetMapDataStr(evt, "data.type"))
+
+	select {
+	case msg := <-events:
+		assert.Failf(t, "should not emit events for the existing resources", "%+v", msg.Payload)
+	default:
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/honeydipper/honeydipper/drivers/pkg/redisclient"
@@ -23,11 +24,30 @@ import (
 // DefaultPrefix is the prefix used for naming the locking topic.
 const DefaultPrefix = "lock:"
 
+// FencingPrefix is the prefix added to the lock name for storing the fencing counter.
+const FencingPrefix = "fencing:"
+
+const (
+	// lockScript acquires the lock and bumps the fencing counter in one atomic operation.
+	lockScript = `if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then return redis.call("incr", KEYS[2]) else return 0 end`
+
+	// unlockScript deletes the lock only if it is still owned by the caller.
+	unlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+	// renewScript extends the lock expiration only if it is still owned by the caller.
+	renewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
+	// statusScript returns the owner, the remaining TTL in milliseconds and the current fencing counter.
+	statusScript = `return {redis.call("get", KEYS[1]), redis.call("pttl", KEYS[1]), redis.call("get", KEYS[2])}`
+)
+
 var (
 	// ErrFailToLock means not being able to acquire the lock.
 	ErrFailToLock = errors.New("fail to lock")
 	// ErrFailToUnlock means not being able to unlock.
 	ErrFailToUnlock = errors.New("fail to unlock")
+	// ErrFailToRenew means not being able to extend the lock.
+	ErrFailToRenew = errors.New("fail to renew")
 )
 
 // Locker holds the driver, configurations and runtime information.
@@ -36,6 +56,7 @@ type Locker struct {
 	redisOptions *redisclient.Options
 	prefix       string
 	nodeID       string
+	newUUID      dipper.UUIDSource
 }
 
 func (l *Locker) start(msg *dipper.Message) {
@@ -61,14 +82,23 @@ func initFlags() {
 func main() {
 	initFlags()
 	flag.Parse()
-	l := Locker{}
+	l := Locker{
+		nodeID:  dipper.GetIP(),
+		newUUID: dipper.NewUUID,
+	}
 	l.driver = dipper.NewDriver(os.Args[1], "redislock")
 	l.driver.Start = l.start
 	l.driver.Reload = l.loadOptions
 	l.driver.RPCHandlers["lock"] = l.lock
 	l.driver.RPCHandlers["unlock"] = l.unlock
+	l.driver.RPCHandlers["renew"] = l.renew
+	l.driver.RPCHandlers["status"] = l.status
 	l.driver.Run()
-	l.nodeID = dipper.GetIP()
+}
+
+// keys returns the redis keys for the lock and its fencing counter.
+func (l *Locker) keys(name string) []string {
+	return []string{l.prefix + name, l.prefix + FencingPrefix + name}
 }
 
 func (l *Locker) lock(msg *dipper.Message) {
@@ -91,17 +121,25 @@ func (l *Locker) lock(msg *dipper.Message) {
 	client := redisclient.NewClient(l.redisOptions)
 	defer client.Close()
 
-	ok := dipper.Must(client.SetNX(ctx, l.prefix+name, l.nodeID, expire).Result()).(bool)
-	if !ok {
+	// the owner token is unique for every acquisition, the node ID is only informational.
+	owner := l.nodeID + "/" + l.newUUID()
+	token := dipper.Must(client.Eval(ctx, lockScript, l.keys(name), owner, expire.Milliseconds()).Int64()).(int64)
+	if token == 0 {
 		panic(ErrFailToLock)
 	}
 
-	msg.Reply <- dipper.Message{}
+	msg.Reply <- dipper.Message{
+		Payload: map[string]interface{}{
+			"owner":         owner,
+			"fencing_token": token,
+		},
+	}
 }
 
 func (l *Locker) unlock(msg *dipper.Message) {
 	msg = dipper.DeserializePayload(msg)
 	name := dipper.MustGetMapDataStr(msg.Payload, "name")
+	owner := dipper.MustGetMapDataStr(msg.Payload, "owner")
 
 	ctx, cancel := l.driver.GetContext()
 	defer cancel()
@@ -109,10 +147,62 @@ func (l *Locker) unlock(msg *dipper.Message) {
 	client := redisclient.NewClient(l.redisOptions)
 	defer client.Close()
 
-	ok := dipper.Must(client.Del(ctx, l.prefix+name).Result()).(int64) > 0
+	ok := dipper.Must(client.Eval(ctx, unlockScript, l.keys(name)[:1], owner).Int64()).(int64) > 0
 	if !ok {
 		panic(ErrFailToUnlock)
 	}
 
 	msg.Reply <- dipper.Message{}
 }
+
+func (l *Locker) renew(msg *dipper.Message) {
+	msg = dipper.DeserializePayload(msg)
+	expire := dipper.Must(time.ParseDuration(dipper.MustGetMapDataStr(msg.Payload, "expire"))).(time.Duration)
+	name := dipper.MustGetMapDataStr(msg.Payload, "name")
+	owner := dipper.MustGetMapDataStr(msg.Payload, "owner")
+
+	ctx, cancel := l.driver.GetContext()
+	defer cancel()
+
+	client := redisclient.NewClient(l.redisOptions)
+	defer client.Close()
+
+	ok := dipper.Must(client.Eval(ctx, renewScript, l.keys(name)[:1], owner, expire.Milliseconds()).Int64()).(int64) > 0
+	if !ok {
+		panic(ErrFailToRenew)
+	}
+
+	msg.Reply <- dipper.Message{}
+}
+
+func (l *Locker) status(msg *dipper.Message) {
+	msg = dipper.DeserializePayload(msg)
+	name := dipper.MustGetMapDataStr(msg.Payload, "name")
+	owner, _ := dipper.GetMapDataStr(msg.Payload, "owner")
+
+	ctx, cancel := l.driver.GetContext()
+	defer cancel()
+
+	client := redisclient.NewClient(l.redisOptions)
+	defer client.Close()
+
+	result := dipper.Must(client.Eval(ctx, statusScript, l.keys(name)).Slice()).([]interface{})
+	current, _ := result[0].(string)
+	ret := map[string]interface{}{
+		"locked": current != "",
+	}
+	if current != "" {
+		ret["holder"], _, _ = strings.Cut(current, "/")
+		ret["ttl_ms"] = result[1]
+		if owner != "" {
+			ret["owned"] = current == owner
+		}
+	}
+	if fencing, ok := result[2].(string); ok {
+		ret["fencing_token"] = dipper.Must(strconv.ParseInt(fencing, 10, 64)).(int64)
+	}
+
+	msg.Reply <- dipper.Message{
+		Payload: ret,
+	}
+}
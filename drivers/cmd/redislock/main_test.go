@@ -0,0 +1,144 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-redis/redismock/v8"
+	"github.com/honeydipper/honeydipper/drivers/pkg/redisclient"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	if dipper.Logger == nil {
+		f, _ := os.Create("test.log")
+		defer f.Close()
+		dipper.GetLogger("test service", "DEBUG", f, f)
+	}
+	os.Exit(m.Run())
+}
+
+func newTestLocker() (*Locker, redismock.ClientMock) {
+	db, mock := redismock.NewClientMock()
+	l := &Locker{
+		driver:       dipper.NewDriver(os.Args[1], "redislock"),
+		redisOptions: &redisclient.Options{Client: db},
+		prefix:       DefaultPrefix,
+		nodeID:       "10.0.0.1",
+		newUUID:      func() string { return "uuid1" },
+	}
+
+	return l, mock
+}
+
+func TestLock(t *testing.T) {
+	l, mock := newTestLocker()
+
+	msg := &dipper.Message{
+		Payload: map[string]interface{}{
+			"name":   "foo",
+			"expire": "1m",
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	mock.ExpectEval(lockScript, []string{"lock:foo", "lock:fencing:foo"}, "10.0.0.1/uuid1", int64(60000)).SetVal(int64(3))
+	assert.NotPanics(t, func() { l.lock(msg) }, "lock should not panic when acquired")
+	reply := <-msg.Reply
+	assert.Equal(t, map[string]interface{}{"owner": "10.0.0.1/uuid1", "fencing_token": int64(3)}, reply.Payload, "lock should return owner and fencing token")
+
+	msg = &dipper.Message{
+		Payload: map[string]interface{}{
+			"name":   "foo",
+			"expire": "1m",
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	mock.ExpectEval(lockScript, []string{"lock:foo", "lock:fencing:foo"}, "10.0.0.1/uuid1", int64(60000)).SetVal(int64(0))
+	assert.PanicsWithValue(t, ErrFailToLock, func() { l.lock(msg) }, "lock should panic when held by others")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestUnlock(t *testing.T) {
+	l, mock := newTestLocker()
+
+	assert.Panics(t, func() { l.unlock(&dipper.Message{Payload: map[string]interface{}{"name": "foo"}}) }, "unlock should panic without owner")
+
+	msg := &dipper.Message{
+		Payload: map[string]interface{}{
+			"name":  "foo",
+			"owner": "10.0.0.1/uuid1",
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	mock.ExpectEval(unlockScript, []string{"lock:foo"}, "10.0.0.1/uuid1").SetVal(int64(1))
+	assert.NotPanics(t, func() { l.unlock(msg) }, "unlock should not panic when owned")
+	<-msg.Reply
+
+	mock.ExpectEval(unlockScript, []string{"lock:foo"}, "10.0.0.1/uuid1").SetVal(int64(0))
+	assert.PanicsWithValue(t, ErrFailToUnlock, func() { l.unlock(msg) }, "unlock should panic when not owned")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRenew(t *testing.T) {
+	l, mock := newTestLocker()
+
+	msg := &dipper.Message{
+		Payload: map[string]interface{}{
+			"name":   "foo",
+			"owner":  "10.0.0.1/uuid1",
+			"expire": "30s",
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	mock.ExpectEval(renewScript, []string{"lock:foo"}, "10.0.0.1/uuid1", int64(30000)).SetVal(int64(1))
+	assert.NotPanics(t, func() { l.renew(msg) }, "renew should not panic when owned")
+	<-msg.Reply
+
+	mock.ExpectEval(renewScript, []string{"lock:foo"}, "10.0.0.1/uuid1", int64(30000)).SetVal(int64(0))
+	assert.PanicsWithValue(t, ErrFailToRenew, func() { l.renew(msg) }, "renew should panic when not owned")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestStatus(t *testing.T) {
+	l, mock := newTestLocker()
+
+	msg := &dipper.Message{
+		Payload: map[string]interface{}{
+			"name":  "foo",
+			"owner": "10.0.0.1/uuid1",
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	mock.ExpectEval(statusScript, []string{"lock:foo", "lock:fencing:foo"}).SetVal([]interface{}{"10.0.0.1/uuid1", int64(1000), "3"})
+	assert.NotPanics(t, func() { l.status(msg) }, "status should not panic when locked")
+	reply := <-msg.Reply
+	assert.Equal(t, map[string]interface{}{
+		"locked":        true,
+		"holder":        "10.0.0.1",
+		"ttl_ms":        int64(1000),
+		"owned":         true,
+		"fencing_token": int64(3),
+	}, reply.Payload, "status should return the lock details")
+
+	msg = &dipper.Message{
+		Payload: map[string]interface{}{
+			"name": "foo",
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	mock.ExpectEval(statusScript, []string{"lock:foo", "lock:fencing:foo"}).SetVal([]interface{}{nil, int64(-2), nil})
+	assert.NotPanics(t, func() { l.status(msg) }, "status should not panic when not locked")
+	reply = <-msg.Reply
+	assert.Equal(t, map[string]interface{}{"locked": false}, reply.Payload, "status should return unlocked")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// DefaultTimeout is the default time limit for a request, including reading the response body.
+	DefaultTimeout = time.Minute
+
+	// DefaultBackoff is the default time to wait before the first retry, doubled for each following retry.
+	DefaultBackoff = time.Second
+
+	// DefaultMaxBackoff is the default limit of the time to wait between retries.
+	DefaultMaxBackoff = 30 * time.Second
+
+	// ProxyNone is the value of the proxy parameter for not using any proxy, even if set in the environment.
+	ProxyNone = "none"
+)
+
+// DefaultRetryStatusCodes are the status codes that are retried if not specified in the retry parameter.
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryPolicy defines how a request is retried.
+type retryPolicy struct {
+	attempts      int
+	nonIdempotent bool // also retry the non-idempotent methods, e.g. POST, on network errors
+	statusCodes   map[int]bool
+	backoff       time.Duration
+	maxBackoff    time.Duration
+}
+
+var (
+	transports     = map[string]*http.Transport{}
+	transportsLock sync.Mutex
+
+	// sleep is used for waiting between retries.
+	sleep = time.Sleep
+)
+
+// getClient creates the http client with the timeout, TLS and proxy parameters in the payload. The transports are
+// cached by the TLS and proxy parameters, so the connections can be reused across requests.
+func getClient(payload interface{}) *http.Client {
+	timeout := DefaultTimeout
+	timeoutStr, ok := dipper.GetMapDataStr(payload, "timeout")
+	if !ok {
+		timeoutStr, ok = driver.GetOptionStr("data.timeout")
+	}
+	if ok {
+		timeout = dipper.Must(time.ParseDuration(timeoutStr)).(time.Duration)
+	}
+
+	tlsOpts, _ := dipper.GetMapData(payload, "tls")
+	proxy, _ := dipper.GetMapDataStr(payload, "proxy")
+	client := &http.Client{Timeout: timeout}
+	if tlsOpts != nil || proxy != "" {
+		client.Transport = getTransport(tlsOpts, proxy)
+	}
+
+	return client
+}
+
+// getTransport returns the cached transport for the TLS and proxy parameters, or creates a new one.
+func getTransport(tlsOpts interface{}, proxy string) *http.Transport {
+	key := dipper.Must(json.Marshal([]interface{}{tlsOpts, proxy})).([]byte)
+	hash := sha256.Sum256(key)
+	hashStr := hex.EncodeToString(hash[:])
+
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+	if t, ok := transports[hashStr]; ok {
+		return t
+	}
+
+	//nolint:gomnd
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	switch proxy {
+	case "":
+	case ProxyNone:
+		t.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			log.Panicf("[%s] invalid proxy url: %+v", driver.Service, err)
+		}
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+	if tlsOpts != nil {
+		t.TLSClientConfig = getTLSConfig(tlsOpts)
+	}
+	transports[hashStr] = t
+
+	return t
+}
+
+// resetTransports closes the idle connections and removes the cached transports.
+func resetTransports() {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+	for _, t := range transports {
+		t.CloseIdleConnections()
+	}
+	transports = map[string]*http.Transport{}
+}
+
+// getTLSConfig creates the TLS config with the CA and the client certificate, either inline or from files.
+func getTLSConfig(tlsOpts interface{}) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	ca, _ := dipper.GetMapDataStr(tlsOpts, "ca")
+	if caFile, ok := dipper.GetMapDataStr(tlsOpts, "ca_file"); ok {
+		content, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Panicf("[%s] unable to load the CA: %+v", driver.Service, err)
+		}
+		ca = string(content)
+	}
+	if ca != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(ca)) {
+			log.Panicf("[%s] no valid CA cert found", driver.Service)
+		}
+		cfg.RootCAs = pool
+	}
+
+	cert, _ := dipper.GetMapDataStr(tlsOpts, "cert")
+	key, _ := dipper.GetMapDataStr(tlsOpts, "key")
+	certFile, hasCertFile := dipper.GetMapDataStr(tlsOpts, "cert_file")
+	keyFile, _ := dipper.GetMapDataStr(tlsOpts, "key_file")
+	var (
+		pair tls.Certificate
+		err  error
+	)
+	switch {
+	case hasCertFile:
+		pair, err = tls.LoadX509KeyPair(certFile, keyFile)
+	case cert != "":
+		pair, err = tls.X509KeyPair([]byte(cert), []byte(key))
+	}
+	if err != nil {
+		log.Panicf("[%s] unable to load the client certificate: %+v", driver.Service, err)
+	}
+	if hasCertFile || cert != "" {
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+
+	cfg.ServerName, _ = dipper.GetMapDataStr(tlsOpts, "server_name")
+	//nolint:gosec
+	cfg.InsecureSkipVerify, _ = dipper.GetMapDataBool(tlsOpts, "insecure")
+
+	return cfg
+}
+
+// getRetryPolicy reads the retry parameter from the payload, requests are not retried by default.
+func getRetryPolicy(payload interface{}) *retryPolicy {
+	policy := &retryPolicy{
+		backoff:    DefaultBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+
+	if attempts, ok := dipper.GetMapData(payload, "retry.attempts"); ok {
+		policy.attempts = dipper.Must(strconv.Atoi(fmt.Sprint(attempts))).(int)
+	}
+	if backoff, ok := dipper.GetMapDataStr(payload, "retry.backoff"); ok {
+		policy.backoff = dipper.Must(time.ParseDuration(backoff)).(time.Duration)
+	}
+	if maxBackoff, ok := dipper.GetMapDataStr(payload, "retry.maxBackoff"); ok {
+		policy.maxBackoff = dipper.Must(time.ParseDuration(maxBackoff)).(time.Duration)
+	}
+	policy.nonIdempotent, _ = dipper.GetMapDataBool(payload, "retry.nonIdempotent")
+
+	policy.statusCodes = map[int]bool{}
+	if codes, ok := dipper.GetMapData(payload, "retry.statusCodes"); ok {
+		for _, code := range codes.([]interface{}) {
+			policy.statusCodes[dipper.Must(strconv.Atoi(fmt.Sprint(code))).(int)] = true
+		}
+	} else {
+		for _, code := range DefaultRetryStatusCodes {
+			policy.statusCodes[code] = true
+		}
+	}
+
+	return policy
+}
+
+// delay returns the time to wait before the given retry, the Retry-After header in seconds is honored if present,
+// both are limited by the maxBackoff.
+func (p *retryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	d := p.backoff
+	for i := 1; i < retry && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if resp != nil {
+		if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && after >= 0 {
+			d = time.Duration(after) * time.Second
+		}
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+
+	return d
+}
+
+// retryOnError tells if the request can be retried on network errors. The request may have reached the server before
+// the error, so only the idempotent methods are retried, unless nonIdempotent is set in the policy.
+func (p *retryPolicy) retryOnError(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return p.nonIdempotent
+}
+
+// doRequest sends the request, retries on network errors and the retryable status codes following the policy, and
+// returns the response data.
+func doRequest(client *http.Client, req *http.Request, policy *retryPolicy) map[string]interface{} {
+	for retry := 0; ; retry++ {
+		if retry > 0 && req.GetBody != nil {
+			req.Body = dipper.Must(req.GetBody()).(io.ReadCloser)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if retry >= policy.attempts || !policy.retryOnError(req.Method) {
+				panic(err)
+			}
+			log.Warningf("[%s] retrying request to %s: %+v", driver.Service, req.URL.Redacted(), err)
+			sleep(policy.delay(retry+1, nil))
+
+			continue
+		}
+
+		if policy.statusCodes[resp.StatusCode] && retry < policy.attempts {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			log.Warningf("[%s] retrying request to %s: status code %d", driver.Service, req.URL.Redacted(), resp.StatusCode)
+			sleep(policy.delay(retry+1, resp))
+
+			continue
+		}
+
+		defer resp.Body.Close()
+
+		return extractHTTPResponseData(resp)
+	}
+}
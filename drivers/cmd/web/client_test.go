@@ -0,0 +1,190 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestGetClient(t *testing.T) {
+	defer resetTransports()
+
+	client := getClient(map[string]interface{}{})
+	assert.Equal(t, DefaultTimeout, client.Timeout, "should use the default timeout")
+	assert.Nil(t, client.Transport, "should use the default transport without tls or proxy")
+
+	driver.Options = map[string]interface{}{"data": map[string]interface{}{"timeout": "10s"}}
+	defer func() { driver.Options = nil }()
+	client = getClient(map[string]interface{}{})
+	assert.Equal(t, 10*time.Second, client.Timeout, "should use the timeout from driver options")
+
+	client = getClient(map[string]interface{}{"timeout": "2s", "proxy": "http://proxy.example.com:3128"})
+	assert.Equal(t, 2*time.Second, client.Timeout, "should use the timeout from the request")
+	assert.NotNil(t, client.Transport, "should create a transport for the proxy")
+
+	again := getClient(map[string]interface{}{"proxy": "http://proxy.example.com:3128"})
+	assert.Same(t, client.Transport, again.Transport, "should reuse the transport for the same proxy")
+
+	noProxy := getClient(map[string]interface{}{"proxy": ProxyNone})
+	assert.Nil(t, noProxy.Transport.(*http.Transport).Proxy, "should not use proxy")
+}
+
+func TestSendRequestTLS(t *testing.T) {
+	defer resetTransports()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"secure": true}`))
+	}))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL": server.URL,
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	assert.Panics(t, func() { sendRequest(request) }, "should not trust the server without the CA")
+
+	request.Payload = map[string]interface{}{
+		"URL": server.URL,
+		"tls": map[string]interface{}{"ca": string(ca)},
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, "200", response.Payload.(map[string]interface{})["status_code"])
+	assert.Equal(t, map[string]interface{}{"secure": true}, response.Payload.(map[string]interface{})["json"])
+
+	assert.Panics(t, func() { getTLSConfig(map[string]interface{}{"ca": "invalid"}) }, "should panic with invalid CA")
+	assert.Panics(t, func() { getTLSConfig(map[string]interface{}{"cert_file": "missing"}) }, "should panic with missing cert")
+}
+
+func TestSendRequestProxy(t *testing.T) {
+	defer resetTransports()
+
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL":   "http://example.com/test",
+			"proxy": proxy.URL,
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, "200", response.Payload.(map[string]interface{})["status_code"])
+	assert.Equal(t, "http://example.com/test", proxied, "should send the request through the proxy")
+}
+
+func TestSendRequestRetry(t *testing.T) {
+	defer gock.Off()
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	gock.New("http://example.com").Post("/test").Reply(503)
+	gock.New("http://example.com").Post("/test").Reply(429).SetHeader("Retry-After", "3")
+	gock.New("http://example.com").Post("/test").BodyString("key=value").Reply(200).JSON(map[string]string{"foo": "bar"})
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL":    "http://example.com/test",
+			"method": "POST",
+			"form":   map[string]interface{}{"key": "value"},
+			"retry":  map[string]interface{}{"attempts": "3"},
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, "200", response.Payload.(map[string]interface{})["status_code"])
+	assert.Equal(t, []time.Duration{time.Second, 3 * time.Second}, delays, "should back off and honor Retry-After")
+	assert.True(t, gock.IsDone(), "should retry until success")
+
+	delays = nil
+	gock.New("http://example.com").Get("/test").Reply(500)
+	request.Payload = map[string]interface{}{
+		"URL":   "http://example.com/test",
+		"retry": map[string]interface{}{"attempts": 2},
+	}
+	sendRequest(request)
+	response = <-request.Reply
+	assert.Equal(t, "500", response.Payload.(map[string]interface{})["status_code"])
+	assert.Contains(t, response.Labels, "error")
+	assert.Empty(t, delays, "should not retry status codes not in the list")
+
+	gock.New("http://example.com").Get("/test").Times(2).ReplyError(http.ErrHandlerTimeout)
+	request.Payload = map[string]interface{}{
+		"URL":   "http://example.com/test",
+		"retry": map[string]interface{}{"attempts": 1},
+	}
+	assert.Panics(t, func() { sendRequest(request) }, "should panic when out of attempts")
+	assert.Len(t, delays, 1, "should retry on network errors")
+
+	delays = nil
+	gock.New("http://example.com").Post("/test").ReplyError(http.ErrHandlerTimeout)
+	request.Payload = map[string]interface{}{
+		"URL":    "http://example.com/test",
+		"method": "POST",
+		"retry":  map[string]interface{}{"attempts": 1},
+	}
+	assert.Panics(t, func() { sendRequest(request) }, "should panic on network errors")
+	assert.Empty(t, delays, "should not retry non-idempotent requests on network errors by default")
+
+	gock.New("http://example.com").Post("/test").ReplyError(http.ErrHandlerTimeout)
+	gock.New("http://example.com").Post("/test").Reply(200)
+	request.Payload = map[string]interface{}{
+		"URL":    "http://example.com/test",
+		"method": "POST",
+		"retry":  map[string]interface{}{"attempts": 1, "nonIdempotent": true},
+	}
+	sendRequest(request)
+	response = <-request.Reply
+	assert.Equal(t, "200", response.Payload.(map[string]interface{})["status_code"])
+	assert.Len(t, delays, 1, "should retry non-idempotent requests on network errors when allowed")
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := getRetryPolicy(map[string]interface{}{
+		"retry": map[string]interface{}{
+			"attempts":    5,
+			"backoff":     "100ms",
+			"maxBackoff":  "300ms",
+			"statusCodes": []interface{}{500, "502"},
+		},
+	})
+	assert.Equal(t, 5, policy.attempts)
+	assert.Equal(t, map[int]bool{500: true, 502: true}, policy.statusCodes)
+	assert.Equal(t, 100*time.Millisecond, policy.delay(1, nil))
+	assert.Equal(t, 200*time.Millisecond, policy.delay(2, nil))
+	assert.Equal(t, 300*time.Millisecond, policy.delay(3, nil), "should be limited by maxBackoff")
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"60"}}}
+	assert.Equal(t, 300*time.Millisecond, policy.delay(1, resp), "Retry-After should be limited by maxBackoff")
+
+	policy = getRetryPolicy(map[string]interface{}{})
+	assert.Equal(t, 0, policy.attempts, "should not retry by default")
+	assert.True(t, policy.statusCodes[http.StatusServiceUnavailable])
+}
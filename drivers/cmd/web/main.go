@@ -43,6 +43,7 @@ func main() {
 	if driver.Service == "operator" {
 		driver.Reload = func(*dipper.Message) {
 			log = nil
+			resetTransports()
 		} // allow hot reload
 		driver.Commands["request"] = sendRequest
 		driver.Run()
@@ -151,18 +152,17 @@ func sendRequest(m *dipper.Message) {
 	}
 	m = dipper.DeserializePayload(m)
 	req := prepareRequest(m)
+	client := getClient(m.Payload)
+	policy := getRetryPolicy(m.Payload)
 
-	client := http.Client{}
-	resp, err := client.Do(req)
-	if resp != nil {
-		defer resp.Body.Close()
+	response := doRequest(client, req, policy)
+	if paginate, ok := dipper.GetMapData(m.Payload, "paginate"); ok {
+		response = followPages(client, req, policy, response, paginate)
 	}
-	if err != nil {
-		panic(err)
-	}
-
-	response := extractHTTPResponseData(resp)
 	statusCode, _ := strconv.Atoi(response["status_code"].(string))
+	if extract, ok := dipper.GetMapData(m.Payload, "extract"); ok && statusCode < http.StatusBadRequest {
+		response = extractFields(response, extract)
+	}
 	ret := dipper.Message{
 		Payload: response,
 		IsRaw:   false,
@@ -0,0 +1,241 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// PaginateLink is the pagination type for following the "next" url in the Link header.
+	PaginateLink = "link"
+
+	// PaginateCursor is the pagination type for passing the cursor in the response to the next request as a query parameter.
+	PaginateCursor = "cursor"
+
+	// DefaultMaxPages is the default limit of the number of pages to fetch.
+	DefaultMaxPages = 10
+
+	// DefaultCursorParam is the default name of the query parameter for passing the cursor.
+	DefaultCursorParam = "cursor"
+
+	// DefaultItemsPath is the default path to the items in each page.
+	DefaultItemsPath = "json"
+)
+
+var (
+	// sensitiveHeaders are not sent to the next page on a different host, same as following redirects in net/http.
+	sensitiveHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2", "Proxy-Authorization"}
+
+	linkURLRegex = regexp.MustCompile(`^\s*<([^>]*)>`)
+	linkRelRegex = regexp.MustCompile(`;\s*rel="?([^";]*)"?`)
+)
+
+// extractPath gets the value from the data following a JSONPath style expression, e.g. "$.json.items[0].name".
+// A "*" component selects all the elements in a list or a map, and returns a list of the values.
+func extractPath(data interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(path, "$")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	components := []string{}
+	for _, c := range strings.Split(path, ".") {
+		c = strings.Trim(c, `'"`)
+		if c != "" {
+			components = append(components, c)
+		}
+	}
+
+	return walkPath(data, components)
+}
+
+func walkPath(current interface{}, components []string) (interface{}, bool) {
+	for i, c := range components {
+		if c != "*" {
+			next, ok := dipper.GetMapData(current, c)
+			if !ok {
+				return nil, false
+			}
+			current = next
+
+			continue
+		}
+
+		var elements []reflect.Value
+		v := reflect.ValueOf(current)
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			for j := 0; j < v.Len(); j++ {
+				elements = append(elements, v.Index(j))
+			}
+		case reflect.Map:
+			keys := v.MapKeys()
+			sort.Slice(keys, func(a, b int) bool { return fmt.Sprint(keys[a]) < fmt.Sprint(keys[b]) })
+			for _, k := range keys {
+				elements = append(elements, v.MapIndex(k))
+			}
+		default:
+			return nil, false
+		}
+
+		ret := []interface{}{}
+		for _, e := range elements {
+			if found, ok := walkPath(e.Interface(), components[i+1:]); ok {
+				ret = append(ret, found)
+			}
+		}
+
+		return ret, true
+	}
+
+	return current, true
+}
+
+// extractFields replaces the response data with only the status code and the extracted fields.
+func extractFields(response map[string]interface{}, extract interface{}) map[string]interface{} {
+	extracted := map[string]interface{}{}
+	for name, path := range extract.(map[string]interface{}) {
+		if value, ok := extractPath(response, path.(string)); ok {
+			extracted[name] = value
+		} else {
+			log.Debugf("[%s] path %s not found in the response", driver.Service, path)
+		}
+	}
+
+	return map[string]interface{}{
+		"status_code": response["status_code"],
+		"extracted":   extracted,
+	}
+}
+
+// nextLink returns the url with "next" relation in the Link header.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		matches := linkURLRegex.FindStringSubmatch(link)
+		if matches == nil {
+			continue
+		}
+		for _, rel := range linkRelRegex.FindAllStringSubmatch(link, -1) {
+			for _, r := range strings.Fields(rel[1]) {
+				if r == "next" {
+					return matches[1]
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// nextPage creates the request for the next page, or returns nil if there are no more pages.
+func nextPage(req *http.Request, response map[string]interface{}, paginate interface{}) *http.Request {
+	pageType, ok := dipper.GetMapDataStr(paginate, "type")
+	if !ok {
+		pageType = PaginateLink
+	}
+
+	var next *http.Request
+	switch pageType {
+	case PaginateLink:
+		link := nextLink(response["headers"].(http.Header).Get("Link"))
+		if link == "" {
+			return nil
+		}
+		u, err := req.URL.Parse(link)
+		if err != nil {
+			log.Panicf("[%s] invalid next page url: %+v", driver.Service, err)
+		}
+		next = req.Clone(req.Context())
+		next.URL = u
+		// same as redirects, the credentials are not sent to another host or over a downgraded connection
+		if !strings.EqualFold(u.Host, req.URL.Host) || (req.URL.Scheme == "https" && u.Scheme != "https") {
+			for _, h := range sensitiveHeaders {
+				next.Header.Del(h)
+			}
+		}
+	case PaginateCursor:
+		cursorPath := dipper.MustGetMapDataStr(paginate, "cursor")
+		cursor, ok := extractPath(response, cursorPath)
+		if !ok || cursor == nil || fmt.Sprint(cursor) == "" {
+			return nil
+		}
+		param, ok := dipper.GetMapDataStr(paginate, "param")
+		if !ok {
+			param = DefaultCursorParam
+		}
+		u := *req.URL
+		query := u.Query()
+		query.Set(param, fmt.Sprint(cursor))
+		u.RawQuery = query.Encode()
+		next = req.Clone(req.Context())
+		next.URL = &u
+	default:
+		log.Panicf("[%s] unknown pagination type: %s", driver.Service, pageType)
+	}
+
+	next.Host = next.URL.Host
+	if req.GetBody != nil {
+		next.Body = dipper.Must(req.GetBody()).(io.ReadCloser)
+	}
+
+	return next
+}
+
+// followPages fetches the following pages, and combines the items from all the pages into the json of the last page
+// response. The response of a failed page is returned as is.
+func followPages(
+	client *http.Client,
+	req *http.Request,
+	policy *retryPolicy,
+	response map[string]interface{},
+	paginate interface{},
+) map[string]interface{} {
+	maxPages := DefaultMaxPages
+	if n, ok := dipper.GetMapData(paginate, "maxPages"); ok {
+		maxPages = dipper.Must(strconv.Atoi(fmt.Sprint(n))).(int)
+	}
+	itemsPath, ok := dipper.GetMapDataStr(paginate, "items")
+	if !ok {
+		itemsPath = DefaultItemsPath
+	}
+
+	items := []interface{}{}
+	pages := 0
+	for {
+		if statusCode, _ := strconv.Atoi(response["status_code"].(string)); statusCode >= http.StatusBadRequest {
+			return response
+		}
+		pages++
+		switch v, _ := extractPath(response, itemsPath); page := v.(type) {
+		case []interface{}:
+			items = append(items, page...)
+		case nil:
+		default:
+			items = append(items, page)
+		}
+
+		if pages >= maxPages {
+			break
+		}
+		if req = nextPage(req, response, paginate); req == nil {
+			break
+		}
+		response = doRequest(client, req, policy)
+	}
+
+	response["json"] = items
+	response["pages"] = pages
+
+	return response
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestExtractPath(t *testing.T) {
+	data := map[string]interface{}{
+		"status_code": "200",
+		"headers":     http.Header{"X-Request-Id": []string{"abc"}},
+		"json": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "foo", "id": 1},
+				map[string]interface{}{"name": "bar", "id": 2},
+				map[string]interface{}{"id": 3},
+			},
+			"labels": map[string]interface{}{"b": "2", "a": "1"},
+		},
+	}
+
+	cases := map[string]interface{}{
+		"$.status_code":                "200",
+		"json.items[0].name":           "foo",
+		"$.json.items.1.id":            2,
+		"$.json.items[*].name":         []interface{}{"foo", "bar"},
+		"json.labels.*":                []interface{}{"1", "2"},
+		"$.headers.X-Request-Id":       []string{"abc"},
+		"$['json']['items'][2]":        map[string]interface{}{"id": 3},
+		"$.headers['X-Request-Id'][0]": "abc",
+	}
+	for path, expected := range cases {
+		value, ok := extractPath(data, path)
+		assert.True(t, ok, "path %s should be found", path)
+		assert.Equal(t, expected, value, "path %s", path)
+	}
+
+	_, ok := extractPath(data, "$.json.missing")
+	assert.False(t, ok, "missing path should not be found")
+	_, ok = extractPath(data, "$.status_code[*]")
+	assert.False(t, ok, "wildcard on scalar should not be found")
+}
+
+func TestNextLink(t *testing.T) {
+	assert.Equal(t, "https://api.example.com/items?page=2",
+		nextLink(`<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=5>; rel="last"`))
+	assert.Equal(t, "/items?page=3", nextLink(`<https://api.example.com/items?page=1>; rel="prev", </items?page=3>; rel=next`))
+	assert.Equal(t, "", nextLink(`<https://api.example.com/items?page=5>; rel="last"`))
+	assert.Equal(t, "", nextLink(""))
+}
+
+func TestSendRequestPaginateLink(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").MatchParam("q", "x").
+		Reply(200).SetHeader("Link", `</items?q=x&page=2>; rel="next"`).JSON([]string{"a", "b"})
+	gock.New("http://example.com").Get("/items").MatchParam("page", "2").
+		Reply(200).SetHeader("Link", `<http://example.com/items?q=x&page=3>; rel="next"`).JSON([]string{"c"})
+	gock.New("http://example.com").Get("/items").MatchParam("page", "3").
+		Reply(200).JSON([]string{"d"})
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL":      "http://example.com/items?q=x",
+			"paginate": map[string]interface{}{},
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, []interface{}{"a", "b", "c", "d"}, response.Payload.(map[string]interface{})["json"])
+	assert.Equal(t, 3, response.Payload.(map[string]interface{})["pages"])
+	assert.True(t, gock.IsDone())
+
+	gock.New("http://example.com").Get("/items").
+		Reply(200).SetHeader("Link", `</items?page=2>; rel="next"`).JSON([]string{"a"})
+	gock.New("http://example.com").Get("/items").MatchParam("page", "2").
+		Reply(200).SetHeader("Link", `</items?page=3>; rel="next"`).JSON([]string{"b"})
+	request.Payload = map[string]interface{}{
+		"URL":      "http://example.com/items",
+		"paginate": map[string]interface{}{"maxPages": "2"},
+	}
+	sendRequest(request)
+	response = <-request.Reply
+	assert.Equal(t, []interface{}{"a", "b"}, response.Payload.(map[string]interface{})["json"], "should stop at maxPages")
+	assert.True(t, gock.IsDone())
+}
+
+func TestSendRequestPaginateLinkOtherHost(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").MatchHeader("Authorization", "Bearer secret").
+		Reply(200).SetHeader("Link", `<http://example.com/items?page=2>; rel="next"`).JSON([]string{"a"})
+	gock.New("http://example.com").Get("/items").MatchParam("page", "2").MatchHeader("Authorization", "Bearer secret").
+		Reply(200).SetHeader("Link", `<http://other.example.com/items?page=3>; rel="next"`).JSON([]string{"b"})
+	gock.New("http://other.example.com").Get("/items").MatchParam("page", "3").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("Authorization") == "" && req.Header.Get("Cookie") == "", nil
+		}).
+		MatchHeader("X-Trace", "1").
+		Reply(200).JSON([]string{"c"})
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL":      "http://example.com/items",
+			"header":   map[string]interface{}{"Authorization": "Bearer secret", "Cookie": "session=1", "X-Trace": "1"},
+			"paginate": map[string]interface{}{},
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, []interface{}{"a", "b", "c"}, response.Payload.(map[string]interface{})["json"])
+	assert.True(t, gock.IsDone(), "should not send the credentials to the other host")
+}
+
+func TestSendRequestPaginateLinkDowngrade(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://example.com").Get("/items").MatchHeader("Authorization", "Bearer secret").
+		Reply(200).SetHeader("Link", `<http://example.com/items?page=2>; rel="next"`).JSON([]string{"a"})
+	gock.New("http://example.com").Get("/items").MatchParam("page", "2").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("Authorization") == "" && req.Header.Get("Cookie") == "", nil
+		}).
+		Reply(200).JSON([]string{"b"})
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL":      "https://example.com/items",
+			"header":   map[string]interface{}{"Authorization": "Bearer secret", "Cookie": "session=1"},
+			"paginate": map[string]interface{}{},
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, []interface{}{"a", "b"}, response.Payload.(map[string]interface{})["json"])
+	assert.True(t, gock.IsDone(), "should not send the credentials over plain http")
+}
+
+func TestSendRequestPaginateCursor(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").
+		Reply(200).JSON(map[string]interface{}{"data": []string{"a"}, "next": "c1"})
+	gock.New("http://example.com").Get("/items").MatchParam("after", "c1").
+		Reply(200).JSON(map[string]interface{}{"data": []string{"b"}, "next": ""})
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL": "http://example.com/items",
+			"paginate": map[string]interface{}{
+				"type":   PaginateCursor,
+				"cursor": "$.json.next",
+				"param":  "after",
+				"items":  "$.json.data",
+			},
+			"extract": map[string]interface{}{
+				"all": "$.json",
+			},
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, map[string]interface{}{
+		"status_code": "200",
+		"extracted":   map[string]interface{}{"all": []interface{}{"a", "b"}},
+	}, response.Payload)
+	assert.True(t, gock.IsDone())
+
+	gock.New("http://example.com").Get("/items").
+		Reply(200).JSON(map[string]interface{}{"data": []string{"a"}, "next": "c1"})
+	gock.New("http://example.com").Get("/items").MatchParam("after", "c1").
+		Reply(500).BodyString("broken")
+	sendRequest(request)
+	response = <-request.Reply
+	assert.Equal(t, "500", response.Payload.(map[string]interface{})["status_code"])
+	assert.Equal(t, "broken", response.Payload.(map[string]interface{})["body"], "should return the failed page as is")
+	assert.Contains(t, response.Labels, "error")
+}
+
+func TestSendRequestExtract(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/test").
+		Reply(200).JSON(map[string]interface{}{"user": map[string]interface{}{"name": "foo", "roles": []string{"a", "b"}}})
+
+	request := &dipper.Message{
+		Payload: map[string]interface{}{
+			"URL": "http://example.com/test",
+			"extract": map[string]interface{}{
+				"name":    "$.json.user.name",
+				"role":    "$.json.user.roles[0]",
+				"missing": "$.json.user.email",
+			},
+		},
+		Reply: make(chan dipper.Message, 1),
+	}
+	sendRequest(request)
+	response := <-request.Reply
+	assert.Equal(t, map[string]interface{}{
+		"status_code": "200",
+		"extracted":   map[string]interface{}{"name": "foo", "role": "a"},
+	}, response.Payload)
+}
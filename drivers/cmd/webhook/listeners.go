@@ -0,0 +1,293 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// DefaultAddr is the default listening address and port of the webhook.
+	DefaultAddr = ":8080"
+
+	// DefaultMaxBodySize is the default limit of the request body size in bytes.
+	DefaultMaxBodySize int64 = 10 << 20
+)
+
+// CORS is the cross-origin resource sharing settings of a listener.
+type CORS struct {
+	AllowOrigins []string `mapstructure:"allow_origins"`
+	AllowMethods []string `mapstructure:"allow_methods"`
+	AllowHeaders []string `mapstructure:"allow_headers"`
+	MaxAge       int      `mapstructure:"max_age"`
+}
+
+// Listener is a http listener for receiving the webhook requests.
+type Listener struct {
+	Addr        string                 `mapstructure:"addr"`
+	TLS         map[string]interface{} `mapstructure:"tls"`
+	MaxBodySize int64                  `mapstructure:"max_body_size"`
+	CORS        *CORS                  `mapstructure:"cors"`
+
+	server *http.Server
+	done   chan struct{}
+}
+
+var (
+	listeners     []*Listener
+	listenersLock sync.Mutex
+
+	// ListenerRestartDelay is the time to wait before restarting a listener that stopped unexpectedly.
+	ListenerRestartDelay = time.Second
+)
+
+// loadListeners reads the listeners from the options, the "Addr" and "tls" options are used as the only listener
+// when "listeners" are not defined.
+func loadListeners() []*Listener {
+	var newListeners []*Listener
+	if cfg, ok := driver.GetOption("data.listeners"); ok {
+		dipper.Must(mapstructure.Decode(cfg, &newListeners))
+	} else {
+		l := &Listener{}
+		l.Addr, _ = driver.GetOptionStr("data.Addr")
+		tlsOpts, _ := driver.GetOption("data.tls")
+		l.TLS, _ = tlsOpts.(map[string]interface{})
+		newListeners = []*Listener{l}
+	}
+
+	maxBodySize := DefaultMaxBodySize
+	if size, ok := driver.GetOption("data.max_body_size"); ok {
+		maxBodySize = dipper.Must(strconv.ParseInt(fmt.Sprint(size), 10, 64)).(int64)
+	}
+	var cors *CORS
+	if corsOpts, ok := driver.GetOption("data.cors"); ok {
+		cors = &CORS{}
+		dipper.Must(mapstructure.Decode(corsOpts, cors))
+	}
+	for _, l := range newListeners {
+		if l.Addr == "" {
+			l.Addr = DefaultAddr
+		}
+		if l.MaxBodySize == 0 {
+			l.MaxBodySize = maxBodySize
+		}
+		if l.CORS == nil {
+			l.CORS = cors
+		}
+	}
+
+	return newListeners
+}
+
+// sameListeners checks if the listeners are configured the same way.
+func sameListeners(a []*Listener, b []*Listener) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Addr != b[i].Addr || a[i].MaxBodySize != b[i].MaxBodySize ||
+			!reflect.DeepEqual(a[i].TLS, b[i].TLS) || !reflect.DeepEqual(a[i].CORS, b[i].CORS) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// startListeners starts all the listeners.
+func startListeners() {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	for _, l := range listeners {
+		l.start()
+	}
+}
+
+// stopListeners stops all the listeners, they won't be restarted.
+func stopListeners() {
+	listenersLock.Lock()
+	stopping := listeners
+	listeners = nil
+	listenersLock.Unlock()
+
+	for _, l := range stopping {
+		if l.server != nil {
+			dipper.Must(l.server.Shutdown(context.Background()))
+			<-l.done
+		}
+	}
+}
+
+// shouldRestart checks if the listener is still in use and the driver is not stopped, the caller should hold the lock.
+func (l *Listener) shouldRestart() bool {
+	if driver.State == "stopped" || driver.State == "cold" {
+		return false
+	}
+	for _, active := range listeners {
+		if active == l {
+			return true
+		}
+	}
+
+	return false
+}
+
+// start serves the requests in the background, the listener is restarted if stopped unexpectedly.
+func (l *Listener) start() {
+	l.server = &http.Server{
+		Addr:              l.Addr,
+		Handler:           http.HandlerFunc(l.handle),
+		ReadHeaderTimeout: RequestHeaderTimeoutSecs * time.Second,
+	}
+	if l.TLS != nil {
+		l.server.TLSConfig = getTLSConfig(l.TLS)
+	}
+	server := l.server
+	done := make(chan struct{})
+	l.done = done
+	go func() {
+		defer close(done)
+		log.Infof("[%s] start listening for webhook requests on %s", driver.Service, l.Addr)
+		if server.TLSConfig != nil {
+			log.Infof("[%s] listener stopped: %+v", driver.Service, server.ListenAndServeTLS("", ""))
+		} else {
+			log.Infof("[%s] listener stopped: %+v", driver.Service, server.ListenAndServe())
+		}
+		listenersLock.Lock()
+		restart := l.shouldRestart()
+		listenersLock.Unlock()
+		if restart {
+			// avoid spinning when unable to listen on the address
+			time.Sleep(ListenerRestartDelay)
+			listenersLock.Lock()
+			defer listenersLock.Unlock()
+			if l.shouldRestart() {
+				l.start()
+			}
+		}
+	}()
+}
+
+// handle applies the CORS settings and the body size limit before handling the request.
+func (l *Listener) handle(w http.ResponseWriter, r *http.Request) {
+	if l.CORS != nil && l.CORS.apply(w, r) {
+		return
+	}
+
+	if l.MaxBodySize > 0 && r.Body != nil {
+		if r.ContentLength > l.MaxBodySize {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, l.MaxBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			badRequest(w)
+
+			return
+		}
+		if int64(len(body)) > l.MaxBodySize {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	hookHandler(w, r)
+}
+
+// apply adds the CORS headers for the allowed origins, and returns true if the request is a preflight request
+// that is already responded.
+func (c *CORS) apply(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	allowed := false
+	for _, o := range c.AllowOrigins {
+		if o == "*" || o == origin {
+			allowed = true
+
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+
+	methods := c.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost}
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(c.AllowHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		w.Header().Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+// getTLSConfig creates the TLS config for the listener, the client certificates are verified if given when
+// the client CA is configured.
+func getTLSConfig(tlsOpts map[string]interface{}) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	certFile, _ := dipper.GetMapDataStr(tlsOpts, "cert_file")
+	keyFile, _ := dipper.GetMapDataStr(tlsOpts, "key_file")
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Panicf("[%s] unable to load the key pair for TLS: %+v", driver.Service, err)
+	}
+	cfg.Certificates = []tls.Certificate{cert}
+
+	if caFile, ok := dipper.GetMapDataStr(tlsOpts, "client_ca_file"); ok {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Panicf("[%s] unable to load the client CA: %+v", driver.Service, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			log.Panicf("[%s] no valid CA cert found in %s", driver.Service, caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if required, _ := dipper.GetMapDataBool(tlsOpts, "require_client_cert"); required {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return cfg
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadListeners(t *testing.T) {
+	driver = &dipper.Driver{Options: map[string]interface{}{
+		"data": map[string]interface{}{
+			"Addr": ":8081",
+		},
+	}}
+	ls := loadListeners()
+	assert.Len(t, ls, 1)
+	assert.Equal(t, ":8081", ls[0].Addr, "should use Addr when listeners not defined")
+	assert.Equal(t, DefaultMaxBodySize, ls[0].MaxBodySize)
+	assert.Nil(t, ls[0].CORS)
+
+	driver = &dipper.Driver{Options: map[string]interface{}{
+		"data": map[string]interface{}{
+			"max_body_size": float64(1024),
+			"cors":          map[string]interface{}{"allow_origins": []interface{}{"*"}},
+			"listeners": []interface{}{
+				map[string]interface{}{},
+				map[string]interface{}{"addr": ":8443", "max_body_size": float64(64), "tls": map[string]interface{}{"cert_file": "cert.pem"}},
+			},
+		},
+	}}
+	ls = loadListeners()
+	assert.Len(t, ls, 2)
+	assert.Equal(t, DefaultAddr, ls[0].Addr)
+	assert.Equal(t, int64(1024), ls[0].MaxBodySize, "should use the driver level limit")
+	assert.Equal(t, []string{"*"}, ls[0].CORS.AllowOrigins, "should use the driver level CORS")
+	assert.Equal(t, int64(64), ls[1].MaxBodySize)
+	assert.Equal(t, "cert.pem", ls[1].TLS["cert_file"])
+
+	assert.True(t, sameListeners(ls, loadListeners()))
+	assert.False(t, sameListeners(ls, ls[:1]))
+}
+
+func TestListenerHandle(t *testing.T) {
+	healthCheckPath = DefaultHealthCheckPath
+	l := &Listener{
+		MaxBodySize: 4,
+		CORS:        &CORS{AllowOrigins: []string{"https://app.example.com"}, AllowHeaders: []string{"Content-Type"}, MaxAge: 600},
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/hook", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	l.handle(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "should respond to preflight requests")
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, DefaultHealthCheckPath, nil)
+	req.Header.Set("Origin", "https://other.example.com")
+	l.handle(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), "should not allow other origins")
+
+	rec = httptest.NewRecorder()
+	l.handle(rec, httptest.NewRequest(http.MethodPost, DefaultHealthCheckPath, bytes.NewBufferString("1234")))
+	assert.Equal(t, http.StatusOK, rec.Code, "should accept the body within limit")
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, DefaultHealthCheckPath, bytes.NewBufferString("12345"))
+	l.handle(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, "should reject the body over limit")
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, DefaultHealthCheckPath, bytes.NewBufferString("12345"))
+	req.ContentLength = -1
+	l.handle(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, "should reject the body over limit without content length")
+}
+
+func TestMultipleListeners(t *testing.T) {
+	defer func() { driver.State = "stopped"; stopWebhook(nil) }()
+	driver = &dipper.Driver{
+		Options: map[string]interface{}{
+			"dynamicData": map[string]interface{}{"collapsedEvents": map[string]interface{}{}},
+			"data": map[string]interface{}{
+				"healthcheck_path": "/healthz",
+				"listeners": []interface{}{
+					map[string]interface{}{"addr": "127.0.0.1:8997"},
+					map[string]interface{}{"addr": "127.0.0.1:8998"},
+				},
+			},
+		},
+		State: "alive",
+	}
+	startWebhook(nil)
+	// without this the client will send request too early and server is not ready
+	<-time.After(100 * time.Millisecond)
+
+	get := func(u string) int {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, get("http://127.0.0.1:8997/healthz"))
+	assert.Equal(t, http.StatusOK, get("http://127.0.0.1:8998/healthz"))
+	assert.Equal(t, http.StatusNotFound, get("http://127.0.0.1:8998/hz/alive"), "should use the configured health check path")
+
+	driver.Options.(map[string]interface{})["data"].(map[string]interface{})["listeners"] = []interface{}{
+		map[string]interface{}{"addr": "127.0.0.1:8997"},
+	}
+	loadOptions(nil)
+	<-time.After(100 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, get("http://127.0.0.1:8997/healthz"), "should restart the listeners when changed")
+	assert.Equal(t, 0, get("http://127.0.0.1:8998/healthz"), "should stop the removed listener")
+}
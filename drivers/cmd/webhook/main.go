@@ -8,7 +8,6 @@
 package main
 
 import (
-	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -56,14 +55,10 @@ func initFlags() {
 
 var (
 	driver *dipper.Driver
-	server *http.Server
 	hooks  map[string]interface{}
 	sysMap map[string]map[string]interface{}
 )
 
-// Addr : listening address and port of the webhook.
-var Addr string
-
 func main() {
 	initFlags()
 	flag.Parse()
@@ -78,7 +73,7 @@ func main() {
 }
 
 func stopWebhook(*dipper.Message) {
-	dipper.Must(server.Shutdown(context.Background()))
+	stopListeners()
 }
 
 func loadOptions(m *dipper.Message) {
@@ -104,30 +99,34 @@ func loadOptions(m *dipper.Message) {
 		}
 	}
 
-	NewAddr, ok := driver.GetOptionStr("data.Addr")
-	if !ok {
-		NewAddr = ":8080"
+	loadVerifiers()
+	loadRoutes()
+
+	healthCheckPath = DefaultHealthCheckPath
+	if p, ok := driver.GetOptionStr("data.healthcheck_path"); ok {
+		healthCheckPath = p
 	}
-	if driver.State == "alive" && NewAddr != Addr {
-		stopWebhook(m) // the webhook will be restarted automatically in the loop
+
+	// restart the listeners if changed after started
+	newListeners := loadListeners()
+	listenersLock.Lock()
+	changed := listeners != nil && !sameListeners(newListeners, listeners)
+	listenersLock.Unlock()
+	if changed {
+		stopListeners()
+		listenersLock.Lock()
+		listeners = newListeners
+		listenersLock.Unlock()
+		startListeners()
 	}
-	Addr = NewAddr
 }
 
 func startWebhook(m *dipper.Message) {
 	loadOptions(m)
-	server = &http.Server{
-		Addr:              Addr,
-		Handler:           http.HandlerFunc(hookHandler),
-		ReadHeaderTimeout: RequestHeaderTimeoutSecs * time.Second,
-	}
-	go func() {
-		log.Infof("[%s] start listening for webhook requests", driver.Service)
-		log.Infof("[%s] listener stopped: %+v", driver.Service, server.ListenAndServe())
-		if driver.State != "stopped" && driver.State != "cold" {
-			startWebhook(m)
-		}
-	}()
+	listenersLock.Lock()
+	listeners = loadListeners()
+	listenersLock.Unlock()
+	startListeners()
 }
 
 func hookHandler(w http.ResponseWriter, r *http.Request) {
@@ -145,7 +144,7 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	if eventData["url"] == "/hz/alive" {
+	if eventData["url"] == healthCheckPath {
 		w.WriteHeader(http.StatusOK)
 
 		return
@@ -154,29 +153,19 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 	verifySystems(eventData)
 
 	log.Debugf("[%s] webhook event data: %+v", driver.Service, eventData)
-	matched := false
-	for _, hook := range hooks {
-		for _, collapsed := range hook.([]interface{}) {
-			condition, _ := dipper.GetMapData(collapsed, "match")
-
-			if dipper.CompareAll(eventData, condition) {
-				matched = true
-
-				break
-			}
-		}
-		if matched {
-			break
-		}
-	}
-
-	if matched {
+	if matched := routes.find(eventData); matched != nil {
 		id := driver.EmitEvent(map[string]interface{}{
 			"events": []interface{}{"webhook."},
 			"data":   eventData,
 		})
 
-		if _, ok := dipper.GetMapDataStr(eventData, "form.accept_uuid.0"); ok {
+		params, _ := dipper.GetMapData(matched, "parameters")
+		paramsMap, _ := params.(map[string]interface{})
+		if respond, _ := dipper.GetMapDataStr(params, "respond"); respond == RespondSync {
+			respondSync(w, paramsMap, id, eventData)
+		} else if response, ok := paramsMap["response"]; ok {
+			writeResponse(w, response, map[string]interface{}{"eventID": id, "request": eventData}, http.StatusOK, "")
+		} else if _, ok := dipper.GetMapDataStr(eventData, "form.accept_uuid.0"); ok {
 			w.Header().Set("content-type", "application/json")
 			_, _ = w.Write([]byte(fmt.Sprintf("{\"eventID\": \"%s\"}", id)))
 		} else {
@@ -203,7 +192,17 @@ func extractEventData(w http.ResponseWriter, r *http.Request) map[string]interfa
 		}
 	}()
 
-	return dipper.ExtractWebRequest(r)
+	eventData := dipper.ExtractWebRequest(r)
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		eventData["clientCert"] = map[string]interface{}{
+			"subject":    cert.Subject.String(),
+			"commonName": cert.Subject.CommonName,
+			"dnsNames":   cert.DNSNames,
+		}
+	}
+
+	return eventData
 }
 
 func verifySignature(header, actual, secret string, eventData map[string]interface{}) bool {
@@ -254,6 +253,24 @@ func verifySignature(header, actual, secret string, eventData map[string]interfa
 }
 
 func verifySystems(eventData map[string]interface{}) {
+	verifiedSystem := verifySignatureHeaders(eventData)
+	verified := map[string]bool{}
+	for _, name := range verifiedSystem {
+		verified[name] = true
+	}
+	for _, name := range runVerifiers(eventData) {
+		if !verified[name] {
+			verifiedSystem = append(verifiedSystem, name)
+		}
+	}
+	if len(verifiedSystem) > 0 {
+		log.Infof("[%s] verified system(s) %+v", driver.Service, verifiedSystem)
+		eventData["verifiedSystem"] = verifiedSystem
+	}
+}
+
+// verifySignatureHeaders verifies the systems using the well-known signature headers.
+func verifySignatureHeaders(eventData map[string]interface{}) []string {
 	headers := eventData["headers"].(http.Header)
 
 	var signatureHeader, signatureValue string
@@ -267,7 +284,7 @@ func verifySystems(eventData map[string]interface{}) {
 	}
 
 	if signatureHeader == "" {
-		return
+		return nil
 	}
 
 	var verifiedSystem []string
@@ -298,5 +315,6 @@ func verifySystems(eventData map[string]interface{}) {
 		}
 	}
 	log.Infof("[%s] HMAC verified for system(s) %+v", driver.Service, verifiedSystem)
-	eventData["verifiedSystem"] = verifiedSystem
+
+	return verifiedSystem
 }
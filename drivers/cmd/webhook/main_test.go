@@ -203,6 +203,7 @@ func TestHookHandler(t *testing.T) {
 			},
 		},
 	}
+	loadRoutes()
 
 	buf := bytes.NewBuffer(make([]byte, 2048))
 	driver = &dipper.Driver{
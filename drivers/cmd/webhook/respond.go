@@ -0,0 +1,192 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// RespondSync is the value of the respond parameter for holding the request until the sessions complete.
+	RespondSync = "sync"
+
+	// DefaultSyncTimeout is the default time to wait for the sessions before responding with the eventID.
+	DefaultSyncTimeout = 30 * time.Second
+
+	// DefaultAPIURL is the default url for the api service.
+	DefaultAPIURL = "http://localhost:9000/api/"
+)
+
+// ErrSyncResponse is raised when unable to get the session results from the api service.
+var ErrSyncResponse = errors.New("unable to get the session results")
+
+// SyncPollInterval is the interval for retrying when the sessions for the event are not created yet.
+var SyncPollInterval = 500 * time.Millisecond
+
+// waitForSessions calls the eventWait API until the sessions for the event complete or the context is done.
+var waitForSessions = func(ctx context.Context, eventID string) ([]interface{}, error) {
+	apiURL, ok := driver.GetOptionStr("data.api.url")
+	if !ok {
+		apiURL = DefaultAPIURL
+	}
+	url := strings.TrimSuffix(apiURL, "/") + "/events/" + eventID + "/wait"
+	headers, _ := driver.GetOption("data.api.headers")
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyncResponse, err)
+		}
+		if h, ok := headers.(map[string]interface{}); ok {
+			for k, v := range h {
+				req.Header.Set(k, fmt.Sprint(v))
+			}
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyncResponse, err)
+		}
+		results := map[string]interface{}{}
+		decodeErr := json.NewDecoder(resp.Body).Decode(&results)
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			if decodeErr != nil {
+				return nil, fmt.Errorf("%w: %v", ErrSyncResponse, decodeErr)
+			}
+			var sessions []interface{}
+			for name, result := range results {
+				if s, ok := dipper.GetMapData(result, "sessions"); ok {
+					list, ok := s.([]interface{})
+					if !ok {
+						return nil, fmt.Errorf("%w: sessions from %s should be a list", ErrSyncResponse, name)
+					}
+					sessions = append(sessions, list...)
+				}
+			}
+
+			return sessions, nil
+		case http.StatusAccepted:
+			// the api service holds the request up to its write timeout, call again to continue waiting
+			continue
+		case http.StatusNotFound:
+			// the sessions may not be created yet
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%w: %v", ErrSyncResponse, ctx.Err())
+			case <-time.After(SyncPollInterval):
+			}
+		default:
+			return nil, fmt.Errorf("%w: api returns status %d", ErrSyncResponse, resp.StatusCode)
+		}
+	}
+}
+
+// respondSync holds the request until the sessions complete, and renders the response using the exported data.
+func respondSync(w http.ResponseWriter, params map[string]interface{}, eventID string, eventData map[string]interface{}) {
+	timeout := DefaultSyncTimeout
+	if timeoutStr, ok := dipper.GetMapDataStr(params, "timeout"); ok {
+		timeout = dipper.Must(time.ParseDuration(timeoutStr)).(time.Duration)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	sessions, err := waitForSessions(ctx, eventID)
+	if err != nil {
+		log.Warningf("[%s] responding with eventID %s: %+v", driver.Service, eventID, err)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(fmt.Sprintf("{\"eventID\": \"%s\"}", eventID)))
+
+		return
+	}
+
+	status, reason, exported := summarizeSessions(sessions)
+	data := map[string]interface{}{
+		"eventID":  eventID,
+		"request":  eventData,
+		"sessions": sessions,
+		"status":   status,
+		"reason":   reason,
+		"exported": exported,
+	}
+
+	code := http.StatusOK
+	if status != dipper.SUCCESS {
+		code = http.StatusInternalServerError
+	}
+	writeResponse(w, params["response"], data, code, exported)
+}
+
+// writeResponse renders the status, headers and body in the response templates with the data, the defaults are
+// used for the missing parts. The body is encoded as JSON if it is not a string.
+func writeResponse(w http.ResponseWriter, response interface{}, data map[string]interface{}, code int, body interface{}) {
+	if response != nil {
+		rendered := dipper.Interpolate(response, data)
+		if s, ok := dipper.GetMapData(rendered, "status"); ok {
+			code = dipper.Must(strconv.Atoi(fmt.Sprint(s))).(int)
+		}
+		if h, ok := dipper.GetMapData(rendered, "headers"); ok {
+			for k, v := range h.(map[string]interface{}) {
+				w.Header().Set(k, fmt.Sprint(v))
+			}
+		}
+		if b, ok := dipper.GetMapData(rendered, "body"); ok {
+			body = b
+		}
+	}
+
+	var content []byte
+	if s, ok := body.(string); ok {
+		content = []byte(s)
+	} else {
+		content = dipper.Must(json.Marshal(body)).([]byte)
+		if w.Header().Get("content-type") == "" {
+			w.Header().Set("content-type", "application/json")
+		}
+	}
+	w.WriteHeader(code)
+	_, _ = w.Write(content)
+}
+
+// summarizeSessions merges the exported data from the sessions, the status is success only when all sessions succeed.
+func summarizeSessions(sessions []interface{}) (string, string, map[string]interface{}) {
+	status := dipper.SUCCESS
+	reason := ""
+	exported := map[string]interface{}{}
+	for _, s := range sessions {
+		if st, _ := dipper.GetMapDataStr(s, "status"); st != dipper.SUCCESS && status == dipper.SUCCESS {
+			status = st
+			reason, _ = dipper.GetMapDataStr(s, "reason")
+		}
+		if e, ok := dipper.GetMapData(s, "exported"); ok {
+			switch v := e.(type) {
+			case map[string]interface{}:
+				exported = dipper.MergeMap(exported, v)
+			case []interface{}:
+				for _, layer := range v {
+					if m, ok := layer.(map[string]interface{}); ok {
+						exported = dipper.MergeMap(exported, m)
+					}
+				}
+			}
+		}
+	}
+
+	return status, reason, exported
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForSessions(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "/api/events/event1/wait", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusNotFound)
+		case 2:
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"uuid": "abc"}`)
+		default:
+			fmt.Fprint(w, `{"engine1": {"sessions": [{"name": "wf1", "status": "success"}]}}`)
+		}
+	}))
+	defer server.Close()
+
+	origInterval := SyncPollInterval
+	defer func() { SyncPollInterval = origInterval }()
+	SyncPollInterval = time.Millisecond
+	driver = &dipper.Driver{
+		Service: "test",
+		Options: map[string]interface{}{
+			"data": map[string]interface{}{
+				"api": map[string]interface{}{
+					"url":     server.URL + "/api/",
+					"headers": map[string]interface{}{"Authorization": "Bearer token"},
+				},
+			},
+		},
+	}
+
+	sessions, err := waitForSessions(context.Background(), "event1")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls, "should retry on 404 and 202")
+	assert.Equal(t, "wf1", dipper.MustGetMapDataStr(sessions, "0.name"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls = 0
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) })
+	_, err = waitForSessions(ctx, "event1")
+	assert.ErrorIs(t, err, ErrSyncResponse, "should give up when the context is done")
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"engine1": {"sessions": {"name": "wf1"}}}`)
+	})
+	_, err = waitForSessions(context.Background(), "event1")
+	assert.ErrorIs(t, err, ErrSyncResponse, "should not panic with malformed sessions")
+}
+
+func TestRespondSync(t *testing.T) {
+	orig := waitForSessions
+	defer func() { waitForSessions = orig }()
+	waitForSessions = func(ctx context.Context, eventID string) ([]interface{}, error) {
+		return []interface{}{
+			map[string]interface{}{"status": "success", "exported": []interface{}{map[string]interface{}{"text": "hello"}}},
+			map[string]interface{}{"status": "success", "exported": []interface{}{map[string]interface{}{"count": 2}}},
+		}, nil
+	}
+
+	rec := httptest.NewRecorder()
+	respondSync(rec, map[string]interface{}{}, "event1", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("content-type"))
+	assert.JSONEq(t, `{"text": "hello", "count": 2}`, rec.Body.String(), "should return the merged exported data by default")
+
+	rec = httptest.NewRecorder()
+	respondSync(rec, map[string]interface{}{
+		"response": map[string]interface{}{
+			"status":  "201",
+			"headers": map[string]interface{}{"content-type": "text/plain"},
+			"body":    "{{ .exported.text }} from {{ .eventID }} via {{ .request.url }}",
+		},
+	}, "event1", map[string]interface{}{"url": "/hook"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "text/plain", rec.Header().Get("content-type"))
+	assert.Equal(t, "hello from event1 via /hook", rec.Body.String())
+
+	waitForSessions = func(ctx context.Context, eventID string) ([]interface{}, error) {
+		return []interface{}{map[string]interface{}{"status": "failure", "reason": "bad"}}, nil
+	}
+	rec = httptest.NewRecorder()
+	respondSync(rec, map[string]interface{}{}, "event1", nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "should fail when the session fails")
+
+	waitForSessions = func(ctx context.Context, eventID string) ([]interface{}, error) {
+		<-ctx.Done()
+
+		return nil, fmt.Errorf("%w: %v", ErrSyncResponse, ctx.Err())
+	}
+	rec = httptest.NewRecorder()
+	respondSync(rec, map[string]interface{}{"timeout": "10ms"}, "event1", nil)
+	assert.Equal(t, http.StatusAccepted, rec.Code, "should respond with eventID when timed out")
+	assert.JSONEq(t, `{"eventID": "event1"}`, rec.Body.String())
+}
+
+func TestHookHandlerSync(t *testing.T) {
+	orig := waitForSessions
+	defer func() { waitForSessions = orig }()
+	waitForSessions = func(ctx context.Context, eventID string) ([]interface{}, error) {
+		return []interface{}{map[string]interface{}{"status": "success", "exported": []interface{}{map[string]interface{}{"text": "pong"}}}}, nil
+	}
+
+	sysMap = nil
+	hooks = map[string]interface{}{
+		"sys.slash": []interface{}{
+			map[string]interface{}{
+				"match":      map[string]interface{}{"url": "/slash"},
+				"parameters": map[string]interface{}{"respond": "sync", "response": map[string]interface{}{"body": "{{ .exported.text }}"}},
+			},
+		},
+	}
+	loadRoutes()
+	buf := &bytes.Buffer{}
+	driver = &dipper.Driver{Out: buf}
+
+	rec := httptest.NewRecorder()
+	hookHandler(rec, &http.Request{Method: "GET", URL: &url.URL{Path: "/slash"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "pong", rec.Body.String())
+	assert.Equal(t, "webhook.", dipper.MustGetMapDataStr(dipper.FetchMessage(buf).Payload, "events.0"))
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// DefaultHealthCheckPath is the default path for checking the health of the webhook driver.
+const DefaultHealthCheckPath = "/hz/alive"
+
+// routeTable indexes the collapsed hooks by the method and url in their conditions, the hooks without a plain url
+// condition, e.g. a regex, are kept in the fallback list.
+type routeTable struct {
+	routes   map[string][]interface{}
+	fallback []interface{}
+}
+
+var (
+	routes          *routeTable
+	healthCheckPath = DefaultHealthCheckPath
+)
+
+func routeKey(method string, url string) string {
+	return strings.ToUpper(method) + " " + url
+}
+
+// loadRoutes builds the routing table from the hooks.
+func loadRoutes() {
+	table := &routeTable{routes: map[string][]interface{}{}}
+	for _, hook := range hooks {
+		for _, collapsed := range hook.([]interface{}) {
+			url, ok := dipper.GetMapData(collapsed, "match.url")
+			urlStr, isStr := url.(string)
+			if !ok || !isStr {
+				table.fallback = append(table.fallback, collapsed)
+
+				continue
+			}
+			method, _ := dipper.GetMapDataStr(collapsed, "match.method")
+			key := routeKey(method, urlStr)
+			table.routes[key] = append(table.routes[key], collapsed)
+		}
+	}
+	routes = table
+}
+
+// find returns the first hook matching the event data, the candidates are looked up with the method and url, and
+// the rest of the conditions are compared.
+func (t *routeTable) find(eventData map[string]interface{}) interface{} {
+	method, _ := eventData["method"].(string)
+	url, _ := eventData["url"].(string)
+
+	for _, candidates := range [][]interface{}{t.routes[routeKey(method, url)], t.routes[routeKey("", url)], t.fallback} {
+		for _, collapsed := range candidates {
+			condition, _ := dipper.GetMapData(collapsed, "match")
+			if dipper.CompareAll(eventData, condition) {
+				return collapsed
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteTable(t *testing.T) {
+	postHook := map[string]interface{}{"match": map[string]interface{}{"url": "/deploy", "method": "POST", "form": map[string]interface{}{"env": "prod"}}}
+	anyHook := map[string]interface{}{"match": map[string]interface{}{"url": "/deploy"}}
+	regexHook := map[string]interface{}{"match": map[string]interface{}{"url": regexp.MustCompile("^/hooks/.*")}}
+	hooks = map[string]interface{}{
+		"sys1.deploy": []interface{}{postHook, anyHook},
+		"sys2.hooks":  []interface{}{regexHook},
+	}
+	loadRoutes()
+
+	assert.Len(t, routes.routes, 2)
+	assert.Len(t, routes.fallback, 1, "should keep the regex conditions in fallback")
+
+	assert.Equal(t, postHook, routes.find(map[string]interface{}{
+		"url": "/deploy", "method": "POST", "form": url.Values{"env": []string{"prod"}},
+	}), "should look up with method and url")
+	assert.Equal(t, anyHook, routes.find(map[string]interface{}{
+		"url": "/deploy", "method": "POST", "form": url.Values{"env": []string{"dev"}},
+	}), "should compare the rest of the conditions")
+	assert.Equal(t, anyHook, routes.find(map[string]interface{}{"url": "/deploy", "method": "GET"}))
+	assert.Equal(t, regexHook, routes.find(map[string]interface{}{"url": "/hooks/abc", "method": "GET"}))
+	assert.Nil(t, routes.find(map[string]interface{}{"url": "/other", "method": "GET"}))
+}
+
+func TestStaticResponse(t *testing.T) {
+	sysMap = nil
+	hooks = map[string]interface{}{
+		"sys.hook": []interface{}{
+			map[string]interface{}{
+				"match": map[string]interface{}{"url": "/static"},
+				"parameters": map[string]interface{}{
+					"response": map[string]interface{}{
+						"status":  202,
+						"headers": map[string]interface{}{"X-Event-ID": "{{ .eventID }}"},
+						"body":    map[string]interface{}{"challenge": "{{ index .request.form.challenge 0 }}"},
+					},
+				},
+			},
+		},
+	}
+	loadRoutes()
+	buf := &bytes.Buffer{}
+	driver = &dipper.Driver{Out: buf}
+
+	rec := httptest.NewRecorder()
+	hookHandler(rec, &http.Request{Method: "GET", URL: &url.URL{Path: "/static", RawQuery: "challenge=abc"}})
+	msg := dipper.FetchMessage(buf)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, msg.Labels["eventID"], rec.Header().Get("X-Event-ID"))
+	assert.Equal(t, "application/json", rec.Header().Get("content-type"))
+	assert.JSONEq(t, `{"challenge": "abc"}`, rec.Body.String())
+}
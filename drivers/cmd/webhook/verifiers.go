@@ -0,0 +1,483 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// DefaultMaxSkewSecs is the default max difference (in seconds) between the request timestamp and current time.
+	DefaultMaxSkewSecs = 300
+
+	// DefaultHMACPayload is the default template for building the signed payload.
+	DefaultHMACPayload = "{{ .body }}"
+)
+
+// ErrInvalidVerifier is raised when the verifier is not configured properly.
+var ErrInvalidVerifier = errors.New("invalid verifier")
+
+// Verifier verifies if a request is sent from a system.
+type Verifier interface {
+	Verify(eventData map[string]interface{}) bool
+}
+
+// VerifierFactory creates a verifier using the config.
+type VerifierFactory func(cfg map[string]interface{}) (Verifier, error)
+
+// VerifierTypes is the registry for the types of the verifiers, new types can be plugged in with their factories.
+var VerifierTypes = map[string]VerifierFactory{
+	"hmac":  newHMACVerifier,
+	"jwt":   newJWTVerifier,
+	"basic": newBasicVerifier,
+	"mtls":  newMTLSVerifier,
+}
+
+// sysVerifiers is a map from system name to the verifiers configured for the system.
+var sysVerifiers map[string][]Verifier
+
+// loadVerifiers creates the verifiers configured in the "verifiers" field of the systems.
+func loadVerifiers() {
+	sysVerifiers = map[string][]Verifier{}
+	for name, sys := range sysMap {
+		cfgs, ok := sys["verifiers"].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, c := range cfgs {
+			cfg, _ := c.(map[string]interface{})
+			vtype, _ := dipper.GetMapDataStr(cfg, "type")
+			factory, ok := VerifierTypes[vtype]
+			if !ok {
+				log.Warningf("[%s] unsupported verifier type %s for system %s", driver.Service, vtype, name)
+
+				continue
+			}
+			v, err := factory(cfg)
+			if err != nil {
+				log.Warningf("[%s] unable to create %s verifier for system %s: %+v", driver.Service, vtype, name, err)
+
+				continue
+			}
+			sysVerifiers[name] = append(sysVerifiers[name], v)
+		}
+	}
+}
+
+// runVerifiers returns the systems verified by any of their verifiers.
+func runVerifiers(eventData map[string]interface{}) []string {
+	var verified []string
+	for name, verifiers := range sysVerifiers {
+		for _, v := range verifiers {
+			if safeVerify(name, v, eventData) {
+				verified = append(verified, name)
+
+				break
+			}
+		}
+	}
+
+	return verified
+}
+
+func safeVerify(name string, v Verifier, eventData map[string]interface{}) (ret bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Warningf("[%s] verification for system %s failed: %v", driver.Service, name, r)
+			ret = false
+		}
+	}()
+
+	return v.Verify(eventData)
+}
+
+// getSecrets reads a secret or a list of secrets, the list is used for rotating the secrets.
+func getSecrets(cfg map[string]interface{}, key string) ([]string, error) {
+	value, ok := cfg[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is required", ErrInvalidVerifier, key)
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	secrets := make([]string, 0, len(list))
+	for _, s := range list {
+		str, ok := s.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s should be a string or a list of strings", ErrInvalidVerifier, key)
+		}
+		secrets = append(secrets, str)
+	}
+
+	return secrets, nil
+}
+
+// HMACVerifier verifies the HMAC signature of the request in a header.
+type HMACVerifier struct {
+	Header          string
+	Secrets         []string
+	Algorithm       func() hash.Hash
+	Prefix          string
+	Separator       string
+	Encoding        string
+	Payload         string
+	TimestampHeader string
+	MaxSkew         int64
+}
+
+func newHMACVerifier(cfg map[string]interface{}) (Verifier, error) {
+	v := &HMACVerifier{
+		Algorithm: sha256.New,
+		Encoding:  "hex",
+		Payload:   DefaultHMACPayload,
+		MaxSkew:   DefaultMaxSkewSecs,
+	}
+	var ok bool
+	if v.Header, ok = dipper.GetMapDataStr(cfg, "header"); !ok {
+		return nil, fmt.Errorf("%w: header is required", ErrInvalidVerifier)
+	}
+	var err error
+	if v.Secrets, err = getSecrets(cfg, "secret"); err != nil {
+		return nil, err
+	}
+	if algo, ok := dipper.GetMapDataStr(cfg, "algorithm"); ok {
+		switch strings.ToLower(algo) {
+		case "sha1":
+			v.Algorithm = sha1.New
+		case "sha256":
+		case "sha512":
+			v.Algorithm = sha512.New
+		default:
+			return nil, fmt.Errorf("%w: unsupported algorithm %s", ErrInvalidVerifier, algo)
+		}
+	}
+	if encoding, ok := dipper.GetMapDataStr(cfg, "encoding"); ok {
+		if encoding != "hex" && encoding != "base64" {
+			return nil, fmt.Errorf("%w: unsupported encoding %s", ErrInvalidVerifier, encoding)
+		}
+		v.Encoding = encoding
+	}
+	v.Prefix, _ = dipper.GetMapDataStr(cfg, "prefix")
+	v.Separator, _ = dipper.GetMapDataStr(cfg, "separator")
+	if payload, ok := dipper.GetMapDataStr(cfg, "payload"); ok {
+		v.Payload = payload
+	}
+	v.TimestampHeader, _ = dipper.GetMapDataStr(cfg, "timestampHeader")
+	if skew, ok := cfg["maxSkew"]; ok {
+		if v.MaxSkew, err = strconv.ParseInt(fmt.Sprint(skew), 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid maxSkew %v", ErrInvalidVerifier, skew)
+		}
+	}
+
+	return v, nil
+}
+
+// Verify checks the signature against the payload signed with each of the secrets.
+func (v *HMACVerifier) Verify(eventData map[string]interface{}) bool {
+	headers, _ := eventData["headers"].(http.Header)
+	actual := headers.Get(v.Header)
+	if actual == "" {
+		return false
+	}
+
+	body, _ := eventData["body"].([]byte)
+	data := map[string]interface{}{
+		"body":    string(body),
+		"method":  eventData["method"],
+		"url":     eventData["url"],
+		"headers": headers,
+	}
+	if v.TimestampHeader != "" {
+		timestamp := headers.Get(v.TimestampHeader)
+		requestedAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			panic(ErrCalcHash)
+		}
+		if current := time.Now().Unix(); v.MaxSkew > 0 && (current-requestedAt > v.MaxSkew || requestedAt-current > v.MaxSkew) {
+			panic(ErrReplayAttack)
+		}
+		data["timestamp"] = timestamp
+	}
+	payload := fmt.Sprintf("%v", dipper.InterpolateGoTemplate(false, "hmac", v.Payload, data))
+
+	var signatures []string
+	if v.Separator != "" {
+		signatures = strings.Split(actual, v.Separator)
+	} else {
+		signatures = []string{actual}
+	}
+
+	for _, secret := range v.Secrets {
+		mac := hmac.New(v.Algorithm, []byte(secret))
+		dipper.Must(mac.Write([]byte(payload)))
+		expected := mac.Sum(nil)
+		for _, sig := range signatures {
+			if hmac.Equal(expected, v.decode(strings.TrimPrefix(strings.TrimSpace(sig), v.Prefix))) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (v *HMACVerifier) decode(sig string) []byte {
+	var (
+		decoded []byte
+		err     error
+	)
+	if v.Encoding == "base64" {
+		decoded, err = base64.StdEncoding.DecodeString(sig)
+	} else {
+		decoded, err = hex.DecodeString(sig)
+	}
+	if err != nil {
+		return nil
+	}
+
+	return decoded
+}
+
+// JWTVerifier verifies the bearer token in the request using the keys in a local JWKS file.
+type JWTVerifier struct {
+	Header  string
+	Keys    map[string]crypto.PublicKey
+	Options []jwt.ParserOption
+}
+
+func newJWTVerifier(cfg map[string]interface{}) (Verifier, error) {
+	v := &JWTVerifier{Header: "Authorization"}
+	if header, ok := dipper.GetMapDataStr(cfg, "header"); ok {
+		v.Header = header
+	}
+	jwksFile, ok := dipper.GetMapDataStr(cfg, "jwks")
+	if !ok {
+		return nil, fmt.Errorf("%w: jwks is required", ErrInvalidVerifier)
+	}
+	var err error
+	if v.Keys, err = loadJWKS(jwksFile); err != nil {
+		return nil, err
+	}
+
+	v.Options = []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+	}
+	if issuer, ok := dipper.GetMapDataStr(cfg, "issuer"); ok {
+		v.Options = append(v.Options, jwt.WithIssuer(issuer))
+	}
+	if audience, ok := dipper.GetMapDataStr(cfg, "audience"); ok {
+		v.Options = append(v.Options, jwt.WithAudience(audience))
+	}
+	if required, ok := dipper.GetMapDataBool(cfg, "requireExpiration"); !ok || required {
+		v.Options = append(v.Options, jwt.WithExpirationRequired())
+	}
+
+	return v, nil
+}
+
+// Verify parses the bearer token and validates its signature and claims.
+func (v *JWTVerifier) Verify(eventData map[string]interface{}) bool {
+	headers, _ := eventData["headers"].(http.Header)
+	tokenStr := headers.Get(v.Header)
+	if len(tokenStr) > 7 && strings.EqualFold(tokenStr[:7], "bearer ") {
+		tokenStr = tokenStr[7:]
+	}
+	if tokenStr == "" {
+		return false
+	}
+
+	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := v.Keys[kid]; ok {
+			return key, nil
+		}
+		if kid == "" && len(v.Keys) == 1 {
+			for _, key := range v.Keys {
+				return key, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%w: key %s not found", ErrInvalidVerifier, kid)
+	}, v.Options...)
+	if err != nil {
+		log.Debugf("[%s] jwt verification failed: %+v", driver.Service, err)
+
+		return false
+	}
+
+	return token.Valid
+}
+
+// loadJWKS reads the RSA and EC public keys from a JWKS file.
+func loadJWKS(file string) (map[string]crypto.PublicKey, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read jwks file %s: %v", ErrInvalidVerifier, file, err)
+	}
+	jwks := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}{}
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse jwks file %s: %v", ErrInvalidVerifier, file, err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range jwks.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				return nil, fmt.Errorf("%w: invalid RSA key %s in jwks", ErrInvalidVerifier, k.Kid)
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, fmt.Errorf("%w: unsupported curve %s in jwks", ErrInvalidVerifier, k.Crv)
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				return nil, fmt.Errorf("%w: invalid EC key %s in jwks", ErrInvalidVerifier, k.Kid)
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		default:
+			log.Warningf("[%s] ignoring unsupported key type %s in jwks %s", driver.Service, k.Kty, file)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no key found in jwks file %s", ErrInvalidVerifier, file)
+	}
+
+	return keys, nil
+}
+
+// BasicVerifier verifies the username and password in the basic auth header.
+type BasicVerifier struct {
+	Username  string
+	Passwords []string
+}
+
+func newBasicVerifier(cfg map[string]interface{}) (Verifier, error) {
+	v := &BasicVerifier{}
+	var ok bool
+	if v.Username, ok = dipper.GetMapDataStr(cfg, "username"); !ok {
+		return nil, fmt.Errorf("%w: username is required", ErrInvalidVerifier)
+	}
+	var err error
+	if v.Passwords, err = getSecrets(cfg, "password"); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Verify compares the credential in the request with the configured username and passwords.
+func (v *BasicVerifier) Verify(eventData map[string]interface{}) bool {
+	headers, _ := eventData["headers"].(http.Header)
+	username, password, ok := (&http.Request{Header: headers}).BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(v.Username)) != 1 {
+		return false
+	}
+	for _, p := range v.Passwords {
+		if subtle.ConstantTimeCompare([]byte(password), []byte(p)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MTLSVerifier verifies the subject of the client certificate, requires the listener to use TLS with client CA.
+type MTLSVerifier struct {
+	Subject     *regexp.Regexp
+	CommonNames []string
+}
+
+func newMTLSVerifier(cfg map[string]interface{}) (Verifier, error) {
+	v := &MTLSVerifier{}
+	if subject, ok := dipper.GetMapDataStr(cfg, "subject"); ok {
+		var err error
+		if v.Subject, err = regexp.Compile(subject); err != nil {
+			return nil, fmt.Errorf("%w: invalid subject pattern %s: %v", ErrInvalidVerifier, subject, err)
+		}
+	}
+	if _, ok := cfg["commonName"]; ok {
+		var err error
+		if v.CommonNames, err = getSecrets(cfg, "commonName"); err != nil {
+			return nil, err
+		}
+	}
+	if v.Subject == nil && len(v.CommonNames) == 0 {
+		return nil, fmt.Errorf("%w: subject or commonName is required", ErrInvalidVerifier)
+	}
+
+	return v, nil
+}
+
+// Verify matches the verified client certificate against the subject pattern and the common names.
+func (v *MTLSVerifier) Verify(eventData map[string]interface{}) bool {
+	cert, ok := eventData["clientCert"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if v.Subject != nil && !v.Subject.MatchString(cert["subject"].(string)) {
+		return false
+	}
+	if len(v.CommonNames) > 0 {
+		for _, cn := range v.CommonNames {
+			if cn == cert["commonName"] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
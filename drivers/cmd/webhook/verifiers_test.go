@@ -0,0 +1,202 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACVerifier(t *testing.T) {
+	v, err := newHMACVerifier(map[string]interface{}{
+		"header":          "X-Signature",
+		"secret":          []interface{}{"old-secret", "new-secret"},
+		"algorithm":       "sha512",
+		"encoding":        "base64",
+		"prefix":          "v1=",
+		"separator":       ",",
+		"payload":         "{{ .timestamp }}.{{ .body }}",
+		"timestampHeader": "X-Timestamp",
+		"maxSkew":         60,
+	})
+	assert.Nil(t, err)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha512.New, []byte("new-secret"))
+	mac.Write([]byte(now + `.{"test": "value"}`))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	eventData := map[string]interface{}{
+		"body":    []byte(`{"test": "value"}`),
+		"headers": http.Header{"X-Signature": []string{"v1=invalid, v1=" + sig}, "X-Timestamp": []string{now}},
+	}
+	assert.True(t, v.Verify(eventData), "should verify with any of the secrets and signatures")
+
+	eventData["headers"] = http.Header{"X-Signature": []string{"v1=" + sig}, "X-Timestamp": []string{"1622172061"}}
+	assert.PanicsWithError(t, "replay attack detected", func() { v.Verify(eventData) }, "should detect replay attack")
+
+	eventData["headers"] = http.Header{"X-Signature": []string{"v1=" + sig}, "X-Timestamp": []string{now}}
+	eventData["body"] = []byte("tampered")
+	assert.False(t, v.Verify(eventData), "should fail with tampered body")
+
+	_, err = newHMACVerifier(map[string]interface{}{"header": "X-Signature"})
+	assert.ErrorIs(t, err, ErrInvalidVerifier, "should require secret")
+	_, err = newHMACVerifier(map[string]interface{}{"header": "X-Signature", "secret": "s", "algorithm": "md5"})
+	assert.ErrorIs(t, err, ErrInvalidVerifier, "should reject unsupported algorithm")
+}
+
+func TestJWTVerifier(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := map[string]interface{}{
+		"keys": []interface{}{
+			map[string]interface{}{
+				"kty": "RSA",
+				"kid": "key1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, os.WriteFile(jwksFile, dipper.Must(json.Marshal(jwks)).([]byte), 0o600))
+
+	v, err := newJWTVerifier(map[string]interface{}{"jwks": jwksFile, "issuer": "ci", "audience": "honeydipper"})
+	assert.Nil(t, err)
+
+	sign := func(kid string, claims jwt.Claims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+
+		return dipper.Must(token.SignedString(key)).(string)
+	}
+	eventData := func(token string) map[string]interface{} {
+		return map[string]interface{}{"headers": http.Header{"Authorization": []string{"Bearer " + token}}}
+	}
+
+	valid := &jwt.RegisteredClaims{
+		Issuer:    "ci",
+		Audience:  jwt.ClaimStrings{"honeydipper"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	assert.True(t, v.Verify(eventData(sign("key1", valid))), "should verify valid token")
+	assert.False(t, v.Verify(eventData(sign("key2", valid))), "should fail with unknown key")
+	assert.False(t, v.Verify(eventData(sign("key1", &jwt.RegisteredClaims{
+		Issuer:    "other",
+		Audience:  jwt.ClaimStrings{"honeydipper"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}))), "should fail with wrong issuer")
+	assert.False(t, v.Verify(eventData(sign("key1", &jwt.RegisteredClaims{
+		Issuer:    "ci",
+		Audience:  jwt.ClaimStrings{"honeydipper"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	}))), "should fail with expired token")
+	assert.False(t, v.Verify(map[string]interface{}{"headers": http.Header{}}), "should fail without token")
+	noExp := &jwt.RegisteredClaims{Issuer: "ci", Audience: jwt.ClaimStrings{"honeydipper"}}
+	assert.False(t, v.Verify(eventData(sign("key1", noExp))), "should fail without expiration")
+
+	v, err = newJWTVerifier(map[string]interface{}{"jwks": jwksFile, "issuer": "ci", "audience": "honeydipper", "requireExpiration": false})
+	assert.Nil(t, err)
+	assert.True(t, v.Verify(eventData(sign("key1", noExp))), "should allow tokens without expiration when not required")
+
+	_, err = newJWTVerifier(map[string]interface{}{"jwks": filepath.Join(t.TempDir(), "missing.json")})
+	assert.ErrorIs(t, err, ErrInvalidVerifier, "should fail with missing jwks file")
+}
+
+func TestBasicVerifier(t *testing.T) {
+	v, err := newBasicVerifier(map[string]interface{}{"username": "hook", "password": "secret"})
+	assert.Nil(t, err)
+
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth("hook", "secret")
+	assert.True(t, v.Verify(map[string]interface{}{"headers": req.Header}))
+	req.SetBasicAuth("hook", "wrong")
+	assert.False(t, v.Verify(map[string]interface{}{"headers": req.Header}))
+	req.SetBasicAuth("other", "secret")
+	assert.False(t, v.Verify(map[string]interface{}{"headers": req.Header}))
+	assert.False(t, v.Verify(map[string]interface{}{"headers": http.Header{}}))
+}
+
+func TestMTLSVerifier(t *testing.T) {
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ci.example.com", Organization: []string{"Example"}},
+	}
+	req := &http.Request{
+		Method: "POST",
+		URL:    &url.URL{Path: "/hook"},
+		TLS:    &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		Body:   io.NopCloser(bytes.NewBufferString("")),
+	}
+	eventData := extractEventData(&mockResponseWriter{header: http.Header{}}, req)
+	assert.Equal(t, "ci.example.com", dipper.MustGetMapDataStr(eventData, "clientCert.commonName"))
+
+	v, err := newMTLSVerifier(map[string]interface{}{"subject": "O=Example", "commonName": []interface{}{"ci.example.com"}})
+	assert.Nil(t, err)
+	assert.True(t, v.Verify(eventData))
+
+	v, _ = newMTLSVerifier(map[string]interface{}{"commonName": "cd.example.com"})
+	assert.False(t, v.Verify(eventData), "should fail with mismatched common name")
+	assert.False(t, v.Verify(map[string]interface{}{}), "should fail without client certificate")
+
+	_, err = newMTLSVerifier(map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrInvalidVerifier, "should require subject or commonName")
+}
+
+func TestVerifySystems(t *testing.T) {
+	defer func() {
+		sysMap = nil
+		sysVerifiers = nil
+	}()
+	sysMap = map[string]map[string]interface{}{
+		"basic": {
+			"verifiers": []interface{}{
+				map[string]interface{}{"type": "unknown"},
+				map[string]interface{}{"type": "basic", "username": "hook", "password": "secret"},
+			},
+		},
+		"hmac": {
+			"verifiers": []interface{}{
+				map[string]interface{}{"type": "hmac", "header": "X-Signature", "secret": "secret", "timestampHeader": "X-Timestamp"},
+			},
+		},
+	}
+	loadVerifiers()
+	assert.Len(t, sysVerifiers["basic"], 1, "should skip the unsupported verifier")
+
+	req := &http.Request{Header: http.Header{"X-Signature": []string{"00"}, "X-Timestamp": []string{"1622172061"}}}
+	req.SetBasicAuth("hook", "secret")
+	eventData := map[string]interface{}{"headers": req.Header}
+	verifySystems(eventData)
+	assert.Equal(t, []string{"basic"}, eventData["verifiedSystem"], "should verify without being affected by failing verifiers")
+
+	eventData = map[string]interface{}{"headers": http.Header{}}
+	verifySystems(eventData)
+	assert.NotContains(t, eventData, "verifiedSystem")
+}
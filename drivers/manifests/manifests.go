@@ -0,0 +1,42 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+// Package manifests contains the manifests of the drivers shipped with Honeydipper, keyed by the driver short names.
+package manifests
+
+import (
+	"embed"
+	"sort"
+	"strings"
+)
+
+//go:embed *.yaml
+var files embed.FS
+
+// Get returns the manifest shipped with the driver, or false if the driver does not ship one.
+func Get(shortName string) ([]byte, bool) {
+	if shortName == "" || strings.ContainsAny(shortName, "/\\") {
+		return nil, false
+	}
+	content, err := files.ReadFile(shortName + ".yaml")
+	if err != nil {
+		return nil, false
+	}
+
+	return content, true
+}
+
+// Names returns the sorted short names of the drivers that ship a manifest.
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+// Package membus provides in-memory eventbus and broadcast drivers for running all the services in a
+// single process without redis.  The drivers are registered as in-process drivers, memqueue and mempubsub,
+// and share the queues and subscriptions through package level variables.
+package membus
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// EventTopic is the queue for the events from receiver to engine.
+	EventTopic = "events"
+	// CommandTopic is the queue for the commands from engine to operator.
+	CommandTopic = "commands"
+	// ReturnTopic is the prefix of the queues for the returns from operator to engine.
+	ReturnTopic = "return:"
+	// APITopic is the prefix of the queues for the api returns to api service.
+	APITopic = "api:"
+)
+
+// queue is an unbounded FIFO of messages that can be consumed by multiple subscribers.
+type queue struct {
+	lock     sync.Mutex
+	messages []*dipper.Message
+	ready    chan struct{}
+}
+
+var (
+	queues     = map[string]*queue{}
+	queuesLock sync.Mutex
+)
+
+func getQueue(topic string) *queue {
+	queuesLock.Lock()
+	defer queuesLock.Unlock()
+	q, ok := queues[topic]
+	if !ok {
+		q = &queue{ready: make(chan struct{}, 1)}
+		queues[topic] = q
+	}
+
+	return q
+}
+
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) push(msg *dipper.Message) {
+	q.lock.Lock()
+	q.messages = append(q.messages, msg)
+	q.lock.Unlock()
+	q.signal()
+}
+
+// requeue puts back a message that could not be delivered, so it is the next one to be consumed.
+func (q *queue) requeue(msg *dipper.Message) {
+	q.lock.Lock()
+	q.messages = append([]*dipper.Message{msg}, q.messages...)
+	q.lock.Unlock()
+	q.signal()
+}
+
+// pop waits for a message until the done channel is closed, returns nil if done.
+func (q *queue) pop(done <-chan struct{}) *dipper.Message {
+	for {
+		q.lock.Lock()
+		if len(q.messages) > 0 {
+			msg := q.messages[0]
+			q.messages = q.messages[1:]
+			remaining := len(q.messages)
+			q.lock.Unlock()
+			if remaining > 0 {
+				// wake up the other subscribers
+				q.signal()
+			}
+
+			return msg
+		}
+		q.lock.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// driverDone returns a channel that is closed when the in-process driver is unloaded.
+func driverDone(d *dipper.Driver) <-chan struct{} {
+	if pipe, ok := d.Out.(*dipper.MessagePipe); ok {
+		return pipe.Done()
+	}
+
+	return nil
+}
+
+// InjectEvent puts an event into the event queue as if it is emitted by a driver in receiver, returns the event ID.
+func InjectEvent(events []interface{}, data interface{}) string {
+	id := uuid.New().String()
+	getQueue(EventTopic).push(&dipper.Message{
+		Channel: "eventbus",
+		Subject: "message",
+		Labels:  map[string]string{"eventID": id, "from": dipper.GetIP()},
+		Payload: map[string]interface{}{
+			"events": events,
+			"data":   data,
+		},
+	})
+
+	return id
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package membus
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/driver"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	if dipper.Logger == nil {
+		f, _ := os.Create("test.log")
+		defer f.Close()
+		dipper.GetLogger("test service", "DEBUG", f, f)
+	}
+	os.Exit(m.Run())
+}
+
+func startTestDriver(t *testing.T, name string, service string, data interface{}) *driver.Runtime {
+	runtime := driver.NewDriver(name, map[string]interface{}{
+		"name":        name,
+		"type":        "inproc",
+		"handlerData": map[string]interface{}{"shortName": name},
+	}, data, nil)
+	runtime.Start(service)
+	assert.Equal(t, "alive", fetchMessage(t, runtime, "state").Subject, "driver should be alive")
+	t.Cleanup(runtime.Handler.Close)
+
+	return runtime
+}
+
+// fetchMessage returns the next message from the driver on the channel, skipping the others.
+func fetchMessage(t *testing.T, runtime *driver.Runtime, channel string) *dipper.Message {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-runtime.Stream:
+			if msg.Channel == channel && msg.Subject != dipper.StateHandshake {
+				return dipper.DeserializePayload(msg)
+			}
+		case <-timeout:
+			assert.FailNow(t, "expecting message from driver", "channel %s", channel)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := getQueue("test")
+	assert.Same(t, q, getQueue("test"), "should reuse the queue for the topic")
+
+	q.push(&dipper.Message{Subject: "1"})
+	q.push(&dipper.Message{Subject: "2"})
+	first := q.pop(nil)
+	assert.Equal(t, "1", first.Subject)
+	q.requeue(first)
+	assert.Equal(t, "1", q.pop(nil).Subject, "requeued message should be consumed first")
+	assert.Equal(t, "2", q.pop(nil).Subject)
+
+	done := make(chan struct{})
+	close(done)
+	assert.Nil(t, q.pop(done), "should stop waiting when done")
+}
+
+func TestMemQueue(t *testing.T) {
+	receiver := startTestDriver(t, "memqueue", "receiver", nil)
+	engine := startTestDriver(t, "memqueue", "engine", nil)
+	operator := startTestDriver(t, "memqueue", "operator", nil)
+
+	receiver.SendMessage(&dipper.Message{
+		Channel: "eventbus",
+		Subject: "message",
+		Labels:  map[string]string{"eventID": "1"},
+		Payload: map[string]interface{}{"events": []interface{}{"webhook."}},
+	})
+	msg := fetchMessage(t, engine, "eventbus")
+	assert.Equal(t, "message", msg.Subject)
+	assert.Equal(t, "1", msg.Labels["eventID"])
+	assert.Equal(t, map[string]interface{}{"events": []interface{}{"webhook."}}, msg.Payload)
+
+	engine.SendMessage(&dipper.Message{
+		Channel: "eventbus",
+		Subject: "command",
+		Labels:  map[string]string{"sessionID": "s1"},
+		Payload: map[string]interface{}{"function": "test"},
+	})
+	msg = fetchMessage(t, operator, "eventbus")
+	assert.Equal(t, "command", msg.Subject)
+	assert.Equal(t, dipper.GetIP(), msg.Labels["from"], "should record the sender for returning")
+
+	operator.SendMessage(&dipper.Message{
+		Channel: "eventbus",
+		Subject: "return",
+		Labels:  map[string]string{"sessionID": "s1", "from": msg.Labels["from"]},
+	})
+	msg = fetchMessage(t, engine, "eventbus")
+	assert.Equal(t, "return", msg.Subject, "should return to the engine")
+	assert.Equal(t, "s1", msg.Labels["sessionID"])
+
+	id := InjectEvent([]interface{}{"webhook."}, map[string]interface{}{"url": "/health"})
+	msg = fetchMessage(t, engine, "eventbus")
+	assert.Equal(t, id, msg.Labels["eventID"], "should receive injected event")
+	assert.Equal(t, map[string]interface{}{"url": "/health"}, msg.Payload.(map[string]interface{})["data"])
+}
+
+func TestMemPubSub(t *testing.T) {
+	engine := startTestDriver(t, "mempubsub", "engine", nil)
+	operator := startTestDriver(t, "mempubsub", "operator", nil)
+	api := startTestDriver(t, "mempubsub", "api", map[string]interface{}{"topic": "api-broadcast", "channel": "api"})
+
+	operator.SendMessage(&dipper.Message{
+		Channel: "rpc",
+		Subject: "call",
+		Labels:  map[string]string{"method": "send", "rpcID": "1", "caller": "operator"},
+		Payload: map[string]interface{}{
+			"broadcastSubject": "reload",
+			"labels":           map[string]interface{}{"service": "engine"},
+			"data":             map[string]interface{}{"force": "true"},
+		},
+	})
+	msg := fetchMessage(t, engine, "broadcast")
+	assert.Equal(t, "reload", msg.Subject)
+	assert.Equal(t, "engine", msg.Labels["service"])
+	assert.Equal(t, map[string]interface{}{"force": "true"}, msg.Payload)
+	assert.Equal(t, "1", fetchMessage(t, operator, "rpc").Labels["rpcID"], "should return the rpc")
+
+	engine.SendMessage(&dipper.Message{
+		Channel: "rpc",
+		Subject: "call",
+		Labels:  map[string]string{"method": "send", "rpcID": "2", "caller": "engine"},
+		Payload: map[string]interface{}{"broadcastSubject": "reload"},
+	})
+	assert.Equal(t, "reload", fetchMessage(t, operator, "broadcast").Subject, "should broadcast to all services")
+	select {
+	case msg := <-api.Stream:
+		assert.Failf(t, "should not receive broadcast on other topics", "%+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	operator.Handler.Close()
+	operator.Handler.Wait()
+	assert.Eventually(t, func() bool {
+		subscriptionsLock.Lock()
+		defer subscriptionsLock.Unlock()
+
+		return len(subscriptions[DefaultBroadcastTopic]) == 1
+	}, time.Second, 10*time.Millisecond, "should unsubscribe when the driver is unloaded")
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package membus
+
+import (
+	"sync"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// DefaultBroadcastTopic is the topic used when the driver data doesn't specify one.
+const DefaultBroadcastTopic = "honeydipper:broadcast"
+
+var (
+	// subscriptions maps the topics to the subscribed drivers and the channels for delivering the messages.
+	subscriptions     = map[string]map[*dipper.Driver]string{}
+	subscriptionsLock sync.Mutex
+)
+
+func init() {
+	dipper.RegisterInProcDriver("mempubsub", newMemPubSub)
+}
+
+// newMemPubSub creates a broadcast driver that works in the same way as redispubsub, delivering the
+// messages to all the services subscribed to the topic in the process.
+func newMemPubSub(service string) *dipper.Driver {
+	driver := dipper.NewDriver(service, "mempubsub")
+	driver.Start = func(*dipper.Message) {
+		topic, ok := driver.GetOptionStr("data.topic")
+		if !ok {
+			topic = DefaultBroadcastTopic
+		}
+		channel, ok := driver.GetOptionStr("data.channel")
+		if !ok {
+			channel = "broadcast"
+		}
+		subscribeBroadcast(driver, topic, channel)
+	}
+	driver.RPCHandlers["send"] = func(msg *dipper.Message) {
+		msg = dipper.DeserializePayload(msg)
+		labels := map[string]string{}
+		if labelMap, ok := dipper.GetMapData(msg.Payload, "labels"); ok && labelMap != nil {
+			for k, v := range labelMap.(map[string]interface{}) {
+				labels[k], _ = v.(string)
+			}
+		}
+		sendBroadcast(driver, msg, labels)
+	}
+	if service == "operator" {
+		driver.Commands["send"] = func(msg *dipper.Message) {
+			msg = dipper.DeserializePayload(msg)
+			labels := map[string]string{}
+			for k, v := range msg.Labels {
+				labels[k] = v
+			}
+			sendBroadcast(driver, msg, labels)
+		}
+	}
+
+	return driver
+}
+
+func subscribeBroadcast(driver *dipper.Driver, topic string, channel string) {
+	subscriptionsLock.Lock()
+	defer subscriptionsLock.Unlock()
+	if subscriptions[topic] == nil {
+		subscriptions[topic] = map[*dipper.Driver]string{}
+	}
+	subscriptions[topic][driver] = channel
+	dipper.Logger.Infof("[%s] subscribed to broadcast topic: %s", driver.Service, topic)
+
+	if done := driverDone(driver); done != nil {
+		go func() {
+			<-done
+			unsubscribeBroadcast(driver, topic)
+		}()
+	}
+}
+
+func unsubscribeBroadcast(driver *dipper.Driver, topic string) {
+	subscriptionsLock.Lock()
+	defer subscriptionsLock.Unlock()
+	delete(subscriptions[topic], driver)
+	dipper.Logger.Infof("[%s] unsubscribed from broadcast topic: %s", driver.Service, topic)
+}
+
+func sendBroadcast(driver *dipper.Driver, msg *dipper.Message, labels map[string]string) {
+	topic, ok := driver.GetOptionStr("data.topic")
+	if !ok {
+		topic = DefaultBroadcastTopic
+	}
+
+	labels["from"] = dipper.GetIP()
+	subject := dipper.MustGetMapDataStr(msg.Payload, "broadcastSubject")
+	data, _ := dipper.GetMapData(msg.Payload, "data")
+
+	subscriptionsLock.Lock()
+	receivers := map[*dipper.Driver]string{}
+	for d, channel := range subscriptions[topic] {
+		receivers[d] = channel
+	}
+	subscriptionsLock.Unlock()
+
+	for d, channel := range receivers {
+		if service := labels["service"]; service != "" && service != d.Service {
+			continue
+		}
+		deliver(d, &dipper.Message{
+			Channel: channel,
+			Subject: subject,
+			Payload: data,
+			Labels:  labels,
+		})
+	}
+	msg.Reply <- dipper.Message{}
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package membus
+
+import (
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+func init() {
+	dipper.RegisterInProcDriver("memqueue", newMemQueue)
+}
+
+// newMemQueue creates an eventbus driver that relays the messages among the services in the same way as
+// redisqueue, through the in-memory queues.
+func newMemQueue(service string) *dipper.Driver {
+	driver := dipper.NewDriver(service, "memqueue")
+	driver.Features = []string{"eventbus"}
+	driver.Start = func(*dipper.Message) {
+		switch driver.Service {
+		case "engine":
+			go subscribe(driver, EventTopic, "message")
+			go subscribe(driver, ReturnTopic+dipper.GetIP(), "return")
+		case "operator":
+			go subscribe(driver, CommandTopic, "command")
+		case "api":
+			go subscribe(driver, APITopic+dipper.GetIP(), "api")
+		}
+	}
+
+	switch service {
+	case "receiver":
+		driver.MessageHandlers["eventbus:message"] = relay
+	case "engine":
+		driver.MessageHandlers["eventbus:command"] = relay
+	case "operator":
+		driver.MessageHandlers["eventbus:return"] = relay
+	}
+	driver.MessageHandlers["eventbus:api"] = relay
+
+	return driver
+}
+
+func relay(msg *dipper.Message) {
+	if msg.Labels == nil {
+		msg.Labels = map[string]string{}
+	}
+	returnTo := msg.Labels["from"]
+	msg.Labels["from"] = dipper.GetIP()
+	topic := EventTopic
+
+	switch msg.Subject {
+	case "command":
+		topic = CommandTopic
+	case "api":
+		if returnTo == "" {
+			dipper.Logger.Panicf("[memqueue] api return message without receipient")
+		}
+		topic = APITopic + returnTo
+	case "return":
+		if returnTo == "" {
+			dipper.Logger.Panicf("[memqueue] return message without receipient")
+		}
+		topic = ReturnTopic + returnTo
+	}
+
+	getQueue(topic).push(msg)
+}
+
+// subscribe delivers the messages from the queue to the service until the driver is unloaded.
+func subscribe(driver *dipper.Driver, topic string, subject string) {
+	q := getQueue(topic)
+	done := driverDone(driver)
+	dipper.Logger.Infof("[%s] start receiving messages on topic: %s", driver.Service, topic)
+	for {
+		msg := q.pop(done)
+		if msg == nil {
+			dipper.Logger.Infof("[%s] stop receiving messages on topic: %s", driver.Service, topic)
+
+			return
+		}
+		if !deliver(driver, &dipper.Message{
+			Channel: "eventbus",
+			Subject: subject,
+			Labels:  msg.Labels,
+			Payload: msg.Payload,
+			IsRaw:   msg.IsRaw,
+		}) {
+			// the driver is being unloaded, leave the message to the next subscriber
+			q.requeue(msg)
+
+			return
+		}
+	}
+}
+
+func deliver(driver *dipper.Driver, msg *dipper.Message) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			dipper.Logger.Warningf("[%s] unable to deliver message %s:%s: %v", driver.Service, msg.Channel, msg.Subject, r)
+		}
+	}()
+	driver.SendMessage(msg)
+
+	return true
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+// Package rediscache enables Honeydipper to use redis as a temporary
+// external cache storage.
+package rediscache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/honeydipper/honeydipper/drivers/pkg/redisclient"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/op/go-logging"
+)
+
+func init() {
+	dipper.RegisterInProcDriver("redis-cache", New)
+}
+
+// redisCache keeps the state of a redis-cache driver for a service.
+type redisCache struct {
+	driver       *dipper.Driver
+	log          *logging.Logger
+	redisOptions *redisclient.Options
+}
+
+// New creates a redis-cache driver for the given service.
+func New(service string) *dipper.Driver {
+	return newRedisCache(service).driver
+}
+
+func newRedisCache(service string) *redisCache {
+	c := &redisCache{driver: dipper.NewDriver(service, "redis-cache")}
+	c.driver.Start = c.start
+	c.driver.RPCHandlers["save"] = c.save
+	c.driver.RPCHandlers["load"] = c.load
+	c.driver.RPCHandlers["delete"] = c.del
+
+	return c
+}
+
+func (c *redisCache) loadOptions() {
+	c.log = c.driver.GetLogger()
+	c.redisOptions = redisclient.GetRedisOpts(c.driver)
+	c.log.Infof("[%s] receiving driver data %+v", c.driver.Service, c.driver.Options)
+}
+
+func (c *redisCache) start(msg *dipper.Message) {
+	c.loadOptions()
+}
+
+func (c *redisCache) load(msg *dipper.Message) {
+	dipper.DeserializePayload(msg)
+	key := dipper.MustGetMapDataStr(msg.Payload, "key")
+
+	client := redisclient.NewClient(c.redisOptions)
+	defer client.Close()
+	ctx, cancel := c.driver.GetContext()
+	defer cancel()
+	val, err := client.Get(ctx, key).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		msg.Reply <- dipper.Message{}
+	case err != nil:
+		c.log.Panicf("[%s] redis error: %v", c.driver.Service, err)
+	default:
+		msg.Reply <- dipper.Message{
+			Payload: map[string]interface{}{
+				"value": val,
+			},
+		}
+	}
+}
+
+func (c *redisCache) save(msg *dipper.Message) {
+	dipper.DeserializePayload(msg)
+	key := dipper.MustGetMapDataStr(msg.Payload, "key")
+	val := dipper.MustGetMapData(msg.Payload, "value")
+	ttl, _ := dipper.GetMapData(msg.Payload, "ttl")
+	nx, _ := dipper.GetMapData(msg.Payload, "nx")
+
+	var exp time.Duration
+	if ttl != nil {
+		switch t := ttl.(type) {
+		case int64:
+			exp = time.Second * time.Duration(t)
+		case int:
+			exp = time.Second * time.Duration(t)
+		case string:
+			exp = dipper.Must(time.ParseDuration(t)).(time.Duration)
+		default:
+			c.log.Panicf("[%s] redis cache unknown TTL type %+v", c.driver.Service, t)
+		}
+	}
+
+	client := redisclient.NewClient(c.redisOptions)
+	defer client.Close()
+	ctx, cancel := c.driver.GetContext()
+	defer cancel()
+	if dipper.IsTruthy(nx) {
+		saved, err := client.SetNX(ctx, key, val, exp).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			c.log.Panicf("[%s] redis error: %v", c.driver.Service, err)
+		}
+		msg.Reply <- dipper.Message{
+			Payload: map[string]interface{}{
+				"saved": saved,
+			},
+		}
+
+		return
+	}
+	if err := client.Set(ctx, key, val, exp).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		c.log.Panicf("[%s] redis error: %v", c.driver.Service, err)
+	}
+	msg.Reply <- dipper.Message{}
+}
+
+func (c *redisCache) del(msg *dipper.Message) {
+	dipper.DeserializePayload(msg)
+	key := dipper.MustGetMapDataStr(msg.Payload, "key")
+
+	client := redisclient.NewClient(c.redisOptions)
+	defer client.Close()
+	ctx, cancel := c.driver.GetContext()
+	defer cancel()
+	if err := client.Del(ctx, key).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		c.log.Panicf("[%s] redis error: %v", c.driver.Service, err)
+	}
+	msg.Reply <- dipper.Message{}
+}
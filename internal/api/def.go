@@ -41,10 +41,28 @@ func GetDefs() map[string]map[string]Def {
 		"events/:eventID/wait": {
 			http.MethodGet: {Object: "event", Name: "eventWait", ReqType: TypeMatch, Service: "engine", Timeout: InfiniteDuration},
 		},
+		"events/:eventID/progress": {
+			http.MethodGet: {Object: "event", Name: "eventProgress", ReqType: TypeMatch, Service: "engine"},
+		},
 		"events": {
 			http.MethodGet:  {Object: "event", Name: "eventList", ReqType: TypeAll, Service: "engine"},
 			http.MethodPost: {Object: "event", Name: "eventAdd", ReqType: TypeFirst, Service: "receiver"},
 		},
+		"engine/drivers": {
+			http.MethodGet: {Object: "driver", Name: "engineDriverList", ReqType: TypeAll, Service: "engine"},
+		},
+		"operator/drivers": {
+			http.MethodGet: {Object: "driver", Name: "operatorDriverList", ReqType: TypeAll, Service: "operator"},
+		},
+		"receiver/drivers": {
+			http.MethodGet: {Object: "driver", Name: "receiverDriverList", ReqType: TypeAll, Service: "receiver"},
+		},
+		"circuit_breakers": {
+			http.MethodGet: {Object: "circuit_breaker", Name: "circuitBreakerList", ReqType: TypeAll, Service: "operator"},
+		},
+		"systems/:system/cache/invalidate": {
+			http.MethodPost: {Object: "cache", Name: "cacheInvalidate", ReqType: TypeAll, Service: "operator"},
+		},
 	}
 }
 
@@ -19,6 +19,10 @@ type CollapsedTrigger struct {
 	SysName    string                   `json:"sysName"`
 }
 
+// DeferredTriggerParameters are the trigger parameters rendered by the drivers at runtime, so they are kept as is
+// when collapsing the triggers, e.g. the response templates for the webhooks.
+var DeferredTriggerParameters = []string{"response"}
+
 // CollapseTrigger collapses matching criteria, exports and sysData of a trigger and its inheritted triggers.
 func CollapseTrigger(t *Trigger, c *DataSet) (*Trigger, *CollapsedTrigger) {
 	var stack []*Trigger
@@ -51,7 +55,17 @@ func CollapseTrigger(t *Trigger, c *DataSet) (*Trigger, *CollapsedTrigger) {
 			"sysData": sysData,
 		}
 		match = dipper.Interpolate(match, envData).(map[string]interface{})
+		deferred := map[string]interface{}{}
+		for _, key := range DeferredTriggerParameters {
+			if v, ok := params[key]; ok {
+				deferred[key] = v
+				delete(params, key)
+			}
+		}
 		params = dipper.Interpolate(params, envData).(map[string]interface{})
+		for key, v := range deferred {
+			params[key] = v
+		}
 	}
 
 	if flag, ok := params["verifySystem"]; ok && dipper.IsTruthy(flag) {
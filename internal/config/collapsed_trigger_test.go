@@ -12,6 +12,7 @@ package config
 import (
 	"testing"
 
+	"github.com/honeydipper/honeydipper/pkg/dipper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,4 +57,16 @@ func TestReceiverCollapseTrigger(t *testing.T) {
 	extendingTrigger.Match["key1"] = "newval1"
 	_, collapsed = CollapseTrigger(&extendingTrigger, cfg)
 	assert.Equal(t, map[string]interface{}{"key1": "newval1", "key3": "val3"}, collapsed.Match, "should override the match with extending trigger")
+
+	cfg.Systems["testsystem"] = System{
+		Data:     map[string]interface{}{"name": "mysys"},
+		Triggers: cfg.Systems["testsystem"].Triggers,
+	}
+	extendingTrigger.Parameters = map[string]interface{}{
+		"channel":  "{{ .sysData.name }}",
+		"response": map[string]interface{}{"body": "{{ .exported.text }}"},
+	}
+	_, collapsed = CollapseTrigger(&extendingTrigger, cfg)
+	assert.Equal(t, "mysys", collapsed.Parameters["channel"], "should interpolate the parameters with sysData")
+	assert.Equal(t, "{{ .exported.text }}", dipper.MustGetMapDataStr(collapsed.Parameters, "response.body"), "should not interpolate the deferred parameters")
 }
@@ -73,6 +73,7 @@ type Config struct {
 	IsConfigCheck bool
 	CheckRemote   bool
 	IsDocGen      bool
+	IsDevMode     bool
 	DocSrc        string
 	DocDst        string
 	Locker        *sync.Mutex
@@ -144,8 +145,14 @@ func (c *Config) Bootstrap(wd string) {
 }
 
 // Watch runs a loop to periodically check remote git repo, reload if changes are detected.
-// It requires that the OnChange variable is defined prior to invoking the function.
+// It requires that the OnChange variable is defined prior to invoking the function.  In dev mode, it
+// watches the files in the local repos instead, and reloads immediately on changes.
 func (c *Config) Watch() {
+	if c.IsDevMode {
+		c.watchLocalFiles()
+
+		return
+	}
 	for {
 		interval := time.Minute
 		if intervalStr, ok := c.GetStagedDriverDataStr("daemon.configCheckInterval"); ok {
@@ -214,6 +221,9 @@ func (c *Config) RollBack() {
 
 func (c *Config) assemble() {
 	c.Staged, c.Loaded = c.Loaded[c.InitRepo].assemble(&(DataSet{}), map[RepoInfo]*Repo{})
+	if c.IsDevMode {
+		c.useInMemoryDrivers()
+	}
 }
 
 // AdvanceStage processes the config and advances the config into the new stage.
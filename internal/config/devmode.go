@@ -0,0 +1,120 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package config
+
+import (
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// DevWatchInterval is the interval for checking the changes in the local config files in dev mode.
+const DevWatchInterval = time.Second
+
+// DevDriverSubstitutes maps the redis based drivers to the in-memory drivers used in dev mode.
+var DevDriverSubstitutes = map[string]string{
+	"redisqueue":  "memqueue",
+	"redispubsub": "mempubsub",
+}
+
+// useInMemoryDrivers replaces the redis based drivers with the in-process in-memory drivers, and uses memqueue as
+// the eventbus if none is configured, so all the services can run in one process without redis.
+func (c *Config) useInMemoryDrivers() {
+	if c.Staged.Drivers == nil {
+		c.Staged.Drivers = map[string]interface{}{}
+	}
+	daemonData, ok := c.Staged.Drivers["daemon"].(map[string]interface{})
+	if !ok {
+		daemonData = map[string]interface{}{}
+		c.Staged.Drivers["daemon"] = daemonData
+	}
+	drivers, ok := daemonData["drivers"].(map[string]interface{})
+	if !ok {
+		drivers = map[string]interface{}{}
+		daemonData["drivers"] = drivers
+	}
+
+	for name, def := range drivers {
+		shortName, _ := dipper.GetMapDataStr(def, "handlerData.shortName")
+		if substitute, ok := DevDriverSubstitutes[shortName]; ok {
+			dipper.Logger.Infof("[config] using in-memory driver %s for %s in dev mode", substitute, name)
+			drivers[name] = inProcDriverDef(name, substitute)
+		}
+	}
+
+	if _, ok := dipper.GetMapDataStr(daemonData, "featureMap.global.eventbus"); !ok {
+		featureMap, ok := daemonData["featureMap"].(map[string]interface{})
+		if !ok {
+			featureMap = map[string]interface{}{}
+			daemonData["featureMap"] = featureMap
+		}
+		global, ok := featureMap["global"].(map[string]interface{})
+		if !ok {
+			global = map[string]interface{}{}
+			featureMap["global"] = global
+		}
+		global["eventbus"] = "memqueue"
+		if _, ok := drivers["memqueue"]; !ok {
+			drivers["memqueue"] = inProcDriverDef("memqueue", "memqueue")
+		}
+	}
+}
+
+func inProcDriverDef(name string, shortName string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"type":        "inproc",
+		"handlerData": map[string]interface{}{"shortName": shortName},
+	}
+}
+
+// watchLocalFiles reloads the config as soon as the files in the local repos are changed.
+func (c *Config) watchLocalFiles() {
+	last := c.localFilesSnapshot()
+	for {
+		time.Sleep(DevWatchInterval)
+		current := c.localFilesSnapshot()
+		if !reflect.DeepEqual(last, current) {
+			dipper.Logger.Warningf("[config] local config files changed, reloading")
+			c.Refresh()
+			last = current
+		}
+	}
+}
+
+// localFilesSnapshot collects the modification time of all yaml files in the local repos.
+func (c *Config) localFilesSnapshot() map[string]time.Time {
+	snapshot := map[string]time.Time{}
+	for _, repo := range c.Loaded {
+		if !repo.isLocal() {
+			continue
+		}
+		_ = filepath.WalkDir(repo.root, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return nil
+			case d.IsDir() && d.Name() == ".git":
+				return filepath.SkipDir
+			case d.IsDir():
+				return nil
+			}
+			if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+				if info, err := d.Info(); err == nil {
+					snapshot[path] = info.ModTime()
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return snapshot
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUseInMemoryDrivers(t *testing.T) {
+	c := &Config{Staged: &DataSet{Drivers: map[string]interface{}{
+		"daemon": map[string]interface{}{
+			"drivers": map[string]interface{}{
+				"redisqueue":    map[string]interface{}{"name": "redisqueue", "type": "builtin", "handlerData": map[string]interface{}{"shortName": "redisqueue"}},
+				"api-broadcast": map[string]interface{}{"name": "api-broadcast", "type": "builtin", "handlerData": map[string]interface{}{"shortName": "redispubsub"}},
+				"webhook":       map[string]interface{}{"name": "webhook", "type": "builtin", "handlerData": map[string]interface{}{"shortName": "webhook"}},
+			},
+			"featureMap": map[string]interface{}{"global": map[string]interface{}{"eventbus": "redisqueue"}},
+		},
+	}}}
+	c.useInMemoryDrivers()
+
+	eventbus, _ := c.GetStagedDriverData("daemon.drivers.redisqueue")
+	assert.Equal(t, inProcDriverDef("redisqueue", "memqueue"), eventbus, "redisqueue should be replaced with memqueue")
+	broadcast, _ := c.GetStagedDriverData("daemon.drivers.api-broadcast")
+	assert.Equal(t, inProcDriverDef("api-broadcast", "mempubsub"), broadcast, "redispubsub should be replaced with mempubsub")
+	webhook, _ := c.GetStagedDriverDataStr("daemon.drivers.webhook.type")
+	assert.Equal(t, "builtin", webhook, "other drivers should not be changed")
+	feature, _ := c.GetStagedDriverDataStr("daemon.featureMap.global.eventbus")
+	assert.Equal(t, "redisqueue", feature, "should keep the configured eventbus")
+
+	c = &Config{Staged: &DataSet{}}
+	c.useInMemoryDrivers()
+	feature, _ = c.GetStagedDriverDataStr("daemon.featureMap.global.eventbus")
+	assert.Equal(t, "memqueue", feature, "should use memqueue when eventbus is not configured")
+	eventbus, _ = c.GetStagedDriverData("daemon.drivers.memqueue")
+	assert.Equal(t, inProcDriverDef("memqueue", "memqueue"), eventbus)
+}
+
+func TestLocalFilesSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "init.yaml"), []byte("---\n"), 0o600))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0o600))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, ".git"), 0o700))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, ".git", "config.yaml"), []byte("---\n"), 0o600))
+
+	c := &Config{InitRepo: RepoInfo{Repo: dir}, Overrides: map[string]string{}}
+	c.Loaded = map[RepoInfo]*Repo{c.InitRepo: {parent: c, repo: &c.InitRepo, root: dir}}
+	remote := RepoInfo{Repo: "https://example.com/config.git"}
+	c.Loaded[remote] = &Repo{parent: c, repo: &remote, root: t.TempDir()}
+
+	snapshot := c.localFilesSnapshot()
+	assert.Equal(t, []string{filepath.Join(dir, "init.yaml")}, keys(snapshot), "should only watch the yaml files in local repos")
+
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "init.yaml"), later, later))
+	assert.NotEqual(t, snapshot, c.localFilesSnapshot(), "should detect the changes")
+}
+
+func keys(m map[string]time.Time) []string {
+	ret := []string{}
+	for k := range m {
+		ret = append(ret, k)
+	}
+
+	return ret
+}
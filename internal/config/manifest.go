@@ -0,0 +1,258 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/honeydipper/honeydipper/drivers/manifests"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/mitchellh/mapstructure"
+)
+
+var (
+	// ErrManifest means the driver manifest is malformed.
+	ErrManifest = errors.New("invalid driver manifest")
+	// ErrManifestViolation means a call to the driver does not match its manifest.
+	ErrManifestViolation = errors.New("not matching driver manifest")
+)
+
+// DriverManifest describes what a driver offers, shipped with the driver under drivers.daemon.drivers.<name>.manifest.
+type DriverManifest struct {
+	Name        string
+	Description string
+	Services    []string
+	Commands    map[string]ManifestCommand
+	RPCs        map[string]ManifestCommand `json:"rpcs" mapstructure:"rpcs"`
+}
+
+// ManifestCommand describes a command or an RPC method offered by a driver.
+type ManifestCommand struct {
+	Description string
+	Parameters  []ManifestParam
+	Returns     []ManifestParam
+}
+
+// ManifestParam describes a parameter accepted by, or a field returned from, a command.
+type ManifestParam struct {
+	Name        string
+	Type        string
+	Required    bool
+	Default     interface{}
+	Description string
+}
+
+// GetDriverManifest returns the manifest of the driver, or nil if the driver does not ship one.  The manifest can be
+// put inline, or in a separate file with a `@:` file reference.  Otherwise, the manifest shipped with Honeydipper for
+// the handlerData.shortName of the driver is used.
+func GetDriverManifest(ds *DataSet, driverName string) (*DriverManifest, error) {
+	if ds == nil || ds.Drivers == nil {
+		return nil, nil
+	}
+	data, ok := dipper.GetMapData(ds.Drivers, "daemon.drivers."+driverName+".manifest")
+	if !ok || data == nil {
+		shortName, _ := dipper.GetMapDataStr(ds.Drivers, "daemon.drivers."+driverName+".handlerData.shortName")
+		content, shipped := manifests.Get(shortName)
+		if !shipped {
+			return nil, nil
+		}
+		data = string(content)
+	}
+
+	return parseManifest(driverName, data)
+}
+
+// GetShippedManifests returns the manifests shipped with Honeydipper, sorted by the driver short names.
+func GetShippedManifests() []*DriverManifest {
+	ret := []*DriverManifest{}
+	for _, name := range manifests.Names() {
+		content, _ := manifests.Get(name)
+		m, err := parseManifest(name, string(content))
+		if err != nil {
+			dipper.Logger.Warningf("skipping manifest: %v", err)
+
+			continue
+		}
+		ret = append(ret, m)
+	}
+
+	return ret
+}
+
+func parseManifest(driverName string, data interface{}) (*DriverManifest, error) {
+	if content, ok := data.(string); ok {
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(content), &parsed); err != nil {
+			return nil, fmt.Errorf("%w for driver %s: %v", ErrManifest, driverName, err)
+		}
+		data = parsed
+	}
+
+	m := &DriverManifest{}
+	if err := mapstructure.Decode(data, m); err != nil {
+		return nil, fmt.Errorf("%w for driver %s: %v", ErrManifest, driverName, err)
+	}
+	if m.Name == "" {
+		m.Name = driverName
+	}
+	for _, commands := range []map[string]ManifestCommand{m.Commands, m.RPCs} {
+		for name, cmd := range commands {
+			for _, p := range append(cmd.Parameters, cmd.Returns...) {
+				if !validParamType(p.Type) {
+					return nil, fmt.Errorf("%w for driver %s: unknown type %s for %s.%s", ErrManifest, driverName, p.Type, name, p.Name)
+				}
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// GetDriverManifests returns the manifests of all the drivers that ship one, keyed by the driver names.
+func GetDriverManifests(ds *DataSet) map[string]*DriverManifest {
+	ret := map[string]*DriverManifest{}
+	if ds == nil || ds.Drivers == nil {
+		return ret
+	}
+	drivers, _ := dipper.GetMapData(ds.Drivers, "daemon.drivers")
+	driverMap, _ := drivers.(map[string]interface{})
+	for name := range driverMap {
+		m, err := GetDriverManifest(ds, name)
+		if err != nil {
+			dipper.Logger.Warningf("skipping manifest: %v", err)
+
+			continue
+		}
+		if m != nil {
+			ret[name] = m
+		}
+	}
+
+	return ret
+}
+
+// SupportsService checks if the driver can be used in the given service.
+func (m *DriverManifest) SupportsService(service string) bool {
+	if len(m.Services) == 0 {
+		return true
+	}
+	for _, s := range m.Services {
+		if s == service {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckCommand validates a call to the command with the given parameters.  The parameters using interpolation are
+// not checked as the values are only known at runtime.
+func (m *DriverManifest) CheckCommand(command string, params map[string]interface{}) error {
+	cmd, ok := m.Commands[command]
+	if !ok {
+		return fmt.Errorf("%w: driver %s has no command %s", ErrManifestViolation, m.Name, command)
+	}
+
+	missing := []string{}
+	for _, p := range cmd.Parameters {
+		v, ok := params[p.Name]
+		if !ok {
+			if p.Required && p.Default == nil {
+				missing = append(missing, p.Name)
+			}
+
+			continue
+		}
+		if !matchParamType(p.Type, v) {
+			return fmt.Errorf("%w: parameter %s of %s.%s should be %s", ErrManifestViolation, p.Name, m.Name, command, p.Type)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+
+		return fmt.Errorf("%w: %s.%s requires parameter %s", ErrManifestViolation, m.Name, command, strings.Join(missing, ","))
+	}
+
+	return nil
+}
+
+func validParamType(t string) bool {
+	switch t {
+	case "", "any", "string", "int", "number", "bool", "list", "map":
+		return true
+	}
+
+	return false
+}
+
+func matchParamType(t string, v interface{}) bool {
+	if s, ok := v.(string); ok && (strings.HasPrefix(strings.TrimSpace(s), "$") || strings.Contains(s, "{{") || strings.HasPrefix(s, ":yaml:")) {
+		// interpolated at runtime
+		return true
+	}
+
+	if s, ok := v.(string); ok {
+		// the drivers parse the numbers and the flags passed as strings
+		switch t {
+		case "int":
+			_, err := strconv.Atoi(s)
+
+			return err == nil
+		case "number":
+			_, err := strconv.ParseFloat(s, 64)
+
+			return err == nil
+		case "bool":
+			_, err := strconv.ParseBool(s)
+
+			return err == nil
+		}
+	}
+
+	switch t {
+	case "string":
+		_, ok := v.(string)
+
+		return ok
+	case "int":
+		switch n := v.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == math.Trunc(n)
+		}
+
+		return false
+	case "number":
+		switch v.(type) {
+		case int, int64, float64:
+			return true
+		}
+
+		return false
+	case "bool":
+		_, ok := v.(bool)
+
+		return ok
+	case "list":
+		_, ok := v.([]interface{})
+
+		return ok
+	case "map":
+		_, ok := v.(map[string]interface{})
+
+		return ok
+	}
+
+	return true
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package config
+
+import (
+	"testing"
+
+	"github.com/honeydipper/honeydipper/drivers/manifests"
+	"github.com/stretchr/testify/assert"
+)
+
+func testManifestDataSet(manifest interface{}) *DataSet {
+	return &DataSet{Drivers: map[string]interface{}{
+		"daemon": map[string]interface{}{
+			"drivers": map[string]interface{}{
+				"kubernetes": map[string]interface{}{"name": "kubernetes", "manifest": manifest},
+				"web":        map[string]interface{}{"name": "web"},
+			},
+		},
+	}}
+}
+
+func TestGetDriverManifest(t *testing.T) {
+	ds := testManifestDataSet(`
+services: [operator]
+commands:
+  createJob:
+    description: create a job
+    parameters:
+      - name: job
+        type: map
+        required: true
+      - name: namespace
+        type: string
+        default: default
+    returns:
+      - name: metadata
+        type: map
+`)
+	m, err := GetDriverManifest(ds, "kubernetes")
+	assert.Nil(t, err)
+	assert.Equal(t, "kubernetes", m.Name)
+	assert.Equal(t, []string{"operator"}, m.Services)
+	assert.True(t, m.Commands["createJob"].Parameters[0].Required)
+	assert.Equal(t, "default", m.Commands["createJob"].Parameters[1].Default)
+	assert.Equal(t, "metadata", m.Commands["createJob"].Returns[0].Name)
+	assert.True(t, m.SupportsService("operator"))
+	assert.False(t, m.SupportsService("receiver"))
+
+	m, err = GetDriverManifest(ds, "web")
+	assert.Nil(t, err)
+	assert.Nil(t, m, "drivers are not required to ship manifest")
+
+	_, err = GetDriverManifest(testManifestDataSet(map[string]interface{}{
+		"commands": map[string]interface{}{"createJob": map[string]interface{}{
+			"parameters": []interface{}{map[string]interface{}{"name": "job", "type": "object"}},
+		}},
+	}), "kubernetes")
+	assert.ErrorIs(t, err, ErrManifest)
+
+	manifests := GetDriverManifests(ds)
+	assert.Len(t, manifests, 1)
+	assert.Contains(t, manifests, "kubernetes")
+}
+
+func TestGetShippedManifest(t *testing.T) {
+	shipped := GetShippedManifests()
+	assert.Len(t, shipped, len(manifests.Names()), "all the shipped manifests should be valid")
+
+	ds := &DataSet{Drivers: map[string]interface{}{
+		"daemon": map[string]interface{}{
+			"drivers": map[string]interface{}{
+				"api-broadcast": map[string]interface{}{
+					"name":        "api-broadcast",
+					"handlerData": map[string]interface{}{"shortName": "redispubsub"},
+				},
+				"custom": map[string]interface{}{
+					"name":        "custom",
+					"handlerData": map[string]interface{}{"shortName": "custom"},
+				},
+			},
+		},
+	}}
+	m, err := GetDriverManifest(ds, "api-broadcast")
+	assert.Nil(t, err)
+	assert.Equal(t, "api-broadcast", m.Name)
+	assert.Nil(t, m.CheckCommand("send", map[string]interface{}{"broadcastSubject": "reload"}), "should use the manifest shipped for the short name")
+
+	m, err = GetDriverManifest(ds, "custom")
+	assert.Nil(t, err)
+	assert.Nil(t, m, "drivers not shipped with Honeydipper have no manifest")
+}
+
+func TestManifestCheckCommand(t *testing.T) {
+	m := &DriverManifest{Name: "kubernetes", Commands: map[string]ManifestCommand{
+		"createJob": {Parameters: []ManifestParam{
+			{Name: "job", Type: "map", Required: true},
+			{Name: "namespace", Type: "string", Required: true, Default: "default"},
+			{Name: "timeout", Type: "int"},
+		}},
+	}}
+
+	assert.Nil(t, m.CheckCommand("createJob", map[string]interface{}{"job": map[string]interface{}{}}))
+	assert.Nil(t, m.CheckCommand("createJob", map[string]interface{}{"job": "$ctx.job", "timeout": 30.0}), "interpolated values are checked at runtime")
+	assert.EqualError(t, m.CheckCommand("createJb", nil), "not matching driver manifest: driver kubernetes has no command createJb")
+	assert.EqualError(t, m.CheckCommand("createJob", nil), "not matching driver manifest: kubernetes.createJob requires parameter job")
+	assert.EqualError(t, m.CheckCommand("createJob", map[string]interface{}{"job": "my-job"}),
+		"not matching driver manifest: parameter job of kubernetes.createJob should be map")
+	assert.EqualError(t, m.CheckCommand("createJob", map[string]interface{}{"job": map[string]interface{}{}, "timeout": 1.5}),
+		"not matching driver manifest: parameter timeout of kubernetes.createJob should be int")
+	assert.Nil(t, m.CheckCommand("createJob", map[string]interface{}{"job": map[string]interface{}{}, "timeout": "30"}), "numbers can be strings")
+	assert.EqualError(t, m.CheckCommand("createJob", map[string]interface{}{"job": map[string]interface{}{}, "timeout": "30s"}),
+		"not matching driver manifest: parameter timeout of kubernetes.createJob should be int")
+}
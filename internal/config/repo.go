@@ -175,17 +175,24 @@ func (c *Repo) loadRepo() {
 	dipper.Logger.Infof("repo [%v] loaded", c.repo.Repo)
 }
 
+// isLocal checks if the repo is loaded from the local file system, either overridden or using uncommitted files.
+func (c *Repo) isLocal() bool {
+	if _, overridden := c.parent.Overrides[c.repo.Repo]; overridden {
+		return true
+	}
+	_, e := os.Stat(c.repo.Repo)
+
+	return e == nil && c.repo.Branch == ""
+}
+
 func (c *Repo) refreshRepo() bool {
 	c.Errors = []Error{}
 
 	defer c.recovering("", c.repo.Repo)
 
 	dipper.Logger.Infof("refreshing repo [%v]", c.repo.Repo)
-	_, overridden := c.parent.Overrides[c.repo.Repo]
-	_, e := os.Stat(c.repo.Repo)
-	usingUncommitted := e == nil && c.repo.Branch == ""
 
-	if !overridden && !usingUncommitted {
+	if !c.isLocal() {
 		repoObj := dipper.Must(git.PlainOpen(c.root)).(*git.Repository)
 		tree := dipper.Must(repoObj.Worktree()).(*git.Worktree)
 		opts := &git.PullOptions{
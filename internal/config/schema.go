@@ -41,10 +41,18 @@ type Function struct {
 	Export          map[string]interface{}
 	ExportOnSuccess map[string]interface{} `json:"export_on_success" mapstructure:"export_on_success"`
 	ExportOnFailure map[string]interface{} `json:"export_on_failure" mapstructure:"export_on_failure"`
+	IdempotencyKey  string                 `json:"idempotency_key" mapstructure:"idempotency_key"`
+	Cache           *FunctionCache
 	Description     string
 	Meta            interface{}
 }
 
+// FunctionCache defines how the return of a function is cached.
+type FunctionCache struct {
+	TTL string `json:"ttl" mapstructure:"ttl"`
+	Key string
+}
+
 // System is an abstract construct to group data, trigger and function definitions.
 type System struct {
 	Data        map[string](interface{})
@@ -86,6 +94,8 @@ type Workflow struct {
 	Retry   string
 	Backoff string
 
+	Lock *WorkflowLock
+
 	OnError      string `json:"on_error" mapstructure:"on_error"`
 	OnFailure    string `json:"on_failure" mapstructure:"on_failure"`
 	Workflow     string `json:"call_workflow" mapstructure:"call_workflow"`
@@ -108,6 +118,13 @@ type Workflow struct {
 	NoExport        []string               `json:"no_export" mapstructure:"no_export"`
 }
 
+// WorkflowLock defines an exclusive lock held by a workflow session while it is executing.
+type WorkflowLock struct {
+	Name        string
+	TTL         string `json:"ttl" mapstructure:"ttl"`
+	WaitTimeout string `json:"wait_timeout" mapstructure:"wait_timeout"`
+}
+
 // Rule is a data structure defining what action to take when certain event happen.
 type Rule struct {
 	When Trigger
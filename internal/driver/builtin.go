@@ -23,9 +23,12 @@ type BuiltinDriver struct {
 	meta   *Meta
 	stream chan<- *dipper.Message
 
-	input  io.ReadCloser
-	output io.WriteCloser
-	run    *exec.Cmd
+	input   io.ReadCloser
+	output  io.WriteCloser
+	run     *exec.Cmd
+	process *os.Process
+	sandbox *sandbox
+	failure string
 }
 
 // BuiltinPath is the path where the builtin drivers are kept. It will try using $HONEYDIPPER_DRIVERS_BUILTIN by default.
@@ -64,10 +67,11 @@ func (d *BuiltinDriver) Acquire() {
 	d.meta.Executable = filepath.Join(builtinPath(), shortName)
 }
 
-// Prepare function is used for preparing the arguments when calling the executable.
+// Prepare function is used for preparing the arguments and the sandbox when calling the executable.
 // for the driver.
 func (d *BuiltinDriver) Prepare(stream chan<- *dipper.Message) {
 	d.stream = stream
+	d.sandbox = newSandbox(d.meta)
 
 	var argsList []interface{}
 
@@ -100,7 +104,12 @@ func NewBuiltinDriver(m *Meta) *BuiltinDriver {
 
 // Start the driver child process.  The "service" indicates which service this driver belongs to.
 func (d *BuiltinDriver) Start(service string) {
-	d.run = execCommand(d.meta.Executable, append([]string{service}, d.meta.Arguments...)...)
+	d.startProcess(service, execCommand(d.meta.Executable, append([]string{service}, d.meta.Arguments...)...))
+}
+
+// startProcess links the pipes to the prepared command and starts the driver child process.
+func (d *BuiltinDriver) startProcess(service string, run *exec.Cmd) {
+	d.run = run
 	if input, err := d.run.StdoutPipe(); err != nil {
 		dipper.Logger.Panicf("[%s] Unable to link to driver stdout %v", service, err)
 	} else {
@@ -116,9 +125,25 @@ func (d *BuiltinDriver) Start(service string) {
 
 	d.run.Stderr = os.Stderr
 	d.run.ExtraFiles = []*os.File{os.Stdout} // giving child process stdout for logging
+	if d.run.Env == nil && d.sandbox != nil && d.sandbox.envAllowlist != nil {
+		d.run.Env = d.sandbox.environ()
+	}
+	if err := d.sandbox.prepare(d.run, service+"-"+d.meta.Name); err != nil {
+		panic(fmt.Errorf("%w: refuse to start driver %s: %v", ErrDriverError, d.meta.Name, err))
+	}
 	if err := d.run.Start(); err != nil {
+		d.sandbox.cleanup()
 		dipper.Logger.Panicf("[%s] Failed to start driver %v", service, err)
 	}
+	d.process = d.run.Process
+	d.failure = ""
+}
+
+// Kill forcefully stops the driver child process.
+func (d *BuiltinDriver) Kill() {
+	if d.process != nil {
+		_ = d.process.Kill()
+	}
 }
 
 // SendMessage sends a dipper message to the driver child process.
@@ -159,8 +184,19 @@ func (d *BuiltinDriver) Close() {
 	}
 }
 
-// Wait wait for the driver process to exit.
+// Wait wait for the driver process to exit, and records the reason if it is killed for exceeding the limits.
 func (d *BuiltinDriver) Wait() {
-	_ = d.run.Wait()
+	err := d.run.Wait()
+	if violation := d.sandbox.violation(d.run.ProcessState, err); violation != "" {
+		d.failure = violation
+	} else if err != nil {
+		d.failure = "driver exited: " + err.Error()
+	}
+	d.sandbox.cleanup()
 	d.run = nil
 }
+
+// FailureReason returns why the driver process exited.
+func (d *BuiltinDriver) FailureReason() string {
+	return d.failure
+}
@@ -33,6 +33,16 @@ type Handler interface {
 	Wait()
 }
 
+// Killer is implemented by the handlers that can forcefully stop a driver that is not responding.
+type Killer interface {
+	Kill()
+}
+
+// FailureReporter is implemented by the handlers that can tell why a driver exited.
+type FailureReporter interface {
+	FailureReason() string
+}
+
 // Runtime contains the runtime information of the running driver.
 type Runtime struct {
 	Data        interface{}
@@ -42,6 +52,7 @@ type Runtime struct {
 	Stream      <-chan *dipper.Message
 	Service     string
 	State       int
+	Handshake   *dipper.Handshake
 }
 
 // NewDriver creates a driver object to represent a child process.
@@ -61,6 +72,14 @@ func NewDriver(feature string, metaData map[string]interface{}, driverData inter
 	switch meta.Type {
 	case "builtin":
 		dh = NewBuiltinDriver(&meta)
+	case "external":
+		dh = NewExternalDriver(&meta)
+	case "inproc":
+		dh = NewInProcDriver(&meta)
+	case "remote":
+		dh = NewRemoteDriver(&meta)
+	case "wasm":
+		dh = NewWasmDriver(&meta)
 	case "null":
 		dh = NewNullDriver(&meta)
 	default:
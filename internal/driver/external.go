@@ -0,0 +1,171 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package driver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// ErrChecksumMismatch means the executable is not the one specified in the config.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ExternalDriver runs a driver executable from any path, or from the content in the config repo.  The
+// executable is copied into a private folder and verified with the configured sha256 checksum before launching.
+type ExternalDriver struct {
+	BuiltinDriver
+	checksum string
+	content  []byte
+	env      []string
+	workdir  string
+	tmpDir   string
+}
+
+// NewExternalDriver creates a handler for the external driver specified in the meta info.
+func NewExternalDriver(m *Meta) *ExternalDriver {
+	return &ExternalDriver{BuiltinDriver: BuiltinDriver{meta: m}}
+}
+
+// Acquire function validates the location and the checksum of the executable.
+func (d *ExternalDriver) Acquire() {
+	checksum, ok := d.meta.HandlerData["sha256"].(string)
+	if !ok || checksum == "" {
+		panic(fmt.Errorf("%w: sha256 is missing for external driver: %s", ErrDriverError, d.meta.Name))
+	}
+	d.checksum = strings.ToLower(checksum)
+
+	if content, ok := d.meta.HandlerData["content"].(string); ok && content != "" {
+		d.content = []byte(content)
+
+		// keep the executable content out of the meta, which is compared and logged
+		handlerData := map[string]interface{}{}
+		for k, v := range d.meta.HandlerData {
+			if k != "content" {
+				handlerData[k] = v
+			}
+		}
+		d.meta.HandlerData = handlerData
+		d.meta.Executable = "content:" + d.checksum
+
+		return
+	}
+
+	executable, ok := d.meta.HandlerData["executable"].(string)
+	if !ok || executable == "" {
+		panic(fmt.Errorf("%w: executable or content is missing for external driver: %s", ErrDriverError, d.meta.Name))
+	}
+	if !filepath.IsAbs(executable) {
+		panic(fmt.Errorf("%w: executable should be an absolute path in driver: %s", ErrDriverError, d.meta.Name))
+	}
+	d.meta.Executable = executable
+}
+
+// Prepare function is used for preparing the arguments, the environment variables and the working directory.
+func (d *ExternalDriver) Prepare(stream chan<- *dipper.Message) {
+	d.BuiltinDriver.Prepare(stream)
+
+	if env, ok := d.meta.HandlerData["env"]; ok && env != nil {
+		envMap, ok := env.(map[string]interface{})
+		if !ok {
+			panic(fmt.Errorf("%w: env in driver %s should be a map", ErrDriverError, d.meta.Name))
+		}
+		for k, v := range envMap {
+			d.env = append(d.env, fmt.Sprintf("%s=%v", k, v))
+		}
+		sort.Strings(d.env)
+	}
+
+	d.workdir, _ = d.meta.HandlerData["workdir"].(string)
+}
+
+// Start verifies the checksum then starts the driver child process.  The "service" indicates which service this driver belongs to.
+func (d *ExternalDriver) Start(service string) {
+	executable, err := d.copyExecutable()
+	if err != nil {
+		d.removeCopy()
+		panic(fmt.Errorf("%w: refuse to start driver %s: %v", ErrDriverError, d.meta.Name, err))
+	}
+
+	run := execCommand(executable, append([]string{service}, d.meta.Arguments...)...)
+	if len(d.env) > 0 {
+		if run.Env == nil {
+			run.Env = d.sandbox.environ()
+		}
+		run.Env = append(run.Env, d.env...)
+	}
+	run.Dir = d.workdir
+	d.startProcess(service, run)
+}
+
+// Wait wait for the driver process to exit, and remove the copy of the executable.
+func (d *ExternalDriver) Wait() {
+	d.BuiltinDriver.Wait()
+	d.removeCopy()
+}
+
+// copyExecutable copies the executable, or writes the content from the config repo, into a private folder owned by the
+// daemon, and verifies the checksum of the copy, so the executable can not be replaced between the verification and
+// the execution. The folder is only searchable by others, and the copy is not writable by others, so the driver can
+// still be executed with the sandbox uid.
+func (d *ExternalDriver) copyExecutable() (string, error) {
+	var src io.Reader = bytes.NewReader(d.content)
+	if d.content == nil {
+		f, err := os.Open(d.meta.Executable)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	dir, err := os.MkdirTemp("", "honeydipper-"+d.meta.Name+"-")
+	if err != nil {
+		return "", err
+	}
+	d.tmpDir = dir
+	if err := os.Chmod(dir, 0o711); err != nil {
+		return "", err
+	}
+
+	executable := filepath.Join(dir, "driver")
+	f, err := os.OpenFile(executable, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o700)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), src); err != nil {
+		return "", err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != d.checksum {
+		return "", fmt.Errorf("%w for %s: %s", ErrChecksumMismatch, d.meta.Executable, actual)
+	}
+	if err := f.Chmod(0o755); err != nil {
+		return "", err
+	}
+
+	// the file has to be closed before executing, otherwise it is busy
+	return executable, f.Close()
+}
+
+func (d *ExternalDriver) removeCopy() {
+	if d.tmpDir != "" {
+		_ = os.RemoveAll(d.tmpDir)
+		d.tmpDir = ""
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+const testExternalContent = "#!/bin/sh\nexit 0\n"
+
+func testExternalChecksum() string {
+	sum := sha256.Sum256([]byte(testExternalContent))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func TestExternalAcquire(t *testing.T) {
+	d := NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"executable": "/opt/drivers/test", "sha256": "ABCD"}})
+	d.Acquire()
+	assert.Equal(t, "/opt/drivers/test", d.meta.Executable)
+	assert.Equal(t, "abcd", d.checksum, "checksum should be case insensitive")
+
+	d = NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"content": testExternalContent, "sha256": "abcd"}})
+	d.Acquire()
+	assert.Equal(t, "content:abcd", d.meta.Executable)
+	assert.NotContains(t, d.meta.HandlerData, "content", "executable content should not be kept in meta")
+
+	assert.PanicsWithError(t, "driver error: sha256 is missing for external driver: test", func() {
+		NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"executable": "/opt/drivers/test"}}).Acquire()
+	})
+	assert.PanicsWithError(t, "driver error: executable or content is missing for external driver: test", func() {
+		NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"sha256": "abcd"}}).Acquire()
+	})
+	assert.PanicsWithError(t, "driver error: executable should be an absolute path in driver: test", func() {
+		NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"executable": "drivers/test", "sha256": "abcd"}}).Acquire()
+	})
+}
+
+func TestExternalPrepare(t *testing.T) {
+	d := NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{
+		"arguments": []interface{}{"--debug"},
+		"env":       map[string]interface{}{"B": 2, "A": "1"},
+		"workdir":   "/tmp",
+	}})
+	d.Prepare(make(chan *dipper.Message, 1))
+	assert.Equal(t, []string{"--debug"}, d.meta.Arguments)
+	assert.Equal(t, []string{"A=1", "B=2"}, d.env)
+	assert.Equal(t, "/tmp", d.workdir)
+
+	assert.PanicsWithError(t, "driver error: env in driver test should be a map", func() {
+		NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"env": []interface{}{"A=1"}}}).Prepare(make(chan *dipper.Message, 1))
+	})
+}
+
+func TestExternalStart(t *testing.T) {
+	defer func(orig func(string, ...string) *exec.Cmd) { execCommand = orig }(execCommand)
+	execCommand = generateFakeExecCommand("TestExecCommandDummy")
+
+	executable := filepath.Join(t.TempDir(), "test-driver")
+	assert.Nil(t, os.WriteFile(executable, []byte(testExternalContent), 0o700))
+
+	d := NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"executable": executable, "sha256": "0000"}})
+	d.Acquire()
+	d.Prepare(make(chan *dipper.Message, 1))
+	assert.PanicsWithError(t, "driver error: refuse to start driver test: checksum mismatch for "+executable+": "+testExternalChecksum(), func() {
+		d.Start("operator")
+	})
+	assert.Equal(t, 0, fakeExecCommandCount, "should not launch the driver on checksum mismatch")
+
+	d = NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{
+		"executable": executable,
+		"sha256":     testExternalChecksum(),
+		"env":        map[string]interface{}{"FOO": "bar"},
+		"workdir":    os.TempDir(),
+	}})
+	d.Acquire()
+	d.Prepare(make(chan *dipper.Message, 1))
+	d.Start("operator")
+	assert.Equal(t, 1, fakeExecCommandCount, "should launch the driver")
+	assert.Contains(t, d.run.Env, "FOO=bar", "should inject the environment variables")
+	assert.Equal(t, os.TempDir(), d.run.Dir, "should run in the working directory")
+	d.Close()
+	d.Wait()
+
+	d = NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"content": testExternalContent, "sha256": testExternalChecksum()}})
+	d.Acquire()
+	d.Prepare(make(chan *dipper.Message, 1))
+	d.Start("operator")
+	tmpDir := d.tmpDir
+	assert.FileExists(t, filepath.Join(tmpDir, "driver"), "should write the content into an executable")
+	d.Close()
+	d.Wait()
+	assert.NoDirExists(t, tmpDir, "should remove the executable after the driver exits")
+}
+
+func TestExternalStartPrivateCopy(t *testing.T) {
+	defer func(orig func(string, ...string) *exec.Cmd) { execCommand = orig }(execCommand)
+	execCommand = generateFakeExecCommand("TestExecCommandWaitStdin")
+
+	executable := filepath.Join(t.TempDir(), "test-driver")
+	assert.Nil(t, os.WriteFile(executable, []byte(testExternalContent), 0o700))
+
+	d := NewExternalDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"executable": executable, "sha256": testExternalChecksum()}})
+	d.Acquire()
+	d.Prepare(make(chan *dipper.Message, 1))
+	d.Start("operator")
+
+	copied := filepath.Join(d.tmpDir, "driver")
+	assert.Equal(t, copied, d.run.Args[3], "should execute the verified copy instead of the original")
+	assert.Nil(t, os.WriteFile(executable, []byte("replaced"), 0o700))
+	content, err := os.ReadFile(copied)
+	assert.Nil(t, err)
+	assert.Equal(t, testExternalContent, string(content), "should not be affected by changes to the original")
+
+	info, err := os.Stat(d.tmpDir)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o711), info.Mode().Perm(), "folder should only be searchable by others")
+	info, err = os.Stat(copied)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm(), "copy should be executable but not writable by others")
+
+	d.Close()
+	d.Wait()
+
+	d.meta.HandlerData["executable"] = filepath.Join(t.TempDir(), "missing")
+	d.Acquire()
+	assert.Panics(t, func() { d.Start("operator") }, "should refuse to start a missing executable")
+	assert.Empty(t, d.tmpDir)
+}
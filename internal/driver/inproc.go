@@ -0,0 +1,96 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// InProcDriver runs a driver registered with dipper.RegisterInProcDriver inside the daemon process, the
+// messages are passed over go channels without serialization.
+type InProcDriver struct {
+	meta    *Meta
+	stream  chan<- *dipper.Message
+	factory dipper.InProcFactory
+
+	toDriver   *dipper.MessagePipe
+	fromDriver *dipper.MessagePipe
+	done       chan struct{}
+}
+
+// NewInProcDriver creates a handler for the in-process driver specified in the meta info.
+func NewInProcDriver(m *Meta) *InProcDriver {
+	return &InProcDriver{meta: m}
+}
+
+// Acquire function looks up the driver in the registered in-process drivers.
+func (d *InProcDriver) Acquire() {
+	shortName, ok := d.meta.HandlerData["shortName"].(string)
+	if !ok || shortName == "" {
+		panic(fmt.Errorf("%w: shortName is missing for inproc driver: %s", ErrDriverError, d.meta.Name))
+	}
+
+	if d.factory, ok = dipper.GetInProcDriver(shortName); !ok {
+		panic(fmt.Errorf("%w: inproc driver not registered: %s", ErrDriverError, shortName))
+	}
+
+	d.meta.Executable = "inproc:" + shortName
+}
+
+// Prepare function keeps the stream for receiving messages from the driver.
+func (d *InProcDriver) Prepare(stream chan<- *dipper.Message) {
+	d.stream = stream
+}
+
+// Meta function exposes the metadata used for this driver handler.
+func (d *InProcDriver) Meta() *Meta {
+	return d.meta
+}
+
+// Start the driver message loop in a goroutine.  The "service" indicates which service this driver belongs to.
+func (d *InProcDriver) Start(service string) {
+	in := make(chan *dipper.Message, DriverMessageBuffer)
+	d.toDriver = dipper.NewMessagePipe(in)
+	d.fromDriver = dipper.NewMessagePipe(d.stream)
+	d.done = make(chan struct{})
+
+	drv := d.factory(service)
+	go func() {
+		defer close(d.done)
+		defer dipper.SafeExitOnError("[%s-%s] in-process driver crashed", service, d.meta.Name)
+		drv.RunInProc(in, d.fromDriver)
+	}()
+}
+
+// SendMessage passes a dipper message to the driver.
+func (d *InProcDriver) SendMessage(msg *dipper.Message) {
+	if d.toDriver == nil || !d.toDriver.Put(msg) {
+		dipper.Logger.Warningf("[%s] dropping message %s:%s to closed inproc driver", d.meta.Name, msg.Channel, msg.Subject)
+	}
+}
+
+// Close closes the channels to and from the driver, which stops the driver message loop.
+func (d *InProcDriver) Close() {
+	if d.toDriver != nil {
+		d.toDriver.Close()
+	}
+	if d.fromDriver != nil {
+		d.fromDriver.Close()
+	} else if d.stream != nil {
+		close(d.stream)
+	}
+	d.stream = nil
+}
+
+// Wait waits for the driver message loop to exit.
+func (d *InProcDriver) Wait() {
+	if d.done != nil {
+		<-d.done
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	dipper.RegisterInProcDriver("test-inproc", func(service string) *dipper.Driver {
+		d := dipper.NewDriver(service, "test-inproc")
+		d.RPCHandlers["echo"] = func(msg *dipper.Message) {
+			msg.Reply <- dipper.Message{Payload: msg.Payload}
+		}
+
+		return d
+	})
+}
+
+func TestInProcAcquire(t *testing.T) {
+	d := NewInProcDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"shortName": "test-inproc"}})
+	d.Acquire()
+	assert.Equal(t, "inproc:test-inproc", d.meta.Executable)
+
+	assert.PanicsWithError(t, "driver error: shortName is missing for inproc driver: test", func() {
+		NewInProcDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{}}).Acquire()
+	})
+	assert.PanicsWithError(t, "driver error: inproc driver not registered: not-there", func() {
+		NewInProcDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"shortName": "not-there"}}).Acquire()
+	})
+}
+
+func TestInProcLifecycle(t *testing.T) {
+	runtime := NewDriver("test", map[string]interface{}{
+		"name":        "test",
+		"type":        "inproc",
+		"handlerData": map[string]interface{}{"shortName": "test-inproc"},
+	}, map[string]interface{}{"key": "val"}, nil)
+	runtime.Start("operator")
+
+	fetch := func() *dipper.Message {
+		select {
+		case msg := <-runtime.Stream:
+			return msg
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "expecting message from inproc driver")
+		}
+
+		return nil
+	}
+
+	state := fetch()
+	assert.Equal(t, "state", state.Channel)
+	assert.Equal(t, dipper.StateHandshake, state.Subject, "driver should announce its capabilities when started")
+	handshake, err := dipper.ParseHandshake(state)
+	assert.Nil(t, err)
+	assert.Equal(t, dipper.ProtocolVersion, handshake.Protocol)
+	assert.Equal(t, []string{"echo"}, handshake.RPCs, "driver should announce its rpc methods")
+
+	state = fetch()
+	assert.Equal(t, "state", state.Channel)
+	assert.Equal(t, "alive", state.Subject, "driver should be alive after receiving options")
+
+	runtime.SendMessage(&dipper.Message{
+		Channel: "rpc",
+		Subject: "call",
+		Labels:  map[string]string{"method": "echo", "rpcID": "1", "caller": "operator"},
+		Payload: map[string]interface{}{"foo": "bar"},
+	})
+	ret := fetch()
+	assert.Equal(t, "rpc", ret.Channel)
+	assert.Equal(t, "return", ret.Subject)
+	assert.Equal(t, "1", ret.Labels["rpcID"])
+	assert.Equal(t, map[string]interface{}{"foo": "bar"}, dipper.DeserializePayload(ret).Payload)
+
+	runtime.Handler.Close()
+	waited := make(chan struct{})
+	go func() {
+		runtime.Handler.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		assert.Fail(t, "wait should return after close")
+	}
+	_, ok := <-runtime.Stream
+	assert.False(t, ok, "stream should be closed")
+	assert.NotPanics(t, func() { runtime.Handler.SendMessage(&dipper.Message{Channel: "command", Subject: "ping"}) })
+}
@@ -14,6 +14,7 @@ import (
 )
 
 func TestMain(m *testing.M) {
+	SandboxExec()
 	if dipper.Logger == nil {
 		f, _ := os.OpenFile(os.DevNull, os.O_APPEND, 0o777)
 		defer f.Close()
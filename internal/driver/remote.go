@@ -0,0 +1,421 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package driver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/daemon"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// DefaultRemoteHeartbeat is the default interval for pinging the remote driver.
+	DefaultRemoteHeartbeat = 10 * time.Second
+	// DefaultRemoteDialTimeout is the default timeout for connecting to the remote driver.
+	DefaultRemoteDialTimeout = 5 * time.Second
+	// RemoteMissedHeartbeats is the number of heartbeat intervals without any message before reconnecting.
+	RemoteMissedHeartbeats = 3
+	// RemoteMinBackoff is the initial wait time before reconnecting.
+	RemoteMinBackoff = time.Second
+	// RemoteMaxBackoff is the maximum wait time before reconnecting.
+	RemoteMaxBackoff = 30 * time.Second
+	// RemoteOutboundBuffer is the size of the buffer for messages waiting to be sent to the remote driver.
+	RemoteOutboundBuffer = 100
+)
+
+// RemoteDriver connects to a long running driver process over TCP or unix socket.
+type RemoteDriver struct {
+	meta    *Meta
+	stream  chan<- *dipper.Message
+	service string
+
+	network     string
+	address     string
+	tlsConfig   *tls.Config
+	heartbeat   time.Duration
+	dialTimeout time.Duration
+
+	lock     sync.Mutex
+	cond     *sync.Cond
+	conn     net.Conn
+	sessions int
+	lastSeen time.Time
+	replay   map[string]*dipper.Message
+	outbound chan *dipper.Message
+	done     chan struct{}
+	closed   bool
+	fetching bool
+	once     sync.Once
+}
+
+// NewRemoteDriver creates a handler for the remote driver specified in the meta info.
+func NewRemoteDriver(m *Meta) *RemoteDriver {
+	d := &RemoteDriver{
+		meta:     m,
+		replay:   map[string]*dipper.Message{},
+		outbound: make(chan *dipper.Message, RemoteOutboundBuffer),
+		done:     make(chan struct{}),
+	}
+	d.cond = sync.NewCond(&d.lock)
+
+	return d
+}
+
+// Acquire function parses the address of the remote driver.
+func (d *RemoteDriver) Acquire() {
+	address, ok := d.meta.HandlerData["address"].(string)
+	if !ok || address == "" {
+		panic(fmt.Errorf("%w: address is missing for remote driver: %s", ErrDriverError, d.meta.Name))
+	}
+
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		d.network, d.address = "unix", address[len("unix://"):]
+	case strings.HasPrefix(address, "tcp://"):
+		d.network, d.address = "tcp", address[len("tcp://"):]
+	case strings.Contains(address, "://"):
+		panic(fmt.Errorf("%w: unsupported address for remote driver %s: %s", ErrDriverError, d.meta.Name, address))
+	default:
+		d.network, d.address = "tcp", address
+	}
+
+	d.meta.Executable = address
+}
+
+// Prepare function loads the TLS and heartbeat settings for the connection.
+func (d *RemoteDriver) Prepare(stream chan<- *dipper.Message) {
+	d.stream = stream
+
+	d.heartbeat = DefaultRemoteHeartbeat
+	if heartbeat, ok := dipper.GetMapDataStr(d.meta.HandlerData, "heartbeat"); ok {
+		d.heartbeat = dipper.Must(time.ParseDuration(heartbeat)).(time.Duration)
+	}
+	d.dialTimeout = DefaultRemoteDialTimeout
+	if timeout, ok := dipper.GetMapDataStr(d.meta.HandlerData, "dial_timeout"); ok {
+		d.dialTimeout = dipper.Must(time.ParseDuration(timeout)).(time.Duration)
+	}
+
+	if tlsData, ok := d.meta.HandlerData["tls"]; ok && tlsData != nil {
+		caFile := dipper.MustGetMapDataStr(tlsData, "ca_file")
+		certFile := dipper.MustGetMapDataStr(tlsData, "cert_file")
+		keyFile := dipper.MustGetMapDataStr(tlsData, "key_file")
+		d.tlsConfig = dipper.Must(dipper.RemoteTLSConfig(caFile, certFile, keyFile, false)).(*tls.Config)
+		if serverName, ok := dipper.GetMapDataStr(tlsData, "server_name"); ok {
+			d.tlsConfig.ServerName = serverName
+		}
+	}
+}
+
+// Meta function exposes the metadata used for this driver handler.
+func (d *RemoteDriver) Meta() *Meta {
+	return d.meta
+}
+
+// Start connecting to the remote driver in the background.  The "service" indicates which service this driver belongs to.
+func (d *RemoteDriver) Start(service string) {
+	d.service = service
+	d.lock.Lock()
+	d.fetching = true
+	d.lock.Unlock()
+	go d.maintainConnection()
+	go d.writeMessages()
+	go d.fetchMessages()
+	go d.keepAlive()
+}
+
+// SendMessage queues a dipper message to be sent to the remote driver.
+func (d *RemoteDriver) SendMessage(msg *dipper.Message) {
+	select {
+	case d.outbound <- msg:
+	case <-d.done:
+		dipper.Logger.Warningf("[%s-%s] dropping message %s:%s to closed remote driver", d.service, d.meta.Name, msg.Channel, msg.Subject)
+	}
+}
+
+// Close closes the connection for the driver, the stream is closed once the messages are no longer fetched.
+func (d *RemoteDriver) Close() {
+	d.lock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+		if d.conn != nil {
+			d.conn.Close()
+			dipper.ForgetComm(d.conn)
+			d.conn = nil
+		}
+		d.cond.Broadcast()
+	}
+	fetching := d.fetching
+	d.lock.Unlock()
+
+	if !fetching {
+		d.closeStream()
+	}
+}
+
+// closeStream closes the stream once, only after nothing is sending to it.
+func (d *RemoteDriver) closeStream() {
+	d.once.Do(func() {
+		if d.stream != nil {
+			close(d.stream)
+		}
+	})
+}
+
+// Wait waits for the driver to be closed, the connection is re-established automatically until then.
+func (d *RemoteDriver) Wait() {
+	<-d.done
+}
+
+// maintainConnection connects to the remote driver whenever there is no live connection.
+func (d *RemoteDriver) maintainConnection() {
+	for {
+		d.lock.Lock()
+		for d.conn != nil && !d.closed {
+			d.cond.Wait()
+		}
+		if d.closed {
+			d.lock.Unlock()
+
+			return
+		}
+		d.lock.Unlock()
+
+		conn := d.dial()
+		if conn == nil {
+			return
+		}
+
+		d.lock.Lock()
+		if d.closed {
+			conn.Close()
+			d.lock.Unlock()
+
+			return
+		}
+		d.conn = conn
+		d.lastSeen = time.Now()
+		d.cond.Broadcast()
+		d.lock.Unlock()
+	}
+}
+
+// dial keeps trying to connect with exponential backoff until connected or closed.
+func (d *RemoteDriver) dial() net.Conn {
+	backoff := RemoteMinBackoff
+	for !d.isClosed() && !daemon.ShuttingDown {
+		conn, err := d.connect()
+		if err == nil {
+			return conn
+		}
+		dipper.Logger.Warningf("[%s-%s] failed to connect to remote driver %s, retry in %s: %+v", d.service, d.meta.Name, d.meta.Executable, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-d.done:
+		}
+		if backoff *= 2; backoff > RemoteMaxBackoff {
+			backoff = RemoteMaxBackoff
+		}
+	}
+
+	return nil
+}
+
+// connect makes the connection, greets the remote driver and replays the options when reconnecting.
+func (d *RemoteDriver) connect() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: d.dialTimeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if d.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, d.network, d.address, d.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(d.network, d.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := []*dipper.Message{
+		{
+			Channel: "command",
+			Subject: dipper.RemoteHelloSubject,
+			Labels: map[string]string{
+				"service": d.service,
+				"driver":  d.meta.Name,
+			},
+		},
+	}
+	d.lock.Lock()
+	if d.sessions > 0 {
+		// a new session on the remote driver needs the options to start
+		for _, subject := range []string{"options", "start"} {
+			if msg, ok := d.replay[subject]; ok {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	d.sessions++
+	d.lock.Unlock()
+
+	for _, msg := range msgs {
+		if err := send(conn, msg, d.dialTimeout); err != nil {
+			conn.Close()
+			dipper.ForgetComm(conn)
+
+			return nil, err
+		}
+	}
+	dipper.Logger.Infof("[%s-%s] connected to remote driver %s", d.service, d.meta.Name, d.meta.Executable)
+
+	return conn, nil
+}
+
+// waitConn waits for a live connection, returns nil if the driver is closed.
+func (d *RemoteDriver) waitConn() net.Conn {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for d.conn == nil && !d.closed {
+		d.cond.Wait()
+	}
+
+	return d.conn
+}
+
+// disconnect drops a broken connection so a new one can be made.
+func (d *RemoteDriver) disconnect(conn net.Conn, reason interface{}) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.conn != conn || d.closed {
+		return
+	}
+	dipper.Logger.Warningf("[%s-%s] lost connection to remote driver %s: %+v", d.service, d.meta.Name, d.meta.Executable, reason)
+	conn.Close()
+	dipper.ForgetComm(conn)
+	d.conn = nil
+	d.cond.Broadcast()
+}
+
+func (d *RemoteDriver) isClosed() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.closed
+}
+
+// writeMessages sends the queued messages, retrying on new connections if the connection is broken.
+func (d *RemoteDriver) writeMessages() {
+	for {
+		var msg *dipper.Message
+		select {
+		case msg = <-d.outbound:
+		case <-d.done:
+			return
+		}
+
+		for {
+			conn := d.waitConn()
+			if conn == nil {
+				return
+			}
+			err := send(conn, msg, RemoteMissedHeartbeats*d.heartbeat)
+			if err == nil {
+				break
+			}
+			d.disconnect(conn, err)
+		}
+
+		if msg.Channel == "command" && (msg.Subject == "options" || msg.Subject == "start") {
+			d.lock.Lock()
+			d.replay[msg.Subject] = msg
+			d.lock.Unlock()
+		}
+	}
+}
+
+// fetchMessages reads the messages from the remote driver into the stream.
+func (d *RemoteDriver) fetchMessages() {
+	daemon.Children.Add(1)
+	defer daemon.Children.Done()
+	defer d.closeStream()
+	for !daemon.ShuttingDown {
+		conn := d.waitConn()
+		if conn == nil {
+			break
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					d.disconnect(conn, r)
+				}
+			}()
+			for {
+				message := dipper.FetchRawMessage(conn)
+				d.lock.Lock()
+				d.lastSeen = time.Now()
+				d.lock.Unlock()
+				select {
+				case d.stream <- message:
+				case <-d.done:
+					return
+				}
+			}
+		}()
+	}
+	dipper.Logger.Warningf("[%s-%s] driver closed for business", d.service, d.meta.Name)
+}
+
+// keepAlive pings the remote driver periodically, and reconnects if the driver stops responding.
+func (d *RemoteDriver) keepAlive() {
+	ticker := time.NewTicker(d.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			d.lock.Lock()
+			conn, lastSeen := d.conn, d.lastSeen
+			d.lock.Unlock()
+			if conn == nil {
+				continue
+			}
+			if time.Since(lastSeen) > RemoteMissedHeartbeats*d.heartbeat {
+				d.disconnect(conn, "heartbeat timeout")
+
+				continue
+			}
+			d.SendMessage(&dipper.Message{
+				Channel: "command",
+				Subject: "ping",
+			})
+		}
+	}
+}
+
+// send writes a message to the connection within the timeout, returns error instead of panicking.
+func send(conn net.Conn, msg *dipper.Message, timeout time.Duration) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrDriverError, r)
+		}
+	}()
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	dipper.SendMessage(conn, msg)
+
+	return nil
+}
@@ -0,0 +1,292 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteAcquire(t *testing.T) {
+	testCases := map[string][]string{
+		"host:9000":               {"tcp", "host:9000"},
+		"tcp://host:9000":         {"tcp", "host:9000"},
+		"unix:///var/run/drv.sck": {"unix", "/var/run/drv.sck"},
+	}
+	for address, expected := range testCases {
+		d := NewRemoteDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"address": address}})
+		d.Acquire()
+		assert.Equal(t, expected[0], d.network, "network for %s", address)
+		assert.Equal(t, expected[1], d.address, "address for %s", address)
+	}
+
+	assert.PanicsWithError(t, "driver error: address is missing for remote driver: test", func() {
+		NewRemoteDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{}}).Acquire()
+	})
+	assert.PanicsWithError(t, "driver error: unsupported address for remote driver test: udp://host:9000", func() {
+		NewRemoteDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"address": "udp://host:9000"}}).Acquire()
+	})
+}
+
+func TestRemotePrepare(t *testing.T) {
+	d := NewRemoteDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"heartbeat": "1s"}})
+	d.Prepare(make(chan *dipper.Message, 1))
+	assert.Equal(t, time.Second, d.heartbeat)
+	assert.Equal(t, DefaultRemoteDialTimeout, d.dialTimeout)
+	assert.Nil(t, d.tlsConfig)
+
+	d = NewRemoteDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{
+		"tls": map[string]interface{}{"ca_file": "test_fixtures/ca.pem", "cert_file": "test_fixtures/cert.pem", "key_file": "test_fixtures/key.pem"},
+	}})
+	assert.Panics(t, func() { d.Prepare(make(chan *dipper.Message, 1)) }, "should panic when tls files are not found")
+}
+
+func TestRemoteReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	stream := make(chan *dipper.Message, 10)
+	d := NewRemoteDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"address": listener.Addr().String()}})
+	d.Acquire()
+	d.Prepare(stream)
+	d.Start("operator")
+	d.SendMessage(&dipper.Message{Channel: "command", Subject: "options", Payload: map[string]interface{}{"data": "foo"}})
+	d.SendMessage(&dipper.Message{Channel: "command", Subject: "start"})
+
+	var conn net.Conn
+	select {
+	case conn = <-conns:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "remote driver should connect")
+	}
+	hello := dipper.FetchRawMessage(conn)
+	assert.Equal(t, dipper.RemoteHelloSubject, hello.Subject)
+	assert.Equal(t, "operator", hello.Labels["service"])
+	assert.Equal(t, "options", dipper.FetchRawMessage(conn).Subject)
+	assert.Equal(t, "start", dipper.FetchRawMessage(conn).Subject)
+
+	dipper.SendMessage(conn, &dipper.Message{Channel: "state", Subject: "alive"})
+	select {
+	case msg := <-stream:
+		assert.Equal(t, "alive", msg.Subject, "message from remote driver should be streamed")
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "message from remote driver should be streamed")
+	}
+
+	// dropping the connection, expecting a new session with the options replayed
+	conn.Close()
+	select {
+	case conn = <-conns:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "remote driver should reconnect")
+	}
+	assert.Equal(t, dipper.RemoteHelloSubject, dipper.FetchRawMessage(conn).Subject)
+	options := dipper.DeserializePayload(dipper.FetchRawMessage(conn))
+	assert.Equal(t, "options", options.Subject)
+	assert.Equal(t, map[string]interface{}{"data": "foo"}, options.Payload, "options should be replayed on reconnect")
+	assert.Equal(t, "start", dipper.FetchRawMessage(conn).Subject)
+
+	d.Close()
+	waited := make(chan struct{})
+	go func() {
+		d.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		assert.Fail(t, "wait should return after close")
+	}
+	conn.Close()
+}
+
+// writeTestCerts creates a CA, and the certificates for the remote driver and the daemon signed by the CA.
+func writeTestCerts(t *testing.T) string {
+	dir := t.TempDir()
+	writePEM := func(name string, blockType string, der []byte) {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	}
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.Nil(t, err)
+	writePEM("ca.pem", "CERTIFICATE", caDER)
+	ca, _ := x509.ParseCertificate(caDER)
+
+	for i, name := range []string{"server", "client"} {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		assert.Nil(t, err)
+		writePEM(name+".pem", "CERTIFICATE", der)
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		writePEM(name+"-key.pem", "EC PRIVATE KEY", keyDER)
+	}
+
+	return dir
+}
+
+func TestRemoteDriverEndToEnd(t *testing.T) {
+	certs := writeTestCerts(t)
+	t.Setenv(dipper.RemoteListenEnv, "tcp://127.0.0.1:0")
+	t.Setenv(dipper.RemoteCAEnv, filepath.Join(certs, "ca.pem"))
+	t.Setenv(dipper.RemoteCertEnv, filepath.Join(certs, "server.pem"))
+	t.Setenv(dipper.RemoteKeyEnv, filepath.Join(certs, "server-key.pem"))
+	listener, err := dipper.ListenRemote()
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	remote := dipper.NewDriver("", "test-remote")
+	remote.Commands["echo"] = func(m *dipper.Message) {
+		m = dipper.DeserializePayload(m)
+		m.Reply <- dipper.Message{Payload: map[string]interface{}{"echo": m.Payload, "service": remote.Service}}
+	}
+	go remote.RunRemote(listener)
+
+	stream := make(chan *dipper.Message, 10)
+	d := NewRemoteDriver(&Meta{Name: "test-remote", HandlerData: map[string]interface{}{
+		"address": "tcp://" + listener.Addr().String(),
+		"tls": map[string]interface{}{
+			"ca_file":   filepath.Join(certs, "ca.pem"),
+			"cert_file": filepath.Join(certs, "client.pem"),
+			"key_file":  filepath.Join(certs, "client-key.pem"),
+		},
+	}})
+	d.Acquire()
+	d.Prepare(stream)
+	d.Start("operator")
+	defer d.Close()
+
+	d.SendMessage(&dipper.Message{
+		Channel: "eventbus",
+		Subject: "command",
+		Labels:  map[string]string{"sessionID": "1", "method": "echo"},
+		Payload: map[string]interface{}{"hello": "world"},
+	})
+	select {
+	case msg := <-stream:
+		msg = dipper.DeserializePayload(msg)
+		assert.Equal(t, "return", msg.Subject)
+		assert.Equal(t, "success", msg.Labels["status"])
+		assert.Equal(t, "world", dipper.MustGetMapDataStr(msg.Payload, "echo.hello"))
+		assert.Equal(t, "operator", dipper.MustGetMapDataStr(msg.Payload, "service"), "should serve the service in hello message")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "remote driver should return the command result")
+	}
+}
+
+func TestRemoteDriverRequiresClientCert(t *testing.T) {
+	certs := writeTestCerts(t)
+	t.Setenv(dipper.RemoteListenEnv, "tcp://127.0.0.1:0")
+	t.Setenv(dipper.RemoteCAEnv, filepath.Join(certs, "ca.pem"))
+	t.Setenv(dipper.RemoteCertEnv, filepath.Join(certs, "server.pem"))
+	t.Setenv(dipper.RemoteKeyEnv, filepath.Join(certs, "server-key.pem"))
+	listener, err := dipper.ListenRemote()
+	assert.Nil(t, err)
+	defer listener.Close()
+	go dipper.NewDriver("", "test-remote").RunRemote(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	dipper.SendMessage(conn, &dipper.Message{Channel: "command", Subject: dipper.RemoteHelloSubject})
+	assert.Nil(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	assert.NotNil(t, err, "should drop the connection without TLS")
+}
+
+func TestRemoteCloseWhileFetching(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if send(conn, &dipper.Message{Channel: "state", Subject: "alive"}, time.Second) != nil {
+				return
+			}
+		}
+	}()
+
+	stream := make(chan *dipper.Message, 1)
+	d := NewRemoteDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"address": listener.Addr().String()}})
+	d.Acquire()
+	d.Prepare(stream)
+	d.Start("operator")
+	select {
+	case <-stream:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "remote driver should stream the messages")
+	}
+
+	// the stream is full, the driver is blocked on sending when closed
+	time.Sleep(10 * time.Millisecond)
+	d.Close()
+	assert.Eventually(t, func() bool {
+		for {
+			if _, ok := <-stream; !ok {
+				return true
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond, "should close the stream after stop fetching")
+
+	closed := NewRemoteDriver(&Meta{Name: "test"})
+	closedStream := make(chan *dipper.Message, 1)
+	closed.Prepare(closedStream)
+	closed.Close()
+	_, ok := <-closedStream
+	assert.False(t, ok, "should close the stream if never started")
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ErrSandbox means the limits or the isolation settings can not be applied to the driver.
+var ErrSandbox = errors.New("sandbox error")
+
+// SandboxExecArg is the first argument for running the daemon executable as a wrapper that sets the rlimits for a
+// driver before executing it.
+const SandboxExecArg = "__sandbox_exec"
+
+// sandbox contains the resource limits and the isolation settings for a driver child process.
+type sandbox struct {
+	memory       int64  // in bytes
+	cpu          int64  // in millicores, only enforced through cgroup
+	cpuTime      uint64 // in seconds
+	openFiles    uint64
+	cgroup       string // the parent cgroup v2 directory
+	envAllowlist []string
+	uid          *uint32
+	gid          *uint32
+
+	cgroupPath string
+}
+
+// newSandbox parses the "limits" and "sandbox" in the handler data, returns nil if neither is specified.
+func newSandbox(m *Meta) *sandbox {
+	limits, hasLimits := m.HandlerData["limits"]
+	settings, hasSettings := m.HandlerData["sandbox"]
+	if !hasLimits && !hasSettings {
+		return nil
+	}
+
+	s := &sandbox{}
+	if hasLimits {
+		if err := s.parseLimits(limits); err != nil {
+			panic(fmt.Errorf("%w: invalid limits in driver %s: %v", ErrDriverError, m.Name, err))
+		}
+	}
+	if hasSettings {
+		if err := s.parseSettings(settings); err != nil {
+			panic(fmt.Errorf("%w: invalid sandbox in driver %s: %v", ErrDriverError, m.Name, err))
+		}
+	}
+	if s.cpu > 0 && s.cgroup == "" {
+		panic(fmt.Errorf("%w: cpu limit requires sandbox.cgroup in driver %s", ErrDriverError, m.Name))
+	}
+
+	return s
+}
+
+func (s *sandbox) parseLimits(v interface{}) error {
+	limits, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: should be a map", ErrSandbox)
+	}
+
+	for key, value := range limits {
+		switch key {
+		case "memory":
+			q, err := resource.ParseQuantity(fmt.Sprint(value))
+			if err != nil {
+				return fmt.Errorf("memory: %w", err)
+			}
+			s.memory = q.Value()
+		case "cpu":
+			q, err := resource.ParseQuantity(fmt.Sprint(value))
+			if err != nil {
+				return fmt.Errorf("cpu: %w", err)
+			}
+			s.cpu = q.MilliValue()
+		case "cpu_time":
+			d, err := time.ParseDuration(fmt.Sprint(value))
+			if err != nil {
+				return fmt.Errorf("cpu_time: %w", err)
+			}
+			s.cpuTime = uint64(math.Ceil(d.Seconds()))
+		case "open_files":
+			n, err := strconv.ParseUint(fmt.Sprint(value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("open_files: %w", err)
+			}
+			s.openFiles = n
+		default:
+			return fmt.Errorf("%w: unknown limit %s", ErrSandbox, key)
+		}
+	}
+
+	return nil
+}
+
+func (s *sandbox) parseSettings(v interface{}) error {
+	settings, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: should be a map", ErrSandbox)
+	}
+
+	for key, value := range settings {
+		switch key {
+		case "cgroup":
+			s.cgroup, _ = value.(string)
+		case "env_allowlist":
+			names, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%w: env_allowlist should be a list of names", ErrSandbox)
+			}
+			s.envAllowlist = []string{}
+			for _, name := range names {
+				s.envAllowlist = append(s.envAllowlist, fmt.Sprint(name))
+			}
+		case "uid", "gid":
+			n, err := strconv.ParseUint(fmt.Sprint(value), 10, 32)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			id := uint32(n)
+			if key == "uid" {
+				s.uid = &id
+			} else {
+				s.gid = &id
+			}
+		default:
+			return fmt.Errorf("%w: unknown setting %s", ErrSandbox, key)
+		}
+	}
+
+	return nil
+}
+
+// environ returns the environment variables of the daemon that the driver is allowed to inherit.
+func (s *sandbox) environ() []string {
+	env := os.Environ()
+	if s == nil || s.envAllowlist == nil {
+		return env
+	}
+
+	ret := []string{}
+	for _, e := range env {
+		name := strings.SplitN(e, "=", 2)[0]
+		for _, allowed := range s.envAllowlist {
+			if name == allowed {
+				ret = append(ret, e)
+
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+// cleanup removes the cgroup created for the driver.
+func (s *sandbox) cleanup() {
+	if s == nil || s.cgroupPath == "" {
+		return
+	}
+	if err := os.Remove(s.cgroupPath); err != nil {
+		dipper.Logger.Warningf("unable to remove driver cgroup %s: %v", s.cgroupPath, err)
+	}
+	s.cgroupPath = ""
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package driver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupCPUPeriod is the period in microseconds used for the cgroup cpu.max.
+const cgroupCPUPeriod = 100000
+
+// cgroupSeq makes the names of the cgroups created by the daemon unique.
+var cgroupSeq int64
+
+// prepare sets up the cgroup, the resource limits and the credential for the driver child process before it is
+// started. The child process joins the cgroup, sets the rlimits and drops the privileges before executing the driver,
+// through the daemon executable running as a wrapper, see SandboxExec, so the driver never runs outside the limits.
+func (s *sandbox) prepare(run *exec.Cmd, name string) error {
+	if s == nil {
+		return nil
+	}
+
+	limits := s.rlimits()
+	cred := s.credential()
+	if len(limits) == 0 && cred == "" && s.cgroup == "" {
+		return nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("%w: unable to locate the daemon executable: %v", ErrSandbox, err)
+	}
+	if s.cgroup != "" {
+		if err := s.createCgroup(name); err != nil {
+			return err
+		}
+	}
+
+	run.Args = append([]string{self, SandboxExecArg, strings.Join(limits, ","), s.cgroupPath, cred, run.Path}, run.Args...)
+	run.Path = self
+
+	return nil
+}
+
+// rlimits returns the rlimits to be set in the driver child process, each in the form of "resource:soft:hard".
+// The address space limit comes last, so the wrapper can still allocate memory when setting the others.
+func (s *sandbox) rlimits() []string {
+	limits := []string{}
+	if s.openFiles > 0 {
+		limits = append(limits, fmt.Sprintf("%d:%d:%d", unix.RLIMIT_NOFILE, s.openFiles, s.openFiles))
+	}
+	if s.cpuTime > 0 {
+		// the hard limit kills the process in case it does not exit on SIGXCPU
+		limits = append(limits, fmt.Sprintf("%d:%d:%d", unix.RLIMIT_CPU, s.cpuTime, s.cpuTime+1))
+	}
+	if s.memory > 0 && s.cgroup == "" {
+		limits = append(limits, fmt.Sprintf("%d:%d:%d", unix.RLIMIT_AS, s.memory, s.memory))
+	}
+
+	return limits
+}
+
+// credential returns the "uid:gid" the driver runs as, or empty if the driver runs as the daemon user.
+func (s *sandbox) credential() string {
+	if s.uid == nil && s.gid == nil {
+		return ""
+	}
+
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	if s.uid != nil {
+		uid = *s.uid
+	}
+	if s.gid != nil {
+		gid = *s.gid
+	}
+
+	return fmt.Sprintf("%d:%d", uid, gid)
+}
+
+// createCgroup creates the cgroup for the driver child process with the limits, the process joins it by itself.
+func (s *sandbox) createCgroup(name string) error {
+	s.cgroupPath = filepath.Join(s.cgroup, fmt.Sprintf("%s-%d-%d", name, os.Getpid(), atomic.AddInt64(&cgroupSeq, 1)))
+	if err := os.Mkdir(s.cgroupPath, 0o755); err != nil {
+		s.cgroupPath = ""
+
+		return fmt.Errorf("%w: unable to create cgroup: %v", ErrSandbox, err)
+	}
+
+	files := map[string]string{}
+	if s.memory > 0 {
+		files["memory.max"] = strconv.FormatInt(s.memory, 10)
+	}
+	if s.cpu > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", s.cpu*cgroupCPUPeriod/1000, cgroupCPUPeriod)
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(s.cgroupPath, file), []byte(content), 0o644); err != nil {
+			s.cleanup()
+
+			return fmt.Errorf("%w: unable to set %s: %v", ErrSandbox, file, err)
+		}
+	}
+
+	return nil
+}
+
+// SandboxExec joins the cgroup, sets the rlimits, drops the privileges and executes the driver if the process is
+// started as the sandbox wrapper, otherwise it returns without doing anything. It should be called at the very
+// beginning of the main function of the daemon.
+func SandboxExec() {
+	if len(os.Args) < 7 || os.Args[1] != SandboxExecArg {
+		return
+	}
+	limits, cgroupPath, cred, executable := os.Args[2], os.Args[3], os.Args[4], os.Args[5]
+
+	// joining the cgroup requires the privileges of the daemon, so it has to be done before dropping them
+	if cgroupPath != "" {
+		procs := filepath.Join(cgroupPath, "cgroup.procs")
+		if err := os.WriteFile(procs, []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+			sandboxFail("unable to join cgroup %s: %v", cgroupPath, err)
+		}
+	}
+
+	if limits != "" {
+		for _, limit := range strings.Split(limits, ",") {
+			var res int
+			var rlimit unix.Rlimit
+			if _, err := fmt.Sscanf(limit, "%d:%d:%d", &res, &rlimit.Cur, &rlimit.Max); err != nil {
+				sandboxFail("invalid rlimit %s: %v", limit, err)
+			}
+			if err := unix.Setrlimit(res, &rlimit); err != nil {
+				sandboxFail("unable to set rlimit %d: %v", res, err)
+			}
+		}
+	}
+
+	if cred != "" {
+		var uid, gid int
+		if _, err := fmt.Sscanf(cred, "%d:%d", &uid, &gid); err != nil {
+			sandboxFail("invalid credential %s: %v", cred, err)
+		}
+		// the supplementary groups are kept as is, same as the daemon
+		if err := syscall.Setgid(gid); err != nil {
+			sandboxFail("unable to set gid %d: %v", gid, err)
+		}
+		if err := syscall.Setuid(uid); err != nil {
+			sandboxFail("unable to set uid %d: %v", uid, err)
+		}
+	}
+
+	err := syscall.Exec(executable, os.Args[6:], os.Environ())
+	sandboxFail("unable to execute driver %s: %v", executable, err)
+}
+
+func sandboxFail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+// violation tells if the driver child process is killed for exceeding the limits.
+func (s *sandbox) violation(state *os.ProcessState, err error) string {
+	var exitErr *exec.ExitError
+	if s == nil || state == nil || !errors.As(err, &exitErr) {
+		return ""
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+
+	switch status.Signal() {
+	case syscall.SIGXCPU, syscall.SIGKILL:
+		if s.cpuTime > 0 && uint64((state.UserTime()+state.SystemTime()).Seconds()) >= s.cpuTime {
+			return "cpu time limit exceeded"
+		}
+		if s.memory > 0 && s.cgroupOOMKilled() {
+			return "memory limit exceeded"
+		}
+	case syscall.SIGSEGV, syscall.SIGABRT:
+		// a failed allocation under the address space limit usually ends up with one of these signals
+		if s.memory > 0 && s.cgroup == "" {
+			return "memory limit exceeded"
+		}
+	}
+
+	return ""
+}
+
+func (s *sandbox) cgroupOOMKilled() bool {
+	if s.cgroupPath == "" {
+		return false
+	}
+	f, err := os.Open(filepath.Join(s.cgroupPath, "memory.events"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" && fields[1] != "0" {
+			return true
+		}
+	}
+
+	return false
+}
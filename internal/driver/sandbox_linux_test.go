@@ -0,0 +1,186 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package driver
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestExecCommandWaitStdin(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	_, _ = io.Copy(io.Discard, os.Stdin)
+	os.Exit(0)
+}
+
+func TestExecCommandBusy(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	for {
+	}
+}
+
+func TestExecCommandAbort(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	// go runtime handles SIGABRT by itself, so the signal is raised from a shell replacing the helper
+	_ = syscall.Exec("/bin/sh", []string{"sh", "-c", "kill -ABRT $$"}, nil)
+	os.Exit(1)
+}
+
+func TestSandboxApplyLimits(t *testing.T) {
+	defer func(orig func(string, ...string) *exec.Cmd) { execCommand = orig }(execCommand)
+	execCommand = generateFakeExecCommand("TestExecCommandWaitStdin")
+
+	cgroup := t.TempDir()
+	d := NewBuiltinDriver(&Meta{Name: "test", Executable: "test", HandlerData: map[string]interface{}{
+		"limits":  map[string]interface{}{"memory": "256Mi", "cpu": "250m", "open_files": 64},
+		"sandbox": map[string]interface{}{"cgroup": cgroup},
+	}})
+	d.Prepare(make(chan *dipper.Message, 1))
+	d.Start("testsvc")
+
+	limit := unix.Rlimit{}
+	assert.Eventually(t, func() bool {
+		return unix.Prlimit(d.process.Pid, unix.RLIMIT_NOFILE, nil, &limit) == nil && limit.Cur == 64
+	}, 5*time.Second, 10*time.Millisecond, "open files should be limited before executing the driver")
+	assert.Nil(t, unix.Prlimit(d.process.Pid, unix.RLIMIT_AS, nil, &limit))
+	assert.Equal(t, uint64(unix.RLIM_INFINITY), limit.Cur, "memory should be limited through cgroup")
+
+	cgroupPath := d.sandbox.cgroupPath
+	assert.Equal(t, cgroup, filepath.Dir(cgroupPath))
+	for file, expected := range map[string]string{
+		"memory.max":   strconv.Itoa(256 * 1024 * 1024),
+		"cpu.max":      "25000 100000",
+		"cgroup.procs": strconv.Itoa(d.process.Pid),
+	} {
+		content, err := os.ReadFile(filepath.Join(cgroupPath, file))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+
+	d.Close()
+	d.Wait()
+	assert.Empty(t, d.FailureReason())
+}
+
+func TestSandboxAddressSpaceLimit(t *testing.T) {
+	defer func(orig func(string, ...string) *exec.Cmd) { execCommand = orig }(execCommand)
+	execCommand = generateFakeExecCommand("TestExecCommandWaitStdin")
+
+	d := NewBuiltinDriver(&Meta{Name: "test", Executable: "test", HandlerData: map[string]interface{}{
+		"limits": map[string]interface{}{"memory": "4Gi"},
+	}})
+	d.Prepare(make(chan *dipper.Message, 1))
+	d.Start("testsvc")
+
+	limit := unix.Rlimit{}
+	assert.Eventually(t, func() bool {
+		return unix.Prlimit(d.process.Pid, unix.RLIMIT_AS, nil, &limit) == nil && limit.Cur == 4*1024*1024*1024
+	}, 5*time.Second, 10*time.Millisecond, "address space should be limited before executing the driver")
+
+	d.Close()
+	d.Wait()
+	assert.Empty(t, d.FailureReason())
+}
+
+func TestSandboxMemoryViolation(t *testing.T) {
+	defer func(orig func(string, ...string) *exec.Cmd) { execCommand = orig }(execCommand)
+	execCommand = generateFakeExecCommand("TestExecCommandAbort")
+
+	d := NewBuiltinDriver(&Meta{Name: "test", Executable: "test", HandlerData: map[string]interface{}{
+		"limits": map[string]interface{}{"memory": "4Gi"},
+	}})
+	d.Prepare(make(chan *dipper.Message, 1))
+	d.Start("testsvc")
+	d.Wait()
+	assert.Equal(t, "memory limit exceeded", d.FailureReason(), "abort under address space limit should be a violation")
+}
+
+func TestSandboxCPUTimeViolation(t *testing.T) {
+	defer func(orig func(string, ...string) *exec.Cmd) { execCommand = orig }(execCommand)
+	execCommand = generateFakeExecCommand("TestExecCommandBusy")
+
+	d := NewBuiltinDriver(&Meta{Name: "test", Executable: "test", HandlerData: map[string]interface{}{
+		"limits": map[string]interface{}{"cpu_time": "1s"},
+	}})
+	d.Prepare(make(chan *dipper.Message, 1))
+	d.Start("testsvc")
+	d.Wait()
+	assert.Equal(t, "cpu time limit exceeded", d.FailureReason())
+}
+
+func TestSandboxPrepareCredential(t *testing.T) {
+	uid, gid := uint32(1000), uint32(2000)
+	run := exec.Command("/bin/true")
+	assert.Nil(t, (&sandbox{uid: &uid, gid: &gid}).prepare(run, "test"))
+	self, _ := os.Executable()
+	assert.Equal(t, []string{self, SandboxExecArg, "", "", "1000:2000", "/bin/true", "/bin/true"}, run.Args,
+		"should drop the privileges in the wrapper")
+	assert.Nil(t, run.SysProcAttr)
+
+	run = exec.Command("/bin/true")
+	assert.Nil(t, (&sandbox{uid: &uid}).prepare(run, "test"))
+	assert.Equal(t, strconv.Itoa(int(uid))+":"+strconv.Itoa(os.Getgid()), run.Args[4], "should keep the daemon gid")
+
+	run = exec.Command("/bin/true")
+	assert.Nil(t, (&sandbox{}).prepare(run, "test"))
+	assert.Equal(t, []string{"/bin/true"}, run.Args)
+}
+
+func TestSandboxPrepareRlimits(t *testing.T) {
+	run := exec.Command("/bin/true", "arg")
+	assert.Nil(t, (&sandbox{memory: 1024, openFiles: 64, cpuTime: 10}).prepare(run, "test"))
+	self, _ := os.Executable()
+	assert.Equal(t, self, run.Path, "should run the daemon executable as the wrapper")
+	assert.Equal(t, []string{
+		self,
+		SandboxExecArg,
+		"7:64:64,0:10:11,9:1024:1024",
+		"",
+		"",
+		"/bin/true",
+		"/bin/true",
+		"arg",
+	}, run.Args)
+}
+
+func TestSandboxPrepareCgroup(t *testing.T) {
+	cgroup := t.TempDir()
+	s := &sandbox{memory: 1024, cgroup: cgroup}
+	run := exec.Command("/bin/true")
+	assert.Nil(t, s.prepare(run, "test"))
+	defer s.cleanup()
+
+	self, _ := os.Executable()
+	assert.Equal(t, []string{self, SandboxExecArg, "", s.cgroupPath, "", "/bin/true", "/bin/true"}, run.Args,
+		"should join the cgroup in the wrapper without limiting address space")
+	content, err := os.ReadFile(filepath.Join(s.cgroupPath, "memory.max"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1024", string(content), "should set the limits before the driver is started")
+	_, err = os.Stat(filepath.Join(s.cgroupPath, "cgroup.procs"))
+	assert.True(t, os.IsNotExist(err), "should leave the joining to the wrapper")
+
+	assert.NotNil(t, (&sandbox{cgroup: filepath.Join(cgroup, "missing")}).prepare(exec.Command("/bin/true"), "test"))
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !linux
+// +build !linux
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// prepare fails if the sandbox requires features only available on linux.
+func (s *sandbox) prepare(run *exec.Cmd, name string) error {
+	if s == nil {
+		return nil
+	}
+	if s.uid != nil || s.gid != nil || s.cgroup != "" || s.memory > 0 || s.cpuTime > 0 || s.openFiles > 0 {
+		return fmt.Errorf("%w: resource limits and uid/gid are only supported on linux", ErrSandbox)
+	}
+
+	return nil
+}
+
+// SandboxExec does nothing on platforms other than linux.
+func SandboxExec() {}
+
+// violation is not detected on platforms other than linux.
+func (s *sandbox) violation(state *os.ProcessState, err error) string {
+	return ""
+}
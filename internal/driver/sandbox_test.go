@@ -0,0 +1,71 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSandbox(t *testing.T) {
+	assert.Nil(t, newSandbox(&Meta{Name: "test", HandlerData: map[string]interface{}{}}))
+
+	s := newSandbox(&Meta{Name: "test", HandlerData: map[string]interface{}{
+		"limits": map[string]interface{}{
+			"memory":     "512Mi",
+			"cpu":        "500m",
+			"cpu_time":   "1h",
+			"open_files": 1024,
+		},
+		"sandbox": map[string]interface{}{
+			"cgroup":        "/sys/fs/cgroup/honeydipper",
+			"env_allowlist": []interface{}{"PATH", "HOME"},
+			"uid":           1000,
+			"gid":           "1000",
+		},
+	}})
+	assert.Equal(t, int64(512*1024*1024), s.memory)
+	assert.Equal(t, int64(500), s.cpu)
+	assert.Equal(t, uint64(3600), s.cpuTime)
+	assert.Equal(t, uint64(1024), s.openFiles)
+	assert.Equal(t, "/sys/fs/cgroup/honeydipper", s.cgroup)
+	assert.Equal(t, []string{"PATH", "HOME"}, s.envAllowlist)
+	assert.Equal(t, uint32(1000), *s.uid)
+	assert.Equal(t, uint32(1000), *s.gid)
+
+	assert.PanicsWithError(t, "driver error: cpu limit requires sandbox.cgroup in driver test", func() {
+		newSandbox(&Meta{Name: "test", HandlerData: map[string]interface{}{"limits": map[string]interface{}{"cpu": 1}}})
+	})
+	assert.PanicsWithError(t, "driver error: invalid limits in driver test: sandbox error: unknown limit disk", func() {
+		newSandbox(&Meta{Name: "test", HandlerData: map[string]interface{}{"limits": map[string]interface{}{"disk": "1Gi"}}})
+	})
+	assert.Panics(t, func() {
+		newSandbox(&Meta{Name: "test", HandlerData: map[string]interface{}{"limits": map[string]interface{}{"memory": "lots"}}})
+	})
+	assert.PanicsWithError(t, "driver error: invalid sandbox in driver test: sandbox error: env_allowlist should be a list of names", func() {
+		newSandbox(&Meta{Name: "test", HandlerData: map[string]interface{}{"sandbox": map[string]interface{}{"env_allowlist": "PATH"}}})
+	})
+}
+
+func TestSandboxEnviron(t *testing.T) {
+	t.Setenv("HD_SANDBOX_ALLOWED", "yes")
+	t.Setenv("HD_SANDBOX_SECRET", "no")
+
+	var s *sandbox
+	assert.Contains(t, s.environ(), "HD_SANDBOX_SECRET=no", "inherit all without sandbox")
+
+	s = &sandbox{envAllowlist: []string{"HD_SANDBOX_ALLOWED"}}
+	assert.Equal(t, []string{"HD_SANDBOX_ALLOWED=yes"}, s.environ())
+
+	s = &sandbox{envAllowlist: []string{}}
+	assert.Empty(t, s.environ())
+	assert.NotNil(t, s.environ(), "empty allowlist should not inherit anything")
+}
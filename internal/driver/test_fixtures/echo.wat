@@ -0,0 +1,24 @@
+;; A minimal wasm driver used in the tests, it echoes back the rpc "echo" and fails the command "fail".
+(module
+  (import "honeydipper" "register" (func $register (param i32 i32 i32)))
+  (import "honeydipper" "reply" (func $reply (param i32 i32)))
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+  (data (i32.const 16) "echo")
+  (data (i32.const 32) "fail")
+
+  (func (export "hd_alloc") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    global.set $heap)
+
+  (func (export "hd_init")
+    (call $register (i32.const 3) (i32.const 16) (i32.const 4))
+    (call $register (i32.const 4) (i32.const 32) (i32.const 4)))
+
+  (func (export "hd_handle") (param $kind i32) (param $name i32) (param $nameLen i32) (param $msg i32) (param $msgLen i32) (result i32)
+    (if (i32.eq (local.get $kind) (i32.const 3))
+      (then (call $reply (local.get $msg) (local.get $msgLen))))
+    (i32.eq (local.get $kind) (i32.const 4))))
@@ -0,0 +1,331 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WasmHostModule is the name of the module providing the host functions to the wasm drivers.
+const WasmHostModule = "honeydipper"
+
+// The kinds of calls made into the wasm driver through hd_handle.
+const (
+	WasmCallStart = iota
+	WasmCallReload
+	WasmCallStop
+	WasmCallRPC
+	WasmCallCommand
+)
+
+// The log levels used by the wasm driver when calling the log host function.
+const (
+	WasmLogDebug = iota
+	WasmLogInfo
+	WasmLogWarning
+	WasmLogError
+)
+
+var wasmMagic = []byte("\x00asm")
+
+// WasmDriver runs a WebAssembly (WASI) module as a driver inside the daemon process. The module exports
+// hd_alloc, hd_handle and optionally hd_init and hd_free, and it uses the functions in the honeydipper
+// host module to register handlers, return values, emit events, read options and log.
+type WasmDriver struct {
+	InProcDriver
+	code    []byte
+	runtime wazero.Runtime
+}
+
+// wasmGuest holds a running instance of the wasm driver module.
+type wasmGuest struct {
+	name   string
+	lock   sync.Mutex
+	driver *dipper.Driver
+	module api.Module
+	alloc  api.Function
+	free   api.Function
+	handle api.Function
+	reply  *dipper.Message
+	ready  bool
+}
+
+// wasmCallData is how the messages are passed to and from the wasm driver.
+type wasmCallData struct {
+	Labels  map[string]string `json:"labels,omitempty"`
+	Payload interface{}       `json:"payload,omitempty"`
+}
+
+// NewWasmDriver creates a handler for the wasm driver specified in the meta info.
+func NewWasmDriver(m *Meta) *WasmDriver {
+	return &WasmDriver{InProcDriver: InProcDriver{meta: m}}
+}
+
+// Acquire function loads the wasm module from the local path or the content from the config repo.
+func (d *WasmDriver) Acquire() {
+	var source string
+	if module, ok := d.meta.HandlerData["module"].(string); ok && module != "" {
+		d.code = []byte(module)
+		source = "module"
+	} else if path, ok := d.meta.HandlerData["path"].(string); ok && path != "" {
+		code, err := os.ReadFile(path)
+		if err != nil {
+			panic(fmt.Errorf("%w: unable to read wasm module for driver %s: %v", ErrDriverError, d.meta.Name, err))
+		}
+		d.code = code
+		source = path
+	} else {
+		panic(fmt.Errorf("%w: module or path is missing for wasm driver: %s", ErrDriverError, d.meta.Name))
+	}
+
+	if !bytes.HasPrefix(d.code, wasmMagic) {
+		panic(fmt.Errorf("%w: not a wasm module for driver %s: %s", ErrDriverError, d.meta.Name, source))
+	}
+
+	// keep the module content out of the meta, which is compared and logged
+	sum := sha256.Sum256(d.code)
+	handlerData := map[string]interface{}{}
+	for k, v := range d.meta.HandlerData {
+		if k != "module" {
+			handlerData[k] = v
+		}
+	}
+	handlerData["sha256"] = hex.EncodeToString(sum[:])
+	d.meta.HandlerData = handlerData
+	d.meta.Executable = "wasm:" + source
+}
+
+// Start instantiates the wasm module and starts the driver message loop.
+func (d *WasmDriver) Start(service string) {
+	ctx := context.Background()
+	config := wazero.NewRuntimeConfig()
+	if pages, ok := d.meta.HandlerData["max_memory_pages"]; ok {
+		config = config.WithMemoryLimitPages(uint32(dipper.Must(strconv.Atoi(fmt.Sprint(pages))).(int)))
+	}
+	d.runtime = wazero.NewRuntimeWithConfig(ctx, config)
+	wasi_snapshot_preview1.MustInstantiate(ctx, d.runtime)
+
+	guest := &wasmGuest{
+		name:   fmt.Sprintf("%s-%s", service, d.meta.Name),
+		driver: dipper.NewDriver(service, d.meta.Name),
+	}
+	dipper.Must(guest.hostModule(d.runtime).Instantiate(ctx))
+	guest.module = dipper.Must(d.runtime.InstantiateWithConfig(ctx, d.code, wazero.NewModuleConfig().
+		WithName(d.meta.Name).
+		WithArgs(d.meta.Name, service).
+		WithStartFunctions("_initialize").
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime(),
+	)).(api.Module)
+	guest.init(ctx)
+
+	d.factory = func(string) *dipper.Driver {
+		return guest.driver
+	}
+	d.InProcDriver.Start(service)
+}
+
+// Close stops the driver message loop and releases the wasm runtime.
+func (d *WasmDriver) Close() {
+	d.InProcDriver.Close()
+	if d.runtime != nil {
+		_ = d.runtime.Close(context.Background())
+		d.runtime = nil
+	}
+}
+
+// hostModule defines the functions available to the wasm driver.
+func (g *wasmGuest) hostModule(r wazero.Runtime) wazero.HostModuleBuilder {
+	return r.NewHostModuleBuilder(WasmHostModule).
+		NewFunctionBuilder().WithFunc(g.register).Export("register").
+		NewFunctionBuilder().WithFunc(g.setReply).Export("reply").
+		NewFunctionBuilder().WithFunc(g.emitEvent).Export("emit_event").
+		NewFunctionBuilder().WithFunc(g.getOption).Export("get_option").
+		NewFunctionBuilder().WithFunc(g.log).Export("log")
+}
+
+// init looks up the exported functions, and wires the driver handlers to the module.
+func (g *wasmGuest) init(ctx context.Context) {
+	g.alloc = g.module.ExportedFunction("hd_alloc")
+	g.handle = g.module.ExportedFunction("hd_handle")
+	g.free = g.module.ExportedFunction("hd_free")
+	if g.alloc == nil || g.handle == nil {
+		panic(fmt.Errorf("%w: wasm driver %s should export hd_alloc and hd_handle", ErrDriverError, g.name))
+	}
+
+	g.driver.Start = func(msg *dipper.Message) { g.call(WasmCallStart, "", nil) }
+	g.driver.Reload = func(msg *dipper.Message) { g.call(WasmCallReload, "", nil) }
+	g.driver.Stop = func(msg *dipper.Message) { g.call(WasmCallStop, "", nil) }
+
+	if hdInit := g.module.ExportedFunction("hd_init"); hdInit != nil {
+		dipper.Must(hdInit.Call(ctx))
+	}
+	g.ready = true
+}
+
+// call passes a message to hd_handle in the module and returns the reply.
+func (g *wasmGuest) call(kind int, name string, msg *dipper.Message) *dipper.Message {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var data []byte
+	if msg != nil {
+		msg = dipper.DeserializePayload(msg)
+		data = dipper.Must(json.Marshal(wasmCallData{Labels: msg.Labels, Payload: msg.Payload})).([]byte)
+	}
+
+	ctx := context.Background()
+	namePtr := g.write(ctx, []byte(name))
+	dataPtr := g.write(ctx, data)
+	defer g.release(ctx, namePtr, len(name))
+	defer g.release(ctx, dataPtr, len(data))
+
+	g.reply = nil
+	ret, err := g.handle.Call(ctx, uint64(kind), uint64(namePtr), uint64(len(name)), uint64(dataPtr), uint64(len(data)))
+	if err != nil {
+		panic(fmt.Errorf("%w: wasm driver %s failed: %v", ErrDriverError, g.name, err))
+	}
+
+	reply := g.reply
+	if reply == nil {
+		reply = &dipper.Message{}
+	}
+	if ret[0] != 0 {
+		if _, ok := reply.Labels["error"]; !ok {
+			reply.Labels = map[string]string{"error": fmt.Sprintf("wasm driver returned %d", int32(ret[0]))}
+		}
+	}
+
+	return reply
+}
+
+// handler creates a message handler for a rpc method or a command in the module.
+func (g *wasmGuest) handler(kind int, name string) dipper.MessageHandler {
+	return func(msg *dipper.Message) {
+		msg.Reply <- *g.call(kind, name, msg)
+	}
+}
+
+// write copies the data into the memory allocated by the module.
+func (g *wasmGuest) write(ctx context.Context, data []byte) uint32 {
+	if len(data) == 0 {
+		return 0
+	}
+	ret, err := g.alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		panic(fmt.Errorf("%w: wasm driver %s failed to allocate memory: %v", ErrDriverError, g.name, err))
+	}
+	ptr := uint32(ret[0])
+	if !g.module.Memory().Write(ptr, data) {
+		panic(fmt.Errorf("%w: wasm driver %s allocated invalid memory", ErrDriverError, g.name))
+	}
+
+	return ptr
+}
+
+// release frees the memory allocated by the module if hd_free is exported.
+func (g *wasmGuest) release(ctx context.Context, ptr uint32, size int) {
+	if g.free != nil && ptr != 0 {
+		_, _ = g.free.Call(ctx, uint64(ptr), uint64(size))
+	}
+}
+
+// read copies the data out of the module memory.
+func (g *wasmGuest) read(m api.Module, ptr, size uint32) []byte {
+	buf, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		panic(fmt.Errorf("%w: wasm driver %s accessed invalid memory", ErrDriverError, g.name))
+	}
+
+	return append([]byte{}, buf...)
+}
+
+// register is a host function for the module to provide a rpc method or a command.
+func (g *wasmGuest) register(ctx context.Context, m api.Module, kind, namePtr, nameLen uint32) {
+	name := string(g.read(m, namePtr, nameLen))
+	if g.ready {
+		panic(fmt.Errorf("%w: wasm driver %s should register %s in hd_init", ErrDriverError, g.name, name))
+	}
+	switch kind {
+	case WasmCallRPC:
+		g.driver.RPCHandlers[name] = g.handler(WasmCallRPC, name)
+	case WasmCallCommand:
+		g.driver.CommandProvider.Commands[name] = g.handler(WasmCallCommand, name)
+	default:
+		panic(fmt.Errorf("%w: wasm driver %s registering unknown kind %d", ErrDriverError, g.name, kind))
+	}
+}
+
+// setReply is a host function for the module to return value for the current rpc or command.
+func (g *wasmGuest) setReply(ctx context.Context, m api.Module, ptr, size uint32) {
+	var data wasmCallData
+	if err := json.Unmarshal(g.read(m, ptr, size), &data); err != nil {
+		panic(fmt.Errorf("%w: wasm driver %s returned invalid data: %v", ErrDriverError, g.name, err))
+	}
+	g.reply = &dipper.Message{Labels: data.Labels, Payload: data.Payload}
+}
+
+// emitEvent is a host function for the module to send an event to the daemon.
+func (g *wasmGuest) emitEvent(ctx context.Context, m api.Module, ptr, size uint32) {
+	payload, ok := dipper.DeserializeContent(g.read(m, ptr, size)).(map[string]interface{})
+	if !ok {
+		panic(fmt.Errorf("%w: wasm driver %s emitting invalid event", ErrDriverError, g.name))
+	}
+	g.driver.EmitEvent(payload)
+}
+
+// getOption is a host function for the module to read the driver options, returns the pointer to
+// the json data in the high 32 bits and the length in the low 32 bits, or 0 if not found.
+func (g *wasmGuest) getOption(ctx context.Context, m api.Module, pathPtr, pathLen uint32) uint64 {
+	var (
+		option interface{}
+		ok     bool
+	)
+	if path := string(g.read(m, pathPtr, pathLen)); path == "" {
+		option, ok = g.driver.Options, g.driver.Options != nil
+	} else {
+		option, ok = g.driver.GetOption(path)
+	}
+	if !ok {
+		return 0
+	}
+
+	data := dipper.SerializeContent(option)
+	ptr := g.write(ctx, data)
+
+	return uint64(ptr)<<32 | uint64(len(data))
+}
+
+// log is a host function for the module to write logs through the daemon logger.
+func (g *wasmGuest) log(ctx context.Context, m api.Module, level, ptr, size uint32) {
+	text := string(g.read(m, ptr, size))
+	switch level {
+	case WasmLogDebug:
+		dipper.Logger.Debugf("[%s] %s", g.name, text)
+	case WasmLogInfo:
+		dipper.Logger.Infof("[%s] %s", g.name, text)
+	case WasmLogWarning:
+		dipper.Logger.Warningf("[%s] %s", g.name, text)
+	default:
+		dipper.Logger.Errorf("[%s] %s", g.name, text)
+	}
+}
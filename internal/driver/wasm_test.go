@@ -0,0 +1,100 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package driver
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWasmAcquire(t *testing.T) {
+	d := NewWasmDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"path": "test_fixtures/echo.wasm"}})
+	d.Acquire()
+	assert.Equal(t, "wasm:test_fixtures/echo.wasm", d.meta.Executable)
+	assert.Len(t, d.meta.HandlerData["sha256"], 64, "meta should include the checksum of the module")
+
+	code, err := os.ReadFile("test_fixtures/echo.wasm")
+	assert.Nil(t, err)
+	handlerData := map[string]interface{}{"module": string(code)}
+	d = NewWasmDriver(&Meta{Name: "test", HandlerData: handlerData})
+	d.Acquire()
+	assert.Equal(t, "wasm:module", d.meta.Executable)
+	assert.NotContains(t, d.meta.HandlerData, "module", "module content should not be kept in meta")
+	assert.Contains(t, handlerData, "module", "driver data should not be changed")
+
+	assert.PanicsWithError(t, "driver error: module or path is missing for wasm driver: test", func() {
+		NewWasmDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{}}).Acquire()
+	})
+	assert.PanicsWithError(t, "driver error: not a wasm module for driver test: module", func() {
+		NewWasmDriver(&Meta{Name: "test", HandlerData: map[string]interface{}{"module": "#!/bin/bash"}}).Acquire()
+	})
+}
+
+func TestWasmLifecycle(t *testing.T) {
+	runtime := NewDriver("test", map[string]interface{}{
+		"name":        "test",
+		"type":        "wasm",
+		"handlerData": map[string]interface{}{"path": "test_fixtures/echo.wasm"},
+	}, map[string]interface{}{"key": "val"}, nil)
+	runtime.Start("operator")
+
+	fetch := func() *dipper.Message {
+		select {
+		case msg := <-runtime.Stream:
+			return msg
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "expecting message from wasm driver")
+		}
+
+		return nil
+	}
+
+	state := fetch()
+	assert.Equal(t, "state", state.Channel)
+	assert.Equal(t, dipper.StateHandshake, state.Subject, "driver should announce its capabilities when started")
+	handshake, err := dipper.ParseHandshake(state)
+	assert.Nil(t, err)
+	assert.Contains(t, handshake.RPCs, "echo", "rpc methods registered by the module should be announced")
+
+	state = fetch()
+	assert.Equal(t, "state", state.Channel)
+	assert.Equal(t, "alive", state.Subject, "driver should be alive after receiving options")
+
+	runtime.SendMessage(&dipper.Message{
+		Channel: "rpc",
+		Subject: "call",
+		Labels:  map[string]string{"method": "echo", "rpcID": "1", "caller": "operator"},
+		Payload: map[string]interface{}{"foo": "bar"},
+	})
+	ret := fetch()
+	assert.Equal(t, "rpc", ret.Channel)
+	assert.Equal(t, "1", ret.Labels["rpcID"])
+	assert.NotContains(t, ret.Labels, "error")
+	assert.Equal(t, map[string]interface{}{"foo": "bar"}, dipper.DeserializePayload(ret).Payload, "rpc should be handled by the module")
+
+	runtime.SendMessage(&dipper.Message{
+		Channel: "eventbus",
+		Subject: "command",
+		Labels:  map[string]string{"method": "fail", "sessionID": "1"},
+	})
+	ret = fetch()
+	assert.Equal(t, "return", ret.Subject)
+	assert.Equal(t, "error", ret.Labels["status"], "command should fail when the module returns non-zero")
+	assert.Equal(t, "wasm driver returned 1", ret.Labels["reason"])
+
+	runtime.Handler.Close()
+	runtime.Handler.Wait()
+	_, ok := <-runtime.Stream
+	assert.False(t, ok, "stream should be closed")
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/internal/daemon"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// CacheLabel is the label used for tracking the cache key of a function call.
+	CacheLabel = "cache_key"
+	// CacheTTLLabel is the label used for tracking how long the return of a function call should be cached.
+	CacheTTLLabel = "cache_ttl"
+	// CachePrefix is the prefix for the cached function returns in the result store.
+	CachePrefix = "cache:"
+	// CacheGenerationPrefix is the prefix for the cache generation of the systems in the result store.
+	CacheGenerationPrefix = "cache-generation:"
+	// DefaultCacheTTL is the default time to cache the return of a function.
+	DefaultCacheTTL = time.Minute
+	// CacheGenerationTTL is how long a cache generation is kept, should be longer than any cache TTL.
+	CacheGenerationTTL = 30 * 24 * time.Hour
+)
+
+var (
+	cacheStore     ResultStore
+	cacheStoreName string
+)
+
+// loadCacheConfig loads the store from driver.daemon.services.operator.cache.
+func loadCacheConfig(cfg *config.Config) {
+	storeName, _ := dipper.GetMapDataStr(cfg.DataSet.Drivers, "daemon.services.operator.cache.store")
+	if cacheStore == nil || storeName != cacheStoreName {
+		cacheStore = NewResultStore(storeName, operator)
+		cacheStoreName = storeName
+	}
+}
+
+// functionCache returns the cache setting of the function, the outermost definition wins.
+func functionCache(f *config.Function) *config.FunctionCache {
+	for _, fn := range functionChain(f) {
+		if fn.Cache != nil {
+			return fn.Cache
+		}
+	}
+
+	return nil
+}
+
+// cacheKey builds the key for caching a function return. The key includes the cache generation of all the
+// systems the function is collapsed through, so that invalidating a system makes its cached returns unreachable.
+func cacheKey(systems []string, driver string, rawAction string, key string, params interface{}) string {
+	if key == "" {
+		hash := sha256.Sum256(dipper.Must(json.Marshal(params)).([]byte))
+		key = hex.EncodeToString(hash[:])
+	}
+
+	generations := make([]string, len(systems))
+	for i, system := range systems {
+		if gen, ok := cacheStore.Load(CacheGenerationPrefix + system); ok {
+			generations[i] = string(gen)
+		}
+	}
+
+	return CachePrefix + driver + "." + rawAction + ":" + strings.Join(generations, ",") + ":" + key
+}
+
+// loadCachedReturn returns the cached return for the call if there is one.
+func loadCachedReturn(key string, msg *dipper.Message, driver string, rawAction string) (*dipper.Message, bool) {
+	tags := []string{"driver:" + driver, "rawAction:" + rawAction}
+	stored, ok := cacheStore.Load(key)
+	if !ok {
+		if emitter, ok := daemon.Emitters[operator.name]; ok {
+			emitter.CounterIncr("honey.honeydipper.operator.cache_miss", tags)
+		}
+
+		return nil, false
+	}
+
+	if emitter, ok := daemon.Emitters[operator.name]; ok {
+		emitter.CounterIncr("honey.honeydipper.operator.cache_hit", tags)
+	}
+	dipper.Logger.Debugf("[operator] returning cached result for [%s]", key)
+
+	return storedReply(msg, decodeStoredResult(stored)), true
+}
+
+// recordCachedReturn caches the return of a successful call.
+func recordCachedReturn(msg *dipper.Message) {
+	key, ok := msg.Labels[CacheLabel]
+	if !ok {
+		return
+	}
+	ttl := msg.Labels[CacheTTLLabel]
+	delete(msg.Labels, CacheLabel)
+	delete(msg.Labels, CacheTTLLabel)
+	defer dipper.SafeExitOnError("[operator] failed to cache return for [%s]", key)
+
+	if msg.Labels["status"] != dipper.SUCCESS {
+		return
+	}
+
+	msg = dipper.DeserializePayload(msg)
+	result := storedResult{
+		Labels:  map[string]string{"status": msg.Labels["status"]},
+		Payload: msg.Payload,
+	}
+	cacheStore.Save(key, dipper.Must(json.Marshal(result)).([]byte), dipper.Must(time.ParseDuration(ttl)).(time.Duration))
+}
+
+// invalidateSystemCache makes all cached returns of the functions in the system unreachable.
+func invalidateSystemCache(system string) {
+	cacheStore.Save(CacheGenerationPrefix+system, []byte(dipper.NewUUID()), CacheGenerationTTL)
+	dipper.Logger.Infof("[operator] invalidated cache for system [%s]", system)
+}
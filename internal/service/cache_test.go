@@ -0,0 +1,100 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package service
+
+import (
+	"testing"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionCache(t *testing.T) {
+	operator = &Service{
+		name: "operator",
+		config: &config.Config{
+			DataSet: &config.DataSet{
+				Systems: map[string]config.System{
+					"gcloud-storage": {
+						Functions: map[string]config.Function{
+							"listFiles": {Driver: "gcloud-storage", RawAction: "listFiles", Cache: &config.FunctionCache{TTL: "10s"}},
+						},
+					},
+					"my-bucket": {
+						Functions: map[string]config.Function{
+							"listFiles":    {Target: config.Action{System: "gcloud-storage", Function: "listFiles"}},
+							"listArchives": {Target: config.Action{System: "gcloud-storage", Function: "listFiles"}, Cache: &config.FunctionCache{TTL: "1h"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	defer func() { operator = nil }()
+
+	assert.Equal(t, &config.FunctionCache{TTL: "10s"}, functionCache(&config.Function{Target: config.Action{System: "my-bucket", Function: "listFiles"}}))
+	assert.Equal(t, &config.FunctionCache{TTL: "1h"}, functionCache(&config.Function{Target: config.Action{System: "my-bucket", Function: "listArchives"}}))
+	assert.Nil(t, functionCache(&config.Function{Driver: "gcloud-storage", RawAction: "listFiles"}))
+}
+
+func TestCachedReturn(t *testing.T) {
+	operator = &Service{name: "operator"}
+	cacheStore = NewResultStore(ResultStoreMemory, nil)
+	defer func() {
+		operator = nil
+		cacheStore = nil
+	}()
+
+	systems := []string{"my-bucket", "gcloud-storage"}
+	params := map[string]interface{}{"bucket": "foo"}
+	key := cacheKey(systems, "gcloud-storage", "listFiles", "", params)
+	assert.Equal(t, key, cacheKey(systems, "gcloud-storage", "listFiles", "", map[string]interface{}{"bucket": "foo"}), "same params should use same key")
+	assert.NotEqual(t, key, cacheKey(systems, "gcloud-storage", "listFiles", "", map[string]interface{}{"bucket": "bar"}), "different params should use different keys")
+	assert.Equal(t, "cache:gcloud-storage.listFiles:,:foo", cacheKey(systems, "gcloud-storage", "listFiles", "foo", params))
+
+	cmd := &dipper.Message{Labels: map[string]string{"sessionID": "1", "method": "listFiles"}}
+	_, ok := loadCachedReturn(key, cmd, "gcloud-storage", "listFiles")
+	assert.False(t, ok, "should miss before anything is cached")
+
+	recordCachedReturn(&dipper.Message{
+		Labels:  map[string]string{"status": "failure", "reason": "not found", CacheLabel: key, CacheTTLLabel: "1m"},
+		Payload: map[string]interface{}{},
+	})
+	_, ok = loadCachedReturn(key, cmd, "gcloud-storage", "listFiles")
+	assert.False(t, ok, "unsuccessful returns should not be cached")
+
+	ret := &dipper.Message{
+		Labels:  map[string]string{"status": "success", CacheLabel: key, CacheTTLLabel: "1m"},
+		Payload: map[string]interface{}{"files": []interface{}{"a", "b"}},
+	}
+	recordCachedReturn(ret)
+	assert.NotContains(t, ret.Labels, CacheLabel, "cache label should be removed from return")
+	assert.NotContains(t, ret.Labels, CacheTTLLabel, "cache ttl label should be removed from return")
+
+	reply, ok := loadCachedReturn(key, cmd, "gcloud-storage", "listFiles")
+	assert.True(t, ok, "should hit after the return is cached")
+	assert.Equal(t, &dipper.Message{
+		Channel: dipper.ChannelEventbus,
+		Subject: dipper.EventbusReturn,
+		Labels:  map[string]string{"sessionID": "1", "method": "listFiles", "status": "success"},
+		Payload: map[string]interface{}{"files": []interface{}{"a", "b"}},
+	}, reply)
+
+	invalidateSystemCache("gcloud-storage")
+	newKey := cacheKey(systems, "gcloud-storage", "listFiles", "", params)
+	assert.NotEqual(t, key, newKey, "invalidating a system should change the key")
+	_, ok = loadCachedReturn(newKey, cmd, "gcloud-storage", "listFiles")
+	assert.False(t, ok, "should miss after the system is invalidated")
+
+	direct := cacheKey([]string{"gcloud-storage"}, "gcloud-storage", "listFiles", "", params)
+	invalidateSystemCache("my-bucket")
+	assert.Equal(t, direct, cacheKey([]string{"gcloud-storage"}, "gcloud-storage", "listFiles", "", params), "invalidating a system should not affect other systems")
+}
@@ -0,0 +1,313 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package service
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/internal/daemon"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// CircuitClosed means calls are allowed and failures are being counted.
+	CircuitClosed = "closed"
+	// CircuitOpen means calls are rejected without reaching the driver.
+	CircuitOpen = "open"
+	// CircuitHalfOpen means a limited number of probe calls are allowed to test the downstream.
+	CircuitHalfOpen = "half_open"
+
+	// DefaultCircuitFailureThreshold is the default number of failures within the window to open a circuit.
+	DefaultCircuitFailureThreshold = 5
+	// DefaultCircuitWindow is the default window in which failures are counted.
+	DefaultCircuitWindow = time.Minute
+	// DefaultCircuitCooldown is the default time a circuit stays open before allowing probes.
+	DefaultCircuitCooldown = 30 * time.Second
+	// DefaultCircuitHalfOpenProbes is the default number of probe calls allowed in half open state.
+	DefaultCircuitHalfOpenProbes = 1
+
+	// CircuitBreakerLabel is the label used for tracking the circuit breakers guarding a function call.
+	CircuitBreakerLabel = "circuit_breakers"
+)
+
+// ErrCircuitOpen is raised when a function call is rejected by an open circuit.
+var ErrCircuitOpen = errors.New("circuit_open")
+
+var (
+	circuitBreakers    = map[string]*CircuitBreaker{}
+	circuitBreakerLock sync.Mutex
+)
+
+// CircuitBreakerConfig defines which function calls a circuit breaker guards and how it trips.
+type CircuitBreakerConfig struct {
+	System           string
+	Driver           string
+	RawAction        string `json:"rawAction" mapstructure:"rawAction"`
+	FailureThreshold int    `json:"failure_threshold" mapstructure:"failure_threshold"`
+	Window           string
+	HalfOpenProbes   int `json:"half_open_probes" mapstructure:"half_open_probes"`
+	Cooldown         string
+}
+
+// CircuitBreaker keeps track of the failures of the guarded function calls.
+type CircuitBreaker struct {
+	Name   string
+	Config CircuitBreakerConfig
+
+	threshold int
+	probes    int
+	window    time.Duration
+	cooldown  time.Duration
+
+	state     string
+	failures  []time.Time
+	changedAt time.Time
+	inflight  int
+	successes int
+	rejected  int
+}
+
+// NewCircuitBreaker creates a circuit breaker in closed state from the given config.
+func NewCircuitBreaker(name string, cfg CircuitBreakerConfig) *CircuitBreaker {
+	b := &CircuitBreaker{
+		Name:      name,
+		Config:    cfg,
+		threshold: cfg.FailureThreshold,
+		probes:    cfg.HalfOpenProbes,
+		window:    DefaultCircuitWindow,
+		cooldown:  DefaultCircuitCooldown,
+		state:     CircuitClosed,
+		changedAt: time.Now(),
+	}
+	if b.threshold <= 0 {
+		b.threshold = DefaultCircuitFailureThreshold
+	}
+	if b.probes <= 0 {
+		b.probes = DefaultCircuitHalfOpenProbes
+	}
+	if cfg.Window != "" {
+		b.window = dipper.Must(time.ParseDuration(cfg.Window)).(time.Duration)
+	}
+	if cfg.Cooldown != "" {
+		b.cooldown = dipper.Must(time.ParseDuration(cfg.Cooldown)).(time.Duration)
+	}
+
+	return b
+}
+
+// Matches checks if the function call, identified by its systems, driver and rawAction, is guarded by the breaker.
+func (b *CircuitBreaker) Matches(systems []string, driver string, rawAction string) bool {
+	if b.Config.System != "" {
+		for _, s := range systems {
+			if s == b.Config.System {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return b.Config.Driver != "" && b.Config.Driver == driver && (b.Config.RawAction == "" || b.Config.RawAction == rawAction)
+}
+
+// Allow decides whether a call can go through, and moves the breaker into half open state after the cool-down.
+func (b *CircuitBreaker) Allow(now time.Time) bool {
+	if b.state == CircuitOpen && now.Sub(b.changedAt) >= b.cooldown {
+		b.setState(CircuitHalfOpen, now)
+	}
+
+	switch b.state {
+	case CircuitOpen:
+		b.rejected++
+
+		return false
+	case CircuitHalfOpen:
+		if b.inflight >= b.probes {
+			if now.Sub(b.changedAt) < b.cooldown {
+				b.rejected++
+
+				return false
+			}
+			// probes never returned, give new probes a chance
+			b.inflight = 0
+			b.changedAt = now
+		}
+		b.inflight++
+	}
+
+	return true
+}
+
+// Record updates the breaker with the result of a call.
+func (b *CircuitBreaker) Record(success bool, now time.Time) {
+	switch b.state {
+	case CircuitClosed:
+		if success {
+			return
+		}
+		b.failures = append(b.failures, now)
+		b.trimFailures(now)
+		if len(b.failures) >= b.threshold {
+			b.setState(CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if b.inflight > 0 {
+			b.inflight--
+		}
+		if !success {
+			b.setState(CircuitOpen, now)
+
+			return
+		}
+		b.successes++
+		if b.successes >= b.probes {
+			b.setState(CircuitClosed, now)
+		}
+	}
+}
+
+// Status returns the current state of the breaker for reporting.
+func (b *CircuitBreaker) Status(now time.Time) map[string]interface{} {
+	b.trimFailures(now)
+
+	return map[string]interface{}{
+		"name":      b.Name,
+		"state":     b.state,
+		"since":     b.changedAt.Format(time.RFC3339),
+		"failures":  len(b.failures),
+		"rejected":  b.rejected,
+		"threshold": b.threshold,
+		"window":    b.window.String(),
+		"cooldown":  b.cooldown.String(),
+		"system":    b.Config.System,
+		"driver":    b.Config.Driver,
+		"rawAction": b.Config.RawAction,
+	}
+}
+
+func (b *CircuitBreaker) setState(state string, now time.Time) {
+	dipper.Logger.Warningf("[operator] circuit breaker [%s] state change %s -> %s", b.Name, b.state, state)
+	b.state = state
+	b.changedAt = now
+	b.failures = nil
+	b.inflight = 0
+	b.successes = 0
+}
+
+func (b *CircuitBreaker) trimFailures(now time.Time) {
+	i := 0
+	for i < len(b.failures) && now.Sub(b.failures[i]) > b.window {
+		i++
+	}
+	b.failures = b.failures[i:]
+}
+
+// loadCircuitBreakers builds the circuit breakers from driver.daemon.services.operator.circuit_breakers, keeping
+// the state of the breakers with unchanged configuration.
+func loadCircuitBreakers(cfg *config.Config) {
+	cfgItem, _ := dipper.GetMapData(cfg.DataSet.Drivers, "daemon.services.operator.circuit_breakers")
+	breakerCfgs := map[string]CircuitBreakerConfig{}
+	dipper.Must(mapstructure.Decode(cfgItem, &breakerCfgs))
+
+	circuitBreakerLock.Lock()
+	defer circuitBreakerLock.Unlock()
+	loaded := map[string]*CircuitBreaker{}
+	for name, breakerCfg := range breakerCfgs {
+		if existing, ok := circuitBreakers[name]; ok && reflect.DeepEqual(existing.Config, breakerCfg) {
+			loaded[name] = existing
+
+			continue
+		}
+		func() {
+			defer dipper.SafeExitOnError("[operator] skipping invalid circuit breaker [%s]", name)
+			loaded[name] = NewCircuitBreaker(name, breakerCfg)
+		}()
+	}
+	circuitBreakers = loaded
+}
+
+// checkCircuitBreakers panics with ErrCircuitOpen if any breaker guarding the call is open, otherwise returns
+// the names of the guarding breakers.
+func checkCircuitBreakers(systems []string, driver string, rawAction string) []string {
+	circuitBreakerLock.Lock()
+	defer circuitBreakerLock.Unlock()
+
+	now := time.Now()
+	names := []string{}
+	for name, b := range circuitBreakers {
+		if b.Matches(systems, driver, rawAction) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	allowed := []string{}
+	for _, name := range names {
+		if !circuitBreakers[name].Allow(now) {
+			// give back the probes taken by the breakers checked so far
+			for _, n := range allowed {
+				if b := circuitBreakers[n]; b.state == CircuitHalfOpen && b.inflight > 0 {
+					b.inflight--
+				}
+			}
+			if emitter, ok := daemon.Emitters[operator.name]; ok {
+				emitter.CounterIncr("honey.honeydipper.operator.circuit_open", []string{"breaker:" + name})
+			}
+			panic(ErrCircuitOpen)
+		}
+		allowed = append(allowed, name)
+	}
+
+	return allowed
+}
+
+// recordCircuitBreakers updates the breakers that guarded the call with the returned status.
+func recordCircuitBreakers(msg *dipper.Message) {
+	names, ok := msg.Labels[CircuitBreakerLabel]
+	if !ok {
+		return
+	}
+	delete(msg.Labels, CircuitBreakerLabel)
+	if names == "" {
+		return
+	}
+
+	success := msg.Labels["status"] != "error"
+	now := time.Now()
+
+	circuitBreakerLock.Lock()
+	defer circuitBreakerLock.Unlock()
+	for _, name := range strings.Split(names, ",") {
+		if b, ok := circuitBreakers[name]; ok {
+			b.Record(success, now)
+		}
+	}
+}
+
+// circuitBreakerMetrics emits the state of all circuit breakers, 0 for closed, 1 for half open and 2 for open.
+func circuitBreakerMetrics() {
+	circuitBreakerLock.Lock()
+	defer circuitBreakerLock.Unlock()
+	for name, b := range circuitBreakers {
+		value := 0
+		switch b.state {
+		case CircuitHalfOpen:
+			value = 1
+		case CircuitOpen:
+			value = 2
+		}
+		operator.GaugeSet("honey.honeydipper.operator.circuit_breaker", strconv.Itoa(value), []string{"breaker:" + name})
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerMatches(t *testing.T) {
+	b := NewCircuitBreaker("sys", CircuitBreakerConfig{System: "github"})
+	assert.True(t, b.Matches([]string{"my-github", "github"}, "web", "request"), "should match a system in the chain")
+	assert.False(t, b.Matches([]string{"gitlab"}, "web", "request"), "should not match other systems")
+
+	b = NewCircuitBreaker("drv", CircuitBreakerConfig{Driver: "web"})
+	assert.True(t, b.Matches(nil, "web", "request"), "should match all rawActions of a driver")
+	assert.False(t, b.Matches(nil, "kubernetes", "request"), "should not match other drivers")
+
+	b = NewCircuitBreaker("act", CircuitBreakerConfig{Driver: "kubernetes", RawAction: "recycleDeployment"})
+	assert.True(t, b.Matches(nil, "kubernetes", "recycleDeployment"), "should match the rawAction")
+	assert.False(t, b.Matches(nil, "kubernetes", "createJob"), "should not match other rawActions")
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	b := NewCircuitBreaker("test", CircuitBreakerConfig{
+		FailureThreshold: 2,
+		Window:           "1m",
+		Cooldown:         "30s",
+		HalfOpenProbes:   2,
+	})
+	now := time.Now()
+
+	assert.True(t, b.Allow(now))
+	b.Record(false, now)
+	b.Record(false, now.Add(2*time.Minute))
+	assert.Equal(t, CircuitClosed, b.state, "failures outside of the window should not trip the breaker")
+
+	b.Record(false, now.Add(2*time.Minute+time.Second))
+	assert.Equal(t, CircuitOpen, b.state, "breaker should open when threshold is reached")
+
+	now = now.Add(2*time.Minute + 2*time.Second)
+	assert.False(t, b.Allow(now), "open breaker should reject calls")
+
+	now = now.Add(30 * time.Second)
+	assert.True(t, b.Allow(now), "breaker should allow probe after cool-down")
+	assert.Equal(t, CircuitHalfOpen, b.state)
+	assert.True(t, b.Allow(now), "breaker should allow the configured number of probes")
+	assert.False(t, b.Allow(now), "breaker should reject calls when probes are exhausted")
+
+	b.Record(true, now)
+	assert.Equal(t, CircuitHalfOpen, b.state, "breaker should stay half open until all probes succeed")
+	b.Record(true, now)
+	assert.Equal(t, CircuitClosed, b.state, "breaker should close after probes succeed")
+
+	b.Record(false, now)
+	b.Record(false, now)
+	now = now.Add(30 * time.Second)
+	assert.True(t, b.Allow(now))
+	b.Record(false, now)
+	assert.Equal(t, CircuitOpen, b.state, "breaker should reopen when a probe fails")
+	assert.Equal(t, 2, b.rejected)
+}
+
+func TestCircuitBreakerCheckAndRecord(t *testing.T) {
+	operator = &Service{
+		name: "operator",
+		config: &config.Config{
+			DataSet: &config.DataSet{
+				Drivers: map[string]interface{}{
+					"daemon": map[string]interface{}{
+						"services": map[string]interface{}{
+							"operator": map[string]interface{}{
+								"circuit_breakers": map[string]interface{}{
+									"github": map[string]interface{}{
+										"system":            "github",
+										"failure_threshold": 1,
+										"cooldown":          "1h",
+									},
+									"web": map[string]interface{}{
+										"driver": "web",
+									},
+								},
+							},
+						},
+					},
+				},
+				Systems: map[string]config.System{
+					"github": {
+						Functions: map[string]config.Function{
+							"api": {Driver: "web", RawAction: "request"},
+						},
+					},
+					"my-github": {
+						Functions: map[string]config.Function{
+							"getRepo": {Target: config.Action{System: "github", Function: "api"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	defer func() {
+		operator = nil
+		circuitBreakers = map[string]*CircuitBreaker{}
+	}()
+	loadCircuitBreakers(operator.config)
+	assert.Len(t, circuitBreakers, 2)
+
+	systems := functionSystems(&config.Function{Target: config.Action{System: "my-github", Function: "getRepo"}})
+	assert.Equal(t, []string{"my-github", "github"}, systems)
+
+	names := checkCircuitBreakers(systems, "web", "request")
+	assert.Equal(t, []string{"github", "web"}, names)
+
+	msg := &dipper.Message{
+		Labels: map[string]string{
+			CircuitBreakerLabel: "github,web",
+			"status":            "error",
+		},
+	}
+	recordCircuitBreakers(msg)
+	assert.NotContains(t, msg.Labels, CircuitBreakerLabel, "circuit breaker label should be removed from return")
+	assert.Equal(t, CircuitOpen, circuitBreakers["github"].state)
+	assert.Equal(t, CircuitClosed, circuitBreakers["web"].state)
+
+	assert.PanicsWithError(t, ErrCircuitOpen.Error(), func() { checkCircuitBreakers(systems, "web", "request") })
+	assert.Equal(t, []string{"web"}, checkCircuitBreakers(nil, "web", "request"), "other systems using the driver should not be affected")
+
+	github := circuitBreakers["github"]
+	loadCircuitBreakers(operator.config)
+	assert.Same(t, github, circuitBreakers["github"], "breaker state should be kept if config is not changed")
+}
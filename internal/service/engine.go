@@ -53,6 +53,11 @@ func (h *WorkflowHelper) GetDaemonID() string {
 	return h.engine.daemonID
 }
 
+// Call method makes a RPC call through the engine service.
+func (h *WorkflowHelper) Call(feature string, method string, params interface{}) ([]byte, error) {
+	return h.engine.Call(feature, method, params)
+}
+
 // StartEngine Starts the engine service.
 func StartEngine(cfg *config.Config) {
 	engine = NewService(cfg, "engine")
@@ -112,6 +117,11 @@ func continueSession(d *driver.Runtime, msg *dipper.Message) {
 	if !ok {
 		dipper.Logger.Panic("[enigne] command return without session id")
 	}
+	if _, ok := msg.Labels[dipper.LabelProgress]; ok {
+		sessionStore.RecordProgress(sessionID, msg)
+
+		return
+	}
 	dipper.Logger.Infof("[engine] command return")
 	go sessionStore.ContinueSession(sessionID, msg, nil)
 }
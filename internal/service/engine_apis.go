@@ -7,6 +7,7 @@
 package service
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/honeydipper/honeydipper/internal/api"
@@ -16,6 +17,7 @@ import (
 func setupEngineAPIs() {
 	engine.APIs["eventWait"] = handleEventWait
 	engine.APIs["eventList"] = handleEventList
+	engine.APIs["eventProgress"] = handleEventProgress
 }
 
 func handleEventWait(resp *api.Response) {
@@ -71,3 +73,70 @@ func handleEventList(resp *api.Response) {
 		"sessions": ret,
 	})
 }
+
+// MaxProgressWait is the longest time the progress API waits for new updates, it should be shorter than the api
+// service timeout.
+var MaxProgressWait = 8 * time.Second
+
+// handleEventProgress returns the progress updates recorded for the event since the given position, the callers can
+// poll with the returned next position to follow the progress.  With the wait parameter, the call waits until new
+// updates arrive, the sessions complete or the wait time is up.
+func handleEventProgress(resp *api.Response) {
+	resp.Request = dipper.DeserializePayload(resp.Request)
+	eventID := dipper.MustGetMapDataStr(resp.Request.Payload, "eventID")
+	sessions := sessionStore.ByEventID(eventID)
+	if len(sessions) == 0 {
+		return
+	}
+
+	since := 0
+	if sinceStr, ok := dipper.GetMapDataStr(resp.Request.Payload, "since"); ok {
+		since, _ = strconv.Atoi(sinceStr)
+	}
+	if waitStr, ok := dipper.GetMapDataStr(resp.Request.Payload, "wait"); ok {
+		if wait, err := time.ParseDuration(waitStr); err == nil && wait > 0 {
+			resp.Ack()
+			watches := make([]<-chan struct{}, 0, len(sessions)*2) //nolint:gomnd
+			for _, session := range sessions {
+				watches = append(watches, session.WatchProgress(since), session.Watch())
+			}
+			waitProgress(watches, wait)
+		}
+	}
+	ret := make([]interface{}, len(sessions))
+	for i, session := range sessions {
+		progress, next := session.GetProgress(since)
+		ret[i] = map[string]interface{}{
+			"name":     session.GetName(),
+			"progress": progress,
+			"next":     next,
+		}
+	}
+	resp.Return(map[string]interface{}{
+		"sessions": ret,
+	})
+}
+
+// waitProgress blocks until any of the channels is closed, or the wait time is up.
+func waitProgress(watches []<-chan struct{}, wait time.Duration) {
+	if wait > MaxProgressWait {
+		wait = MaxProgressWait
+	}
+	updated := make(chan struct{}, len(watches))
+	done := make(chan struct{})
+	defer close(done)
+	for _, c := range watches {
+		go func(c <-chan struct{}) {
+			select {
+			case <-c:
+				updated <- struct{}{}
+			case <-done:
+			}
+		}(c)
+	}
+
+	select {
+	case <-updated:
+	case <-time.After(wait):
+	}
+}
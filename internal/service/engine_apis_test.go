@@ -0,0 +1,42 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitProgress(t *testing.T) {
+	updated := make(chan struct{})
+	completed := make(chan struct{})
+	watches := []<-chan struct{}{updated, completed}
+
+	start := time.Now()
+	waitProgress(watches, 50*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "should wait until the time is up")
+
+	orig := MaxProgressWait
+	MaxProgressWait = 50 * time.Millisecond
+	start = time.Now()
+	waitProgress(watches, time.Hour)
+	assert.Less(t, time.Since(start), time.Second, "should not wait longer than the max")
+	MaxProgressWait = orig
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(completed)
+	}()
+	start = time.Now()
+	waitProgress(watches, 10*time.Second)
+	assert.Less(t, time.Since(start), time.Second, "should return when any of the channels is closed")
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package service
+
+import (
+	"github.com/honeydipper/honeydipper/internal/driver"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// handleHandshake checks the capabilities announced by the driver.  An incompatible driver is marked as failed, so
+// its following messages, including the state:alive, are not read, and the loading fails as if it timed out.  The
+// rejection is terminal, the driver is not restarted until it is reloaded with a compatible version.
+func (s *Service) handleHandshake(runtime *driver.Runtime, msg *dipper.Message) {
+	driverName := runtime.Handler.Meta().Name
+	h, err := dipper.ParseHandshake(msg)
+	if err == nil {
+		err = h.Check(s.name, runtime.Feature)
+	}
+
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	health := s.getDriverHealth(runtime.Feature, runtime)
+	if err != nil {
+		dipper.Logger.Errorf("[%s] rejecting driver %s for feature %s: %v", s.name, driverName, runtime.Feature, err)
+		runtime.State = driver.DriverFailed
+		health.Healthy = false
+		health.LastError = err.Error()
+		health.Rejected = err.Error()
+
+		return
+	}
+
+	dipper.Logger.Infof("[%s] driver %s speaks protocol version %d", s.name, driverName, h.Protocol)
+	runtime.Handshake = h
+	health.Handshake = h
+	health.Rejected = ""
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package service
+
+import (
+	"testing"
+
+	"github.com/honeydipper/honeydipper/internal/driver"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleHandshake(t *testing.T) {
+	newRuntime := func(feature string) *driver.Runtime {
+		return &driver.Runtime{
+			Feature: feature,
+			State:   driver.DriverLoading,
+			Handler: &driver.NullDriverHandler{MetaFunc: func() *driver.Meta { return &driver.Meta{Name: "test"} }},
+		}
+	}
+	svc := &Service{name: "operator"}
+
+	accepted := newRuntime("eventbus")
+	svc.handleHandshake(accepted, &dipper.Message{Payload: map[string]interface{}{
+		"protocol": dipper.ProtocolVersion,
+		"features": []interface{}{"eventbus"},
+		"commands": []interface{}{"send"},
+	}})
+	assert.Equal(t, driver.DriverLoading, accepted.State)
+	assert.Equal(t, []string{"send"}, accepted.Handshake.Commands)
+	assert.Same(t, accepted.Handshake, svc.driverHealth["eventbus"].Handshake)
+
+	rejected := newRuntime("emitter")
+	svc.handleHandshake(rejected, &dipper.Message{Payload: map[string]interface{}{
+		"protocol": dipper.ProtocolVersion + 1,
+	}})
+	assert.Equal(t, driver.DriverFailed, rejected.State, "incompatible driver should be marked as failed")
+	assert.Nil(t, rejected.Handshake)
+	assert.Contains(t, svc.driverHealth["emitter"].LastError, "driver speaks protocol version 2")
+	assert.Equal(t, svc.driverHealth["emitter"].LastError, svc.driverHealth["emitter"].Rejected, "rejection should be recorded")
+
+	reloaded := newRuntime("emitter")
+	svc.handleHandshake(reloaded, &dipper.Message{Payload: map[string]interface{}{
+		"protocol": dipper.ProtocolVersion,
+	}})
+	assert.Empty(t, svc.driverHealth["emitter"].Rejected, "rejection should be cleared by a compatible driver")
+}
+
+func TestLoadFailedDriverRuntimeRejected(t *testing.T) {
+	closed := false
+	rejected := &driver.Runtime{
+		Feature: "emitter",
+		Service: "testsvc",
+		State:   driver.DriverLoading,
+		Handler: &driver.NullDriverHandler{
+			MetaFunc:  func() *driver.Meta { return &driver.Meta{Name: "test"} },
+			CloseFunc: func() { closed = true },
+		},
+	}
+	svc := &Service{name: "testsvc", driverRuntimes: map[string]*driver.Runtime{"emitter": rejected}}
+	Services = map[string]*Service{"testsvc": svc}
+	defer func() { Services = map[string]*Service{} }()
+
+	svc.handleHandshake(rejected, &dipper.Message{Payload: map[string]interface{}{
+		"protocol": dipper.ProtocolVersion + 1,
+	}})
+	assert.NotPanics(t, func() { loadFailedDriverRuntime(rejected, "timeout waiting for driver to be alive") })
+	assert.True(t, closed, "rejected driver should be closed")
+	assert.Equal(t, 0, svc.driverHealth["emitter"].Restarts, "rejected driver should not be restarted")
+	assert.Contains(t, svc.driverHealth["emitter"].LastError, "protocol version", "rejection should be kept as the last error")
+}
+
+func TestHandleRPCCallUnsupported(t *testing.T) {
+	returned := []*dipper.Message{}
+	called := []*dipper.Message{}
+	caller := &driver.Runtime{
+		Feature: "driver:caller",
+		Service: "testsvc",
+		Handler: &driver.NullDriverHandler{
+			MetaFunc:        func() *driver.Meta { return &driver.Meta{Name: "caller"} },
+			SendMessageFunc: func(m *dipper.Message) { returned = append(returned, m) },
+		},
+	}
+	target := &driver.Runtime{
+		Feature:   "driver:target",
+		Handshake: &dipper.Handshake{Protocol: dipper.ProtocolVersion, RPCs: []string{"decrypt"}},
+		Handler: &driver.NullDriverHandler{
+			MetaFunc:        func() *driver.Meta { return &driver.Meta{Name: "target"} },
+			SendMessageFunc: func(m *dipper.Message) { called = append(called, m) },
+		},
+	}
+	Services = map[string]*Service{"testsvc": {name: "testsvc", driverRuntimes: map[string]*driver.Runtime{"driver:target": target}}}
+	defer func() { Services = map[string]*Service{} }()
+
+	handleRPCCall(caller, &dipper.Message{Channel: "rpc", Subject: "call", Labels: map[string]string{"feature": "driver:target", "method": "decrypt", "rpcID": "1"}})
+	assert.Len(t, called, 1)
+	assert.Empty(t, returned)
+
+	handleRPCCall(caller, &dipper.Message{Channel: "rpc", Subject: "call", Labels: map[string]string{"feature": "driver:target", "method": "encrypt", "rpcID": "2"}})
+	assert.Len(t, called, 1, "unsupported rpc method should not be sent to the driver")
+	assert.Len(t, returned, 1)
+	assert.Equal(t, "2", returned[0].Labels["rpcID"])
+	assert.Equal(t, "driver target does not support rpc method encrypt", returned[0].Labels["error"])
+}
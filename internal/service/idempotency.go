@@ -0,0 +1,164 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/internal/daemon"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// IdempotencyLabel is the label used for tracking the idempotency key of a function call.
+	IdempotencyLabel = "idempotency_key"
+	// IdempotencyPrefix is the prefix for the idempotency keys in the result store.
+	IdempotencyPrefix = "idempotency:"
+	// DefaultIdempotencyTTL is the default time to keep the result of an idempotent function call.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyClaimTTL is the default time to wait for the first call to return before allowing duplicates.
+	DefaultIdempotencyClaimTTL = time.Hour
+	// IdempotencyPollInterval is the interval for duplicate calls to check if the first call has returned.
+	IdempotencyPollInterval = time.Second
+
+	idempotencyInProgress = "in_progress"
+	idempotencyDone       = "done"
+)
+
+var (
+	idempotencyStore     ResultStore
+	idempotencyStoreName string
+	idempotencyTTL       = DefaultIdempotencyTTL
+	idempotencyClaimTTL  = DefaultIdempotencyClaimTTL
+)
+
+// loadIdempotencyConfig loads the store and TTLs from driver.daemon.services.operator.idempotency.
+func loadIdempotencyConfig(cfg *config.Config) {
+	storeName, _ := dipper.GetMapDataStr(cfg.DataSet.Drivers, "daemon.services.operator.idempotency.store")
+	if idempotencyStore == nil || storeName != idempotencyStoreName {
+		idempotencyStore = NewResultStore(storeName, operator)
+		idempotencyStoreName = storeName
+	}
+
+	idempotencyTTL = DefaultIdempotencyTTL
+	if ttl, ok := dipper.GetMapDataStr(cfg.DataSet.Drivers, "daemon.services.operator.idempotency.ttl"); ok {
+		idempotencyTTL = dipper.Must(time.ParseDuration(ttl)).(time.Duration)
+	}
+	idempotencyClaimTTL = DefaultIdempotencyClaimTTL
+	if ttl, ok := dipper.GetMapDataStr(cfg.DataSet.Drivers, "daemon.services.operator.idempotency.claim_ttl"); ok {
+		idempotencyClaimTTL = dipper.Must(time.ParseDuration(ttl)).(time.Duration)
+	}
+}
+
+// functionIdempotencyKey returns the idempotency key template of the function, the outermost definition wins.
+func functionIdempotencyKey(f *config.Function) string {
+	for _, fn := range functionChain(f) {
+		if fn.IdempotencyKey != "" {
+			return fn.IdempotencyKey
+		}
+	}
+
+	return ""
+}
+
+// claimIdempotencyKey marks the key as in progress, returns true if the call should be dispatched. Otherwise, the
+// stored result is returned as a reply, or the call will be answered once the first call returns.
+func claimIdempotencyKey(key string, msg *dipper.Message, payload interface{}) (bool, *dipper.Message) {
+	claim := dipper.Must(json.Marshal(storedResult{State: idempotencyInProgress})).([]byte)
+	if idempotencyStore.SaveNX(key, claim, idempotencyClaimTTL) {
+		return true, nil
+	}
+
+	if stored, ok := idempotencyStore.Load(key); ok {
+		if record := decodeStoredResult(stored); record.State == idempotencyDone {
+			dipper.Logger.Infof("[operator] returning stored result for idempotency key [%s]", key)
+
+			return false, storedReply(msg, record)
+		}
+	}
+
+	labels := map[string]string{}
+	for k, v := range msg.Labels {
+		labels[k] = v
+	}
+	dipper.Logger.Infof("[operator] waiting for in-progress call with idempotency key [%s]", key)
+	go waitIdempotentCall(key, &dipper.Message{
+		Channel: msg.Channel,
+		Subject: msg.Subject,
+		Labels:  labels,
+		Payload: payload,
+	})
+
+	return false, nil
+}
+
+// waitIdempotentCall waits for the first call with the same key to return, and dispatches the call if the first
+// call fails.
+func waitIdempotentCall(key string, cmd *dipper.Message) {
+	defer dipper.SafeExitOnError("[operator] stop waiting for call with idempotency key [%s]", key)
+
+	deadline := time.Now().Add(idempotencyClaimTTL + IdempotencyPollInterval)
+	for !daemon.ShuttingDown && time.Now().Before(deadline) {
+		time.Sleep(IdempotencyPollInterval)
+		stored, ok := idempotencyStore.Load(key)
+		if !ok {
+			// the first call failed, dispatching this call instead
+			for _, routed := range handleEventbusCommand(cmd) {
+				routed.driverRuntime.SendMessage(routed.message)
+			}
+
+			return
+		}
+		if record := decodeStoredResult(stored); record.State == idempotencyDone {
+			if reply := storedReply(cmd, record); reply != nil {
+				operator.getDriverRuntime(dipper.ChannelEventbus).SendMessage(reply)
+			}
+
+			return
+		}
+	}
+}
+
+// recordIdempotentResult stores the result of the call, or removes the claim so the call can be retried on error.
+func recordIdempotentResult(msg *dipper.Message) {
+	key, ok := msg.Labels[IdempotencyLabel]
+	if !ok {
+		return
+	}
+	delete(msg.Labels, IdempotencyLabel)
+	defer dipper.SafeExitOnError("[operator] failed to record result for idempotency key [%s]", key)
+
+	if msg.Labels["status"] == "error" {
+		idempotencyStore.Delete(key)
+
+		return
+	}
+
+	msg = dipper.DeserializePayload(msg)
+	record := storedResult{
+		State:   idempotencyDone,
+		Labels:  map[string]string{"status": msg.Labels["status"]},
+		Payload: msg.Payload,
+	}
+	if reason, ok := msg.Labels["reason"]; ok {
+		record.Labels["reason"] = reason
+	}
+	idempotencyStore.Save(key, dipper.Must(json.Marshal(record)).([]byte), idempotencyTTL)
+}
+
+// releaseIdempotencyKey removes the claim when the call fails before being dispatched.
+func releaseIdempotencyKey(msg *dipper.Message) {
+	key, ok := msg.Labels[IdempotencyLabel]
+	if !ok {
+		return
+	}
+	delete(msg.Labels, IdempotencyLabel)
+	defer dipper.SafeExitOnError("[operator] failed to release idempotency key [%s]", key)
+	idempotencyStore.Delete(key)
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryResultStore(t *testing.T) {
+	store := NewResultStore(ResultStoreMemory, nil)
+
+	assert.True(t, store.SaveNX("foo", []byte("bar"), time.Minute), "SaveNX should save a new key")
+	assert.False(t, store.SaveNX("foo", []byte("baz"), time.Minute), "SaveNX should not overwrite an existing key")
+	value, ok := store.Load("foo")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("bar"), value)
+
+	store.Save("foo", []byte("baz"), -time.Second)
+	_, ok = store.Load("foo")
+	assert.False(t, ok, "expired key should not be loaded")
+	assert.True(t, store.SaveNX("foo", []byte("bar"), time.Minute), "SaveNX should save over an expired key")
+
+	store.Delete("foo")
+	_, ok = store.Load("foo")
+	assert.False(t, ok, "deleted key should not be loaded")
+}
+
+func TestFunctionIdempotencyKey(t *testing.T) {
+	operator = &Service{
+		name: "operator",
+		config: &config.Config{
+			DataSet: &config.DataSet{
+				Systems: map[string]config.System{
+					"kubernetes": {
+						Functions: map[string]config.Function{
+							"createJob": {Driver: "kubernetes", RawAction: "createJob", IdempotencyKey: "{{ .params.job.metadata.name }}"},
+						},
+					},
+					"my-cluster": {
+						Functions: map[string]config.Function{
+							"createJob":  {Target: config.Action{System: "kubernetes", Function: "createJob"}},
+							"runBackup":  {Target: config.Action{System: "kubernetes", Function: "createJob"}, IdempotencyKey: "$ctx.backup_id"},
+							"deleteJobs": {Driver: "kubernetes", RawAction: "deleteJob"},
+						},
+					},
+				},
+			},
+		},
+	}
+	defer func() { operator = nil }()
+
+	assert.Equal(t, "{{ .params.job.metadata.name }}", functionIdempotencyKey(&config.Function{Target: config.Action{System: "my-cluster", Function: "createJob"}}))
+	assert.Equal(t, "$ctx.backup_id", functionIdempotencyKey(&config.Function{Target: config.Action{System: "my-cluster", Function: "runBackup"}}))
+	assert.Equal(t, "", functionIdempotencyKey(&config.Function{Target: config.Action{System: "my-cluster", Function: "deleteJobs"}}))
+}
+
+func TestIdempotentCall(t *testing.T) {
+	idempotencyStore = NewResultStore(ResultStoreMemory, nil)
+	defer func() { idempotencyStore = nil }()
+
+	cmd := &dipper.Message{
+		Channel: dipper.ChannelEventbus,
+		Subject: dipper.EventbusCommand,
+		Labels:  map[string]string{"sessionID": "1", "method": "createJob", "timeout": "10"},
+	}
+	dispatch, reply := claimIdempotencyKey("idempotency:kubernetes.createJob:job1", cmd, nil)
+	assert.True(t, dispatch, "first call should be dispatched")
+	assert.Nil(t, reply)
+
+	ret := &dipper.Message{
+		Labels: map[string]string{
+			"sessionID":      "1",
+			"status":         "success",
+			IdempotencyLabel: "idempotency:kubernetes.createJob:job1",
+		},
+		Payload: map[string]interface{}{"metadata": map[string]interface{}{"name": "job1"}},
+	}
+	recordIdempotentResult(ret)
+	assert.NotContains(t, ret.Labels, IdempotencyLabel, "idempotency key label should be removed from return")
+
+	cmd.Labels["sessionID"] = "2"
+	dispatch, reply = claimIdempotencyKey("idempotency:kubernetes.createJob:job1", cmd, nil)
+	assert.False(t, dispatch, "duplicate call should not be dispatched")
+	assert.Equal(t, &dipper.Message{
+		Channel: dipper.ChannelEventbus,
+		Subject: dipper.EventbusReturn,
+		Labels:  map[string]string{"sessionID": "2", "method": "createJob", "status": "success"},
+		Payload: map[string]interface{}{"metadata": map[string]interface{}{"name": "job1"}},
+	}, reply, "duplicate call should receive the stored result")
+
+	dispatch, _ = claimIdempotencyKey("idempotency:kubernetes.createJob:job2", cmd, nil)
+	assert.True(t, dispatch)
+	recordIdempotentResult(&dipper.Message{
+		Labels: map[string]string{
+			"status":         "error",
+			"reason":         "timeout",
+			IdempotencyLabel: "idempotency:kubernetes.createJob:job2",
+		},
+	})
+	dispatch, _ = claimIdempotencyKey("idempotency:kubernetes.createJob:job2", cmd, nil)
+	assert.True(t, dispatch, "call should be dispatched again after the first call errors")
+
+	cmd.Labels[IdempotencyLabel] = "idempotency:kubernetes.createJob:job2"
+	releaseIdempotencyKey(cmd)
+	assert.NotContains(t, cmd.Labels, IdempotencyLabel)
+	dispatch, _ = claimIdempotencyKey("idempotency:kubernetes.createJob:job2", cmd, nil)
+	assert.True(t, dispatch, "call should be dispatched again after the claim is released")
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/honeydipper/honeydipper/internal/config"
 	"github.com/honeydipper/honeydipper/pkg/dipper"
@@ -28,17 +29,33 @@ var (
 func StartOperator(cfg *config.Config) {
 	operator = NewService(cfg, "operator")
 	operator.Route = operatorRoute
+	operator.ServiceReload = reloadOperator
+	operator.EmitMetrics = circuitBreakerMetrics
+	setupOperatorAPIs()
 	operator.start()
 }
 
+// reloadOperator loads the operator specific configurations.
+func reloadOperator(cfg *config.Config) {
+	loadCircuitBreakers(cfg)
+	loadIdempotencyConfig(cfg)
+	loadCacheConfig(cfg)
+}
+
 // handleEventbusCommand.
 func handleEventbusCommand(msg *dipper.Message) []RoutedMessage {
 	defer func() {
 		if r := recover(); r != nil {
+			releaseIdempotencyKey(msg)
+			delete(msg.Labels, CacheLabel)
+			delete(msg.Labels, CacheTTLLabel)
 			if sessionID, ok := msg.Labels["sessionID"]; ok && sessionID != "" {
 				newLabels := msg.Labels
 				newLabels["status"] = "error"
 				newLabels["reason"] = fmt.Sprintf("%+v", r)
+				if err, ok := r.(error); ok && errors.Is(err, ErrCircuitOpen) {
+					newLabels["reason"] = ErrCircuitOpen.Error()
+				}
 				eventbus := operator.getDriverRuntime(dipper.ChannelEventbus)
 				eventbus.SendMessage(&dipper.Message{
 					Channel: dipper.ChannelEventbus,
@@ -51,6 +68,7 @@ func handleEventbusCommand(msg *dipper.Message) []RoutedMessage {
 	}()
 
 	msg = dipper.DeserializePayload(msg)
+	payload := msg.Payload
 	dipper.Logger.Debugf("[operator] function call payload %+v", msg.Payload)
 	function := config.Function{}
 	data, _ := dipper.GetMapData(msg.Payload, "data")
@@ -81,6 +99,9 @@ func handleEventbusCommand(msg *dipper.Message) []RoutedMessage {
 	if worker == nil {
 		panic(fmt.Errorf("%w: not defined: %s", ErrOperatorError, driver))
 	}
+	if !worker.Handshake.HasCommand(rawaction) {
+		panic(fmt.Errorf("%w: driver %s does not support command %s", ErrOperatorError, driver, rawaction))
+	}
 	finalParams := params
 	if params != nil {
 		// interpolate twice for giving an chance for using sysData in ctx
@@ -137,6 +158,45 @@ func handleEventbusCommand(msg *dipper.Message) []RoutedMessage {
 		msg.Labels["timeout"] = timeout
 	}
 
+	keyEnv := map[string]interface{}{
+		"sysData": sysData,
+		"data":    data,
+		"event":   event,
+		"labels":  msg.Labels,
+		"ctx":     ctx,
+		"params":  finalParams,
+	}
+
+	delete(msg.Labels, CacheLabel)
+	delete(msg.Labels, CacheTTLLabel)
+	if cache := functionCache(&function); cache != nil {
+		ttl := DefaultCacheTTL
+		if cache.TTL != "" {
+			ttl = dipper.Must(time.ParseDuration(cache.TTL)).(time.Duration)
+		}
+		key := cacheKey(functionSystems(&function), driver, rawaction, dipper.InterpolateStr(cache.Key, keyEnv), finalParams)
+		if reply, ok := loadCachedReturn(key, msg, driver, rawaction); ok {
+			return returnStored(reply)
+		}
+		msg.Labels[CacheLabel] = key
+		msg.Labels[CacheTTLLabel] = ttl.String()
+	}
+
+	delete(msg.Labels, IdempotencyLabel)
+	if keyTemplate := functionIdempotencyKey(&function); keyTemplate != "" {
+		key := IdempotencyPrefix + driver + "." + rawaction + ":" + dipper.InterpolateStr(keyTemplate, keyEnv)
+		if dispatch, reply := claimIdempotencyKey(key, msg, payload); !dispatch {
+			return returnStored(reply)
+		}
+		msg.Labels[IdempotencyLabel] = key
+	}
+
+	// checking circuit breakers last, so that the probes taken are returned through the driver
+	delete(msg.Labels, CircuitBreakerLabel)
+	if breakers := checkCircuitBreakers(functionSystems(&function), driver, rawaction); len(breakers) > 0 {
+		msg.Labels[CircuitBreakerLabel] = strings.Join(breakers, ",")
+	}
+
 	return []RoutedMessage{
 		{
 			driverRuntime: worker,
@@ -145,6 +205,20 @@ func handleEventbusCommand(msg *dipper.Message) []RoutedMessage {
 	}
 }
 
+// returnStored routes the stored return, if any, back to the caller without calling the driver.
+func returnStored(reply *dipper.Message) []RoutedMessage {
+	if reply == nil {
+		return nil
+	}
+
+	return []RoutedMessage{
+		{
+			driverRuntime: operator.getDriverRuntime(dipper.ChannelEventbus),
+			message:       reply,
+		},
+	}
+}
+
 func operatorRoute(msg *dipper.Message) (ret []RoutedMessage) {
 	dipper.Logger.Infof("[operator] routing message %s.%s", msg.Channel, msg.Subject)
 	defer dipper.SafeExitOnError("[operator] continue on processing messages")
@@ -152,6 +226,11 @@ func operatorRoute(msg *dipper.Message) (ret []RoutedMessage) {
 	case msg.Channel == dipper.ChannelEventbus && msg.Subject == dipper.EventbusCommand:
 		ret = handleEventbusCommand(msg)
 	case msg.Channel == dipper.ChannelEventbus && msg.Subject == dipper.EventbusReturn:
+		if _, ok := msg.Labels[dipper.LabelProgress]; !ok {
+			recordCircuitBreakers(msg)
+			recordIdempotentResult(msg)
+			recordCachedReturn(msg)
+		}
 		ret = []RoutedMessage{
 			{
 				driverRuntime: operator.getDriverRuntime(dipper.ChannelEventbus),
@@ -163,6 +242,37 @@ func operatorRoute(msg *dipper.Message) (ret []RoutedMessage) {
 	return ret
 }
 
+// functionChain returns the function and the functions it is collapsed through, from the outermost to the innermost.
+func functionChain(f *config.Function) []*config.Function {
+	chain := []*config.Function{f}
+	for len(f.Driver) == 0 && len(f.Target.System) > 0 {
+		childSystem, ok := operator.config.DataSet.Systems[f.Target.System]
+		if !ok {
+			break
+		}
+		childFunction, ok := childSystem.Functions[f.Target.Function]
+		if !ok {
+			break
+		}
+		f = &childFunction
+		chain = append(chain, f)
+	}
+
+	return chain
+}
+
+// functionSystems returns the names of the systems the function is collapsed through.
+func functionSystems(f *config.Function) []string {
+	systems := []string{}
+	for _, fn := range functionChain(f) {
+		if len(fn.Driver) == 0 && len(fn.Target.System) > 0 {
+			systems = append(systems, fn.Target.System)
+		}
+	}
+
+	return systems
+}
+
 func collapseFunction(s *config.System, f *config.Function) (string, string, map[string]interface{}, map[string]interface{}) {
 	var sysData map[string]interface{}
 	var params map[string]interface{}
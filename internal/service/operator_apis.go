@@ -0,0 +1,48 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/api"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+func setupOperatorAPIs() {
+	operator.APIs["circuitBreakerList"] = handleCircuitBreakerList
+	operator.APIs["cacheInvalidate"] = handleCacheInvalidate
+}
+
+func handleCircuitBreakerList(resp *api.Response) {
+	circuitBreakerLock.Lock()
+	now := time.Now()
+	ret := make([]interface{}, 0, len(circuitBreakers))
+	names := make([]string, 0, len(circuitBreakers))
+	for name := range circuitBreakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ret = append(ret, circuitBreakers[name].Status(now))
+	}
+	circuitBreakerLock.Unlock()
+
+	resp.Return(map[string]interface{}{
+		"circuit_breakers": ret,
+	})
+}
+
+func handleCacheInvalidate(resp *api.Response) {
+	resp.Request = dipper.DeserializePayload(resp.Request)
+	system := dipper.MustGetMapDataStr(resp.Request.Payload, "system")
+	invalidateSystemCache(system)
+	resp.Return(map[string]interface{}{
+		"system": system,
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package service
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// ResultStoreMemory is the name of the result store that keeps the data in the memory of the daemon.
+	ResultStoreMemory = "memory"
+	// ResultStoreRedis is the name of the result store that keeps the data in redis through the cache feature.
+	ResultStoreRedis = "redis"
+
+	// ResultStoreFeature is the feature providing the save/load/delete RPCs for storing results externally.
+	ResultStoreFeature = "cache"
+
+	// ResultStorePurgeInterval is the minimum interval between purging expired items from the memory store.
+	ResultStorePurgeInterval = time.Minute
+)
+
+// ResultStore stores function call results for a period of time.
+type ResultStore interface {
+	// Load fetches the value stored with the key.
+	Load(key string) ([]byte, bool)
+	// Save stores the value with the key, overwriting existing value.
+	Save(key string, value []byte, ttl time.Duration)
+	// SaveNX stores the value only if the key does not exist, returns true if saved.
+	SaveNX(key string, value []byte, ttl time.Duration) bool
+	// Delete removes the key from the store.
+	Delete(key string)
+}
+
+// storedResult is a function call result kept in a result store.
+type storedResult struct {
+	State   string            `json:"state,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Payload interface{}       `json:"payload,omitempty"`
+}
+
+// NewResultStore creates a result store by its name.
+func NewResultStore(name string, s *Service) ResultStore {
+	switch name {
+	case "", ResultStoreMemory:
+		return &memoryResultStore{items: map[string]*memoryResultItem{}}
+	case ResultStoreRedis:
+		return &featureResultStore{service: s, feature: ResultStoreFeature}
+	}
+	dipper.Logger.Panicf("[%s] unknown result store %s", s.name, name)
+
+	return nil
+}
+
+type memoryResultItem struct {
+	value   []byte
+	expires time.Time
+}
+
+// memoryResultStore keeps the results in the memory of the current daemon.
+type memoryResultStore struct {
+	lock     sync.Mutex
+	items    map[string]*memoryResultItem
+	purgedAt time.Time
+}
+
+func (m *memoryResultStore) Load(key string) ([]byte, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	item, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(item.expires) {
+		delete(m.items, key)
+
+		return nil, false
+	}
+
+	return item.value, true
+}
+
+func (m *memoryResultStore) Save(key string, value []byte, ttl time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.purge()
+	m.items[key] = &memoryResultItem{value: value, expires: time.Now().Add(ttl)}
+}
+
+func (m *memoryResultStore) SaveNX(key string, value []byte, ttl time.Duration) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.purge()
+	if item, ok := m.items[key]; ok && time.Now().Before(item.expires) {
+		return false
+	}
+	m.items[key] = &memoryResultItem{value: value, expires: time.Now().Add(ttl)}
+
+	return true
+}
+
+func (m *memoryResultStore) Delete(key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.items, key)
+}
+
+// purge removes expired items, the caller should hold the lock.
+func (m *memoryResultStore) purge() {
+	now := time.Now()
+	if now.Sub(m.purgedAt) < ResultStorePurgeInterval {
+		return
+	}
+	m.purgedAt = now
+	for key, item := range m.items {
+		if now.After(item.expires) {
+			delete(m.items, key)
+		}
+	}
+}
+
+// featureResultStore keeps the results in an external storage through RPCs to a feature, e.g. redis-cache.
+type featureResultStore struct {
+	service *Service
+	feature string
+}
+
+func (f *featureResultStore) Load(key string) ([]byte, bool) {
+	ret := dipper.DeserializeContent(dipper.Must(f.service.Call(f.feature, "load", map[string]interface{}{
+		"key": key,
+	})).([]byte))
+	value, ok := dipper.GetMapDataStr(ret, "value")
+	if !ok {
+		return nil, false
+	}
+
+	return []byte(value), true
+}
+
+func (f *featureResultStore) Save(key string, value []byte, ttl time.Duration) {
+	dipper.Must(f.service.Call(f.feature, "save", map[string]interface{}{
+		"key":   key,
+		"value": string(value),
+		"ttl":   ttl.String(),
+	}))
+}
+
+func (f *featureResultStore) SaveNX(key string, value []byte, ttl time.Duration) bool {
+	ret := dipper.DeserializeContent(dipper.Must(f.service.Call(f.feature, "save", map[string]interface{}{
+		"key":   key,
+		"value": string(value),
+		"ttl":   ttl.String(),
+		"nx":    true,
+	})).([]byte))
+	saved, _ := dipper.GetMapData(ret, "saved")
+
+	return dipper.IsTruthy(saved)
+}
+
+func (f *featureResultStore) Delete(key string) {
+	dipper.Must(f.service.Call(f.feature, "delete", map[string]interface{}{
+		"key": key,
+	}))
+}
+
+func decodeStoredResult(stored []byte) *storedResult {
+	result := &storedResult{}
+	dipper.Must(json.Unmarshal(stored, result))
+
+	return result
+}
+
+// storedReply builds a return message for the call using the stored result.
+func storedReply(cmd *dipper.Message, result *storedResult) *dipper.Message {
+	if _, ok := cmd.Labels["sessionID"]; !ok {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for k, v := range cmd.Labels {
+		labels[k] = v
+	}
+	delete(labels, "backoff_ms")
+	delete(labels, "retry")
+	delete(labels, "timeout")
+	delete(labels, IdempotencyLabel)
+	delete(labels, CircuitBreakerLabel)
+	delete(labels, CacheLabel)
+	delete(labels, CacheTTLLabel)
+	for k, v := range result.Labels {
+		labels[k] = v
+	}
+
+	return &dipper.Message{
+		Channel: dipper.ChannelEventbus,
+		Subject: dipper.EventbusReturn,
+		Labels:  labels,
+		Payload: result.Payload,
+	}
+}
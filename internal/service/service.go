@@ -34,11 +34,9 @@ const (
 	// DriverReadyTimeout is the timeout in seconds for the driver to be ready.
 	DriverReadyTimeout time.Duration = 10
 
-	// DriverRetryBackoff is the interval in seconds before retry loading a driver.
-	DriverRetryBackoff time.Duration = 30
-
-	// DriverRetryCount is the number of attempts to load a driver.
-	DriverRetryCount = 3
+	// DriverRetryBackoff is the initial interval in seconds before retry loading a failed driver, it doubles on
+	// every consecutive failure.
+	DriverRetryBackoff time.Duration = 5
 )
 
 // MessageResponder is a function type that respond to messages.
@@ -75,6 +73,8 @@ type Service struct {
 	healthy            bool
 	drainingGroup      *sync.WaitGroup
 	daemonID           string
+	driverHealth       map[string]*DriverHealth
+	healthLock         sync.Mutex
 }
 
 var (
@@ -108,7 +108,9 @@ func NewService(cfg *config.Config, name string) *Service {
 	svc.responders["api:call"] = []MessageResponder{handleAPI}
 
 	svc.ResponseFactory = api.NewResponseFactory()
-	svc.APIs = map[string]func(*api.Response){}
+	svc.APIs = map[string]func(*api.Response){
+		name + "DriverList": svc.handleDriverList,
+	}
 
 	if len(Services) == 0 {
 		masterService = svc
@@ -261,6 +263,7 @@ func (s *Service) start() {
 		}
 		s.healthy = true
 		go s.metricsLoop()
+		go s.supervisorLoop()
 	}()
 }
 
@@ -352,6 +355,7 @@ func (s *Service) loadRequiredFeatures(featureList map[string]bool, boot bool) {
 				"state:alive:"+driverName,
 				func(*dipper.Message) {
 					s.driverRuntimes[feature].State = driver.DriverAlive
+					s.driverAlive(s.driverRuntimes[feature])
 					if feature == FeatureEmitter {
 						// emitter is loaded
 						daemon.Emitters[s.name] = s
@@ -393,6 +397,7 @@ func (s *Service) loadAdditionalFeatures(featureList map[string]bool) {
 						"state:alive:"+driverName,
 						func(*dipper.Message) {
 							s.driverRuntimes[feature].State = driver.DriverAlive
+							s.driverAlive(s.driverRuntimes[feature])
 							if feature == FeatureEmitter {
 								// emitter is loaded
 								daemon.Emitters[s.name] = s
@@ -454,6 +459,7 @@ func (s *Service) serviceLoop() {
 				defer dipper.SafeExitOnError("[%s] service loop continue", s.name)
 				runtime := orderedRuntimes[chosen]
 				msg := value.Interface().(*dipper.Message)
+				s.driverSeen(runtime)
 				if runtime.Feature != FeatureEmitter {
 					if emitter, ok := daemon.Emitters[s.name]; ok {
 						emitter.CounterIncr("honey.honeydipper.local.message", []string{
@@ -466,6 +472,13 @@ func (s *Service) serviceLoop() {
 					}
 				}
 
+				if msg.Channel == dipper.ChannelState && msg.Subject == dipper.StateHandshake {
+					// handled before the following state:alive message
+					s.handleHandshake(runtime, msg)
+
+					return
+				}
+
 				s.driverLock.Lock()
 				defer s.driverLock.Unlock()
 				go s.process(*msg, runtime)
@@ -480,7 +493,11 @@ func (s *Service) serviceLoop() {
 			}
 			if d := orderedRuntimes[chosen]; d.State == driver.DriverAlive {
 				// only reload drivers that used to be in DriveAlive state
-				go loadFailedDriverRuntime(orderedRuntimes[chosen], 0)
+				reason := "driver crashed"
+				if reporter, ok := orderedRuntimes[chosen].Handler.(driver.FailureReporter); ok && reporter.FailureReason() != "" {
+					reason = reporter.FailureReason()
+				}
+				go loadFailedDriverRuntime(orderedRuntimes[chosen], reason)
 			}
 		}
 	}
@@ -625,10 +642,21 @@ func coldReloadDriverRuntime(d *driver.Runtime, m *dipper.Message) {
 	dipper.Must(s.loadFeature(d.Feature))
 }
 
-func loadFailedDriverRuntime(d *driver.Runtime, count int) {
+func loadFailedDriverRuntime(d *driver.Runtime, reason string) {
 	s := Services[d.Service]
 	d.State = driver.DriverFailed
 	driverName := d.Handler.Meta().Name
+	if rejected := s.driverRejected(d); rejected != "" {
+		// restarting the same driver won't make it compatible
+		dipper.Logger.Errorf("[%s] not restarting driver %s rejected in handshake: %s", s.name, driverName, rejected)
+		func() {
+			defer dipper.SafeExitOnError("[%s] rejected driver %s already closed", s.name, driverName)
+			d.Handler.Close()
+		}()
+
+		return
+	}
+	backoff := s.driverFailed(d, reason)
 	if emitter, ok := daemon.Emitters[s.name]; ok {
 		emitter.CounterIncr("honey.honeydipper.driver.recovery_attempt", []string{
 			"service:" + s.name,
@@ -636,40 +664,70 @@ func loadFailedDriverRuntime(d *driver.Runtime, count int) {
 		})
 	}
 
-	dipper.Logger.Warningf("[%s] start loading/reloading driver %s", s.name, driverName)
-	retry := func() {
-		dipper.Logger.Warningf("[%s] failed to load/reload driver %s attempt %d", s.name, driverName, count)
-		if count < DriverRetryCount {
-			time.Sleep(DriverRetryBackoff * time.Second)
-			go loadFailedDriverRuntime(d, count+1)
-		} else {
-			dipper.Logger.Fatalf("[%s] quiting after failed to reload crashed driver %s", s.name, driverName)
-		}
+	if backoff > 0 {
+		dipper.Logger.Warningf("[%s] reloading driver %s in %s after failure: %s", s.name, driverName, backoff, reason)
+		time.Sleep(backoff)
+	}
+	if daemon.ShuttingDown {
+		return
+	}
+	if current := s.getDriverRuntime(d.Feature); current != nil && current != d && current.State != driver.DriverFailed {
+		dipper.Logger.Infof("[%s] driver %s already replaced, skip reloading", s.name, driverName)
+
+		return
 	}
+
+	dipper.Logger.Warningf("[%s] start loading/reloading driver %s", s.name, driverName)
 	_, _, err := s.loadFeature(d.Feature)
 	if err != nil {
-		retry()
-	} else {
-		s.addExpect(
-			"state:alive:"+driverName,
-			func(*dipper.Message) {
-				s.driverRuntimes[d.Feature].State = driver.DriverAlive
-				if d.Feature == FeatureEmitter {
-					// emitter is loaded
-					daemon.Emitters[s.name] = s
-				}
-			},
-			DriverReadyTimeout*time.Second,
-			retry,
-		)
+		go loadFailedDriverRuntime(d, err.Error())
+
+		return
 	}
+
+	s.addExpect(
+		"state:alive:"+driverName,
+		func(*dipper.Message) {
+			s.driverRuntimes[d.Feature].State = driver.DriverAlive
+			s.driverAlive(s.driverRuntimes[d.Feature])
+			if d.Feature == FeatureEmitter {
+				// emitter is loaded
+				daemon.Emitters[s.name] = s
+			}
+		},
+		DriverReadyTimeout*time.Second,
+		func() {
+			failed := d
+			if current := s.getDriverRuntime(d.Feature); current != nil {
+				failed = current
+			}
+			go loadFailedDriverRuntime(failed, "timeout waiting for driver to be alive")
+		},
+	)
 }
 
 func handleRPCCall(from *driver.Runtime, m *dipper.Message) {
 	feature := m.Labels["feature"]
 	m.Labels["caller"] = from.Feature
 	s := Services[from.Service]
-	s.getDriverRuntime(feature).SendMessage(m)
+	target := s.getDriverRuntime(feature)
+	if method := m.Labels["method"]; !target.Handshake.HasRPC(method) {
+		dipper.Logger.Warningf("[%s] driver %s does not support rpc method %s", s.name, target.Handler.Meta().Name, method)
+		if m.Labels["rpcID"] != "skip" {
+			from.SendMessage(&dipper.Message{
+				Channel: dipper.ChannelRPC,
+				Subject: "return",
+				Labels: map[string]string{
+					"rpcID":  m.Labels["rpcID"],
+					"caller": from.Feature,
+					"error":  fmt.Sprintf("driver %s does not support rpc method %s", target.Handler.Meta().Name, method),
+				},
+			})
+		}
+
+		return
+	}
+	target.SendMessage(m)
 }
 
 func handleRPCReturn(from *driver.Runtime, m *dipper.Message) {
@@ -787,6 +845,7 @@ func (s *Service) metricsLoop() {
 					"service:" + s.name,
 					"state:failed",
 				})
+				s.driverHealthMetrics()
 			}
 			if s.EmitMetrics != nil {
 				s.EmitMetrics()
@@ -0,0 +1,280 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/api"
+	"github.com/honeydipper/honeydipper/internal/daemon"
+	"github.com/honeydipper/honeydipper/internal/driver"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// DefaultDriverHeartbeat is the default interval for pinging the drivers.
+	DefaultDriverHeartbeat = 10 * time.Second
+	// DefaultDriverMissedHeartbeats is the default number of heartbeats a driver can miss before being restarted.
+	DefaultDriverMissedHeartbeats = 3
+	// DefaultDriverMaxBackoff is the default max wait time before restarting a driver that keeps failing.
+	DefaultDriverMaxBackoff = 5 * time.Minute
+	// DriverStablePeriod is how long a driver needs to stay alive for the backoff to be reset.
+	DriverStablePeriod = 10 * time.Minute
+)
+
+// DriverHealth keeps track of the liveness and the restarts of the driver for a feature.
+type DriverHealth struct {
+	Feature     string    `json:"feature"`
+	Driver      string    `json:"driver"`
+	Healthy     bool      `json:"healthy"`
+	Restarts    int       `json:"restarts"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastRestart time.Time `json:"last_restart,omitempty"`
+	LastSeen    time.Time `json:"last_seen,omitempty"`
+
+	Handshake *dipper.Handshake `json:"handshake,omitempty"`
+	Rejected  string            `json:"rejected,omitempty"`
+
+	failures   int
+	aliveSince time.Time
+
+	// pinging is set while a ping is being sent to the driver, the heartbeats are counted as missed until it is sent.
+	pinging     bool
+	missedPings int
+}
+
+// getDriverHealth returns the health record for the feature, creating it if not exist.  Caller should hold the healthLock.
+func (s *Service) getDriverHealth(feature string, runtime *driver.Runtime) *DriverHealth {
+	if s.driverHealth == nil {
+		s.driverHealth = map[string]*DriverHealth{}
+	}
+	h, ok := s.driverHealth[feature]
+	if !ok {
+		h = &DriverHealth{Feature: feature}
+		s.driverHealth[feature] = h
+	}
+	if runtime != nil {
+		if meta := runtime.Handler.Meta(); meta != nil {
+			h.Driver = meta.Name
+		}
+	}
+
+	return h
+}
+
+// driverSeen records that a message is received from the driver.
+func (s *Service) driverSeen(runtime *driver.Runtime) {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	s.getDriverHealth(runtime.Feature, runtime).LastSeen = time.Now()
+}
+
+// driverAlive records that the driver is started or restarted successfully.
+func (s *Service) driverAlive(runtime *driver.Runtime) {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	h := s.getDriverHealth(runtime.Feature, runtime)
+	h.Healthy = true
+	h.LastSeen = time.Now()
+	h.aliveSince = h.LastSeen
+	h.missedPings = 0
+}
+
+// driverRejected returns why the driver is rejected in the handshake, or empty string if it is not rejected.
+func (s *Service) driverRejected(runtime *driver.Runtime) string {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+
+	return s.getDriverHealth(runtime.Feature, runtime).Rejected
+}
+
+// driverFailed records the failure of the driver, and returns how long to wait before restarting it.  The wait
+// time doubles on every consecutive failure up to the max backoff, and is reset once the driver stays alive
+// for the DriverStablePeriod.
+func (s *Service) driverFailed(runtime *driver.Runtime, reason string) time.Duration {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	h := s.getDriverHealth(runtime.Feature, runtime)
+	if !h.aliveSince.IsZero() && time.Since(h.aliveSince) > DriverStablePeriod {
+		h.failures = 0
+	}
+	h.aliveSince = time.Time{}
+	h.Healthy = false
+	h.LastError = reason
+	h.LastRestart = time.Now()
+	h.Restarts++
+	h.failures++
+
+	if h.failures == 1 {
+		return 0
+	}
+	maxBackoff := s.supervisorDuration("max_backoff", DefaultDriverMaxBackoff)
+	backoff := DriverRetryBackoff * time.Second
+	for i := 2; i < h.failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff
+}
+
+// supervisorDuration reads a duration from daemon.supervisor config.
+func (s *Service) supervisorDuration(key string, defaultValue time.Duration) time.Duration {
+	if s.config != nil {
+		if v, ok := s.config.GetDriverDataStr("daemon.supervisor." + key); ok {
+			if d, err := time.ParseDuration(v); err == nil {
+				return d
+			}
+			dipper.Logger.Warningf("[%s] invalid daemon.supervisor.%s: %s", s.name, key, v)
+		}
+	}
+
+	return defaultValue
+}
+
+// supervisorLoop pings the alive drivers periodically and restarts the ones that miss too many heartbeats.
+func (s *Service) supervisorLoop() {
+	for !daemon.ShuttingDown {
+		heartbeat := s.supervisorDuration("heartbeat", DefaultDriverHeartbeat)
+		time.Sleep(heartbeat)
+		func() {
+			defer dipper.SafeExitOnError("[%s] supervisor loop continue", s.name)
+			for _, runtime := range s.checkHeartbeats(heartbeat) {
+				s.restartHangingDriver(runtime)
+			}
+		}()
+	}
+}
+
+// checkHeartbeats pings the alive drivers, and returns the ones that missed too many heartbeats.
+func (s *Service) checkHeartbeats(heartbeat time.Duration) []*driver.Runtime {
+	missed := DefaultDriverMissedHeartbeats
+	if s.config != nil {
+		if v, ok := s.config.GetDriverData("daemon.supervisor.missed_heartbeats"); ok {
+			if n, err := strconv.Atoi(fmt.Sprint(v)); err == nil && n > 0 {
+				missed = n
+			}
+		}
+	}
+
+	runtimes := []*driver.Runtime{}
+	s.driverLock.Lock()
+	for _, runtime := range s.driverRuntimes {
+		if runtime.State == driver.DriverAlive {
+			runtimes = append(runtimes, runtime)
+		}
+	}
+	s.driverLock.Unlock()
+
+	hanging := []*driver.Runtime{}
+	for _, runtime := range runtimes {
+		s.healthLock.Lock()
+		h := s.getDriverHealth(runtime.Feature, runtime)
+		lastSeen := h.LastSeen
+		pending := h.pinging
+		if pending {
+			h.missedPings++
+		}
+		isHanging := h.missedPings >= missed || (!lastSeen.IsZero() && time.Since(lastSeen) > time.Duration(missed)*heartbeat)
+		if !isHanging && !pending {
+			h.pinging = true
+		}
+		s.healthLock.Unlock()
+
+		if isHanging {
+			hanging = append(hanging, runtime)
+
+			continue
+		}
+
+		if !pending {
+			// a wedged driver may block the sending, so it should not block checking the other drivers
+			go s.sendPing(runtime, h)
+		}
+	}
+
+	return hanging
+}
+
+// sendPing sends a ping message to the driver, a failed sending is counted as a missed heartbeat.
+func (s *Service) sendPing(runtime *driver.Runtime, h *DriverHealth) {
+	sent := false
+	defer func() {
+		s.healthLock.Lock()
+		defer s.healthLock.Unlock()
+		h.pinging = false
+		if sent {
+			h.missedPings = 0
+		} else {
+			h.missedPings++
+		}
+	}()
+	defer dipper.SafeExitOnError("[%s] failed to ping driver %s", s.name, runtime.Feature)
+
+	runtime.SendMessage(&dipper.Message{
+		Channel: "command",
+		Subject: "ping",
+	})
+	sent = true
+}
+
+// restartHangingDriver stops a driver that is not responding and restarts it.
+func (s *Service) restartHangingDriver(runtime *driver.Runtime) {
+	driverName := runtime.Handler.Meta().Name
+	dipper.Logger.Warningf("[%s] driver %s missed heartbeats, restarting", s.name, driverName)
+	if emitter, ok := daemon.Emitters[s.name]; ok {
+		emitter.CounterIncr("honey.honeydipper.driver.unhealthy", []string{
+			"service:" + s.name,
+			"driver:" + driverName,
+		})
+	}
+
+	// marked as failed so the service loop won't restart it again as a crash
+	runtime.State = driver.DriverFailed
+	if killer, ok := runtime.Handler.(driver.Killer); ok {
+		killer.Kill()
+	}
+	runtime.Handler.Close()
+	go loadFailedDriverRuntime(runtime, "missed heartbeats")
+}
+
+// driverHealthMetrics reports the number of restarts for each driver.
+func (s *Service) driverHealthMetrics() {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	for _, h := range s.driverHealth {
+		s.GaugeSet("honey.honeydipper.driver.restarts", strconv.Itoa(h.Restarts), []string{
+			"service:" + s.name,
+			"driver:" + h.Driver,
+		})
+	}
+}
+
+// handleDriverList returns the health records of all the drivers in the service.
+func (s *Service) handleDriverList(resp *api.Response) {
+	s.healthLock.Lock()
+	features := make([]string, 0, len(s.driverHealth))
+	for feature := range s.driverHealth {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	ret := make([]interface{}, 0, len(features))
+	for _, feature := range features {
+		ret = append(ret, *s.driverHealth[feature])
+	}
+	s.healthLock.Unlock()
+
+	resp.Return(map[string]interface{}{
+		"service": s.name,
+		"drivers": ret,
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/internal/driver"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriverFailedBackoff(t *testing.T) {
+	svc := &Service{
+		name: "testsvc",
+		config: &config.Config{DataSet: &config.DataSet{Drivers: map[string]interface{}{
+			"daemon": map[string]interface{}{
+				"supervisor": map[string]interface{}{"max_backoff": "30s"},
+			},
+		}}},
+	}
+	runtime := &driver.Runtime{
+		Feature: "receiver",
+		Handler: &driver.NullDriverHandler{MetaFunc: func() *driver.Meta { return &driver.Meta{Name: "d1"} }},
+	}
+
+	expected := []time.Duration{0, 5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, e := range expected {
+		assert.Equalf(t, e, svc.driverFailed(runtime, "crashed"), "backoff for failure #%d", i+1)
+	}
+
+	h := svc.driverHealth["receiver"]
+	assert.Equal(t, "d1", h.Driver)
+	assert.Equal(t, len(expected), h.Restarts)
+	assert.Equal(t, "crashed", h.LastError)
+	assert.False(t, h.Healthy)
+
+	svc.driverAlive(runtime)
+	assert.True(t, h.Healthy)
+	assert.Equal(t, 30*time.Second, svc.driverFailed(runtime, "crashed again"), "backoff continues if not stable")
+
+	svc.driverAlive(runtime)
+	h.aliveSince = time.Now().Add(-DriverStablePeriod - time.Second)
+	assert.Equal(t, time.Duration(0), svc.driverFailed(runtime, "crashed after stable"), "backoff reset after stable period")
+	assert.Equal(t, len(expected)+2, h.Restarts, "restarts are never reset")
+}
+
+func TestCheckHeartbeats(t *testing.T) {
+	pinged := make(chan string, 10)
+	newRuntime := func(feature string) *driver.Runtime {
+		return &driver.Runtime{
+			Feature: feature,
+			State:   driver.DriverAlive,
+			Handler: &driver.NullDriverHandler{
+				MetaFunc: func() *driver.Meta { return &driver.Meta{Name: feature} },
+				SendMessageFunc: func(msg *dipper.Message) {
+					assert.Equal(t, "command", msg.Channel)
+					assert.Equal(t, "ping", msg.Subject)
+					pinged <- feature
+				},
+			},
+		}
+	}
+	svc := &Service{
+		name: "testsvc",
+		driverRuntimes: map[string]*driver.Runtime{
+			"healthy": newRuntime("healthy"),
+			"hanging": newRuntime("hanging"),
+			"loading": newRuntime("loading"),
+		},
+	}
+	svc.driverRuntimes["loading"].State = driver.DriverLoading
+	svc.driverAlive(svc.driverRuntimes["healthy"])
+	svc.driverAlive(svc.driverRuntimes["hanging"])
+	svc.driverHealth["hanging"].LastSeen = time.Now().Add(-time.Minute)
+
+	hanging := svc.checkHeartbeats(time.Second)
+	assert.Equal(t, "healthy", <-pinged, "only the alive and responding drivers are pinged")
+	assert.Len(t, hanging, 1)
+	assert.Equal(t, "hanging", hanging[0].Feature)
+
+	svc.driverSeen(svc.driverRuntimes["hanging"])
+	assert.Empty(t, svc.checkHeartbeats(time.Second), "driver recovers after responding")
+	assert.ElementsMatch(t, []string{"healthy", "hanging"}, []string{<-pinged, <-pinged})
+	assert.Empty(t, pinged)
+}
+
+func TestCheckHeartbeatsBlockedSend(t *testing.T) {
+	unblock := make(chan struct{})
+	newRuntime := func(feature string, send func(*dipper.Message)) *driver.Runtime {
+		return &driver.Runtime{
+			Feature: feature,
+			State:   driver.DriverAlive,
+			Handler: &driver.NullDriverHandler{
+				MetaFunc:        func() *driver.Meta { return &driver.Meta{Name: feature} },
+				SendMessageFunc: send,
+			},
+		}
+	}
+	svc := &Service{
+		name: "testsvc",
+		driverRuntimes: map[string]*driver.Runtime{
+			"wedged": newRuntime("wedged", func(*dipper.Message) { <-unblock }),
+			"broken": newRuntime("broken", func(*dipper.Message) { panic("broken pipe") }),
+		},
+	}
+	defer close(unblock)
+	svc.driverAlive(svc.driverRuntimes["wedged"])
+	svc.driverAlive(svc.driverRuntimes["broken"])
+
+	missedPings := func(feature string) int {
+		svc.healthLock.Lock()
+		defer svc.healthLock.Unlock()
+
+		return svc.driverHealth[feature].missedPings
+	}
+
+	for i := 1; i <= DefaultDriverMissedHeartbeats; i++ {
+		done := make(chan []*driver.Runtime)
+		go func() { done <- svc.checkHeartbeats(time.Minute) }()
+		select {
+		case hanging := <-done:
+			assert.Empty(t, hanging, "round %d should not find hanging drivers", i)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "checking heartbeats should not be blocked by the wedged driver")
+		}
+		assert.Eventually(t, func() bool { return missedPings("broken") == i }, time.Second, 10*time.Millisecond)
+		svc.driverSeen(svc.driverRuntimes["wedged"])
+		svc.driverSeen(svc.driverRuntimes["broken"])
+	}
+
+	hanging := svc.checkHeartbeats(time.Minute)
+	features := []string{}
+	for _, r := range hanging {
+		features = append(features, r.Feature)
+	}
+	assert.ElementsMatch(t, []string{"wedged", "broken"}, features, "the pending and failed pings count as missed heartbeats")
+}
@@ -244,6 +244,8 @@ func (w *Session) complete(msg *dipper.Message) {
 		}
 	}
 
+	// the session may fail before being saved, the lock should be released regardless
+	w.releaseLock()
 	if w.ID != "" {
 		dipper.Logger.Infof("[workflow] session [%s] completed", w.ID)
 		w.ID = ""
@@ -317,6 +319,22 @@ func (w *Session) continueAfterHook(msg *dipper.Message) {
 // continueExec resume a session with given dipper message.
 func (w *Session) continueExec(msg *dipper.Message, exports []map[string]interface{}) {
 	w.mergeContext(exports)
+	if !w.isInCompleteHooks() {
+		if name := w.lostLock(); name != "" {
+			w.currentHook = ""
+			w.complete(&dipper.Message{
+				Channel: dipper.ChannelEventbus,
+				Subject: dipper.EventbusReturn,
+				Labels: map[string]string{
+					"status": SessionStatusError,
+					"reason": fmt.Sprintf("lock [%s] is lost", name),
+				},
+				Payload: map[string]interface{}{},
+			})
+
+			return
+		}
+	}
 	if w.currentHook != "" {
 		w.continueAfterHook(msg)
 
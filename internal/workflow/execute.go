@@ -41,9 +41,11 @@ func (w *Session) execute(msg *dipper.Message) {
 					defer dipper.SafeExitOnError("Failed in execute %+v", *w.workflow)
 					w.save()
 					defer w.onError()
+					w.acquireLock()
 					w.executeRound(msg)
 				}()
 			} else {
+				w.acquireLock()
 				w.executeRound(msg)
 			}
 		} else if w.parent != "" {
@@ -0,0 +1,173 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/honeydipper/honeydipper/internal/daemon"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+const (
+	// LockFeature is the name of the feature that provides the locking RPCs.
+	LockFeature = "locker"
+
+	// DefaultLockTTL is the default time to live for a workflow lock.
+	DefaultLockTTL = 5 * time.Minute
+
+	// LockRetryInterval is the interval between attempts to acquire a lock.
+	LockRetryInterval = time.Second
+
+	// LockRenewRatio decides how often a lock is renewed, e.g. 3 means renewing 3 times within a TTL.
+	LockRenewRatio = 3
+
+	// LockContextKey is the reserved context key for exposing the lock name and fencing token to the workflow.
+	LockContextKey = "_lock"
+)
+
+// sessionLock is an exclusive lock being held by a session.
+type sessionLock struct {
+	name    string
+	owner   string
+	session string
+	ttl     time.Duration
+	done    chan struct{}
+	lost    bool
+}
+
+// acquireLock acquires the lock defined in the workflow before executing the subtree.
+func (w *Session) acquireLock() {
+	if w.workflow.Lock == nil || w.heldLock != nil {
+		return
+	}
+
+	name := w.workflow.Lock.Name
+	if name == "" {
+		panic(fmt.Errorf("%w: lock name is empty in workflow: %s", ErrWorkflowError, w.workflow.Name))
+	}
+	ttl := DefaultLockTTL
+	if w.workflow.Lock.TTL != "" {
+		ttl = dipper.Must(time.ParseDuration(w.workflow.Lock.TTL)).(time.Duration)
+	}
+	var wait time.Duration
+	if w.workflow.Lock.WaitTimeout != "" {
+		wait = dipper.Must(time.ParseDuration(w.workflow.Lock.WaitTimeout)).(time.Duration)
+	}
+
+	w.performing = "acquiring lock " + name
+	deadline := time.Now().Add(wait)
+	for {
+		ret, err := w.store.Helper.Call(LockFeature, "lock", map[string]interface{}{
+			"name":   name,
+			"expire": ttl.String(),
+		})
+		if err == nil {
+			acquired := dipper.DeserializeContent(ret)
+			owner, _ := dipper.GetMapDataStr(acquired, "owner")
+			held := &sessionLock{
+				name:    name,
+				owner:   owner,
+				session: w.ID,
+				ttl:     ttl,
+				done:    make(chan struct{}),
+			}
+			w.store.locksLock.Lock()
+			w.heldLock = held
+			w.store.locksLock.Unlock()
+
+			// expose the fencing token so it can be passed to downstream systems
+			if w.ctx == nil {
+				w.ctx = map[string]interface{}{}
+			}
+			fencingToken, _ := dipper.GetMapData(acquired, "fencing_token")
+			w.ctx[LockContextKey] = map[string]interface{}{
+				"name":          name,
+				"fencing_token": fencingToken,
+			}
+			dipper.Logger.Infof("[workflow] session [%s] acquired lock [%s]", w.ID, name)
+
+			go w.renewLock(held)
+
+			return
+		}
+		if !time.Now().Add(LockRetryInterval).Before(deadline) || daemon.ShuttingDown {
+			panic(fmt.Errorf("%w: unable to acquire lock %s: %v", ErrWorkflowError, name, err))
+		}
+		time.Sleep(LockRetryInterval)
+	}
+}
+
+// renewLock keeps extending the lock TTL until the lock is released.  If the lock can not be renewed, it may have
+// expired and been taken by others, the lock is marked as lost and the session fails before taking more actions.
+func (w *Session) renewLock(l *sessionLock) {
+	defer dipper.SafeExitOnError("[workflow] session [%s] stop renewing lock [%s]", l.session, l.name)
+
+	ticker := time.NewTicker(l.ttl / LockRenewRatio)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			_, err := w.store.Helper.Call(LockFeature, "renew", map[string]interface{}{
+				"name":   l.name,
+				"owner":  l.owner,
+				"expire": l.ttl.String(),
+			})
+			if err != nil {
+				dipper.Logger.Errorf("[workflow] session [%s] lost lock [%s]: %+v", l.session, l.name, err)
+				w.store.locksLock.Lock()
+				l.lost = true
+				w.store.locksLock.Unlock()
+
+				return
+			}
+		}
+	}
+}
+
+// lostLock returns the name of the lock that is lost by the session or any of its parent sessions.
+func (w *Session) lostLock() string {
+	w.store.locksLock.Lock()
+	defer w.store.locksLock.Unlock()
+	for s := w; s != nil; {
+		if s.heldLock != nil && s.heldLock.lost {
+			return s.heldLock.name
+		}
+		if s.parent == "" {
+			break
+		}
+		s, _ = dipper.IDMapGet(&w.store.sessions, s.parent).(*Session)
+	}
+
+	return ""
+}
+
+// releaseLock releases the lock held by the session, if any.
+func (w *Session) releaseLock() {
+	w.store.locksLock.Lock()
+	l := w.heldLock
+	w.heldLock = nil
+	w.store.locksLock.Unlock()
+	if l == nil {
+		return
+	}
+	close(l.done)
+
+	_, err := w.store.Helper.Call(LockFeature, "unlock", map[string]interface{}{
+		"name":  l.name,
+		"owner": l.owner,
+	})
+	if err != nil {
+		dipper.Logger.Warningf("[workflow] session [%s] failed to release lock [%s]: %+v", l.session, l.name, err)
+
+		return
+	}
+	dipper.Logger.Infof("[workflow] session [%s] released lock [%s]", l.session, l.name)
+}
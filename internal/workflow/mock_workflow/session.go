@@ -42,6 +42,18 @@ func (m *MockSessionHandler) EXPECT() *MockSessionHandlerMockRecorder {
 	return m.recorder
 }
 
+// AddProgress mocks base method.
+func (m *MockSessionHandler) AddProgress(entry interface{}) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddProgress", entry)
+}
+
+// AddProgress indicates an expected call of AddProgress.
+func (mr *MockSessionHandlerMockRecorder) AddProgress(entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddProgress", reflect.TypeOf((*MockSessionHandler)(nil).AddProgress), entry)
+}
+
 // GetCompletionTime mocks base method.
 func (m *MockSessionHandler) GetCompletionTime() time.Time {
 	m.ctrl.T.Helper()
@@ -140,6 +152,21 @@ func (mr *MockSessionHandlerMockRecorder) GetParent() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetParent", reflect.TypeOf((*MockSessionHandler)(nil).GetParent))
 }
 
+// GetProgress mocks base method.
+func (m *MockSessionHandler) GetProgress(since int) ([]interface{}, int) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProgress", since)
+	ret0, _ := ret[0].([]interface{})
+	ret1, _ := ret[1].(int)
+	return ret0, ret1
+}
+
+// GetProgress indicates an expected call of GetProgress.
+func (mr *MockSessionHandlerMockRecorder) GetProgress(since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProgress", reflect.TypeOf((*MockSessionHandler)(nil).GetProgress), since)
+}
+
 // GetStartTime mocks base method.
 func (m *MockSessionHandler) GetStartTime() time.Time {
 	m.ctrl.T.Helper()
@@ -183,6 +210,20 @@ func (mr *MockSessionHandlerMockRecorder) Watch() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockSessionHandler)(nil).Watch))
 }
 
+// WatchProgress mocks base method.
+func (m *MockSessionHandler) WatchProgress(since int) <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchProgress", since)
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// WatchProgress indicates an expected call of WatchProgress.
+func (mr *MockSessionHandlerMockRecorder) WatchProgress(since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchProgress", reflect.TypeOf((*MockSessionHandler)(nil).WatchProgress), since)
+}
+
 // continueExec mocks base method.
 func (m *MockSessionHandler) continueExec(msg *dipper.Message, exports []map[string]interface{}) {
 	m.ctrl.T.Helper()
@@ -42,6 +42,21 @@ func (m *MockSessionStoreHelper) EXPECT() *MockSessionStoreHelperMockRecorder {
 	return m.recorder
 }
 
+// Call mocks base method.
+func (m *MockSessionStoreHelper) Call(feature, method string, params interface{}) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Call", feature, method, params)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Call indicates an expected call of Call.
+func (mr *MockSessionStoreHelperMockRecorder) Call(feature, method, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Call", reflect.TypeOf((*MockSessionStoreHelper)(nil).Call), feature, method, params)
+}
+
 // GetConfig mocks base method.
 func (m *MockSessionStoreHelper) GetConfig() *config.Config {
 	m.ctrl.T.Helper()
@@ -0,0 +1,92 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package workflow
+
+import (
+	"sync"
+	"time"
+
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+)
+
+// MaxProgressEntries is the number of progress updates kept for each event triggered session, the older updates
+// are dropped.
+var MaxProgressEntries = 1000
+
+// progressLog keeps the progress updates sent by the long running functions.
+type progressLog struct {
+	lock    sync.Mutex
+	entries []interface{}
+	dropped int
+	updated chan struct{}
+}
+
+// AddProgress records a progress update in the session.
+func (w *Session) AddProgress(entry interface{}) {
+	w.progress.lock.Lock()
+	defer w.progress.lock.Unlock()
+	w.progress.entries = append(w.progress.entries, entry)
+	if extra := len(w.progress.entries) - MaxProgressEntries; extra > 0 {
+		w.progress.entries = w.progress.entries[extra:]
+		w.progress.dropped += extra
+	}
+	if w.progress.updated != nil {
+		close(w.progress.updated)
+		w.progress.updated = nil
+	}
+}
+
+// WatchProgress returns a channel that is closed when there are progress updates after the given position.
+func (w *Session) WatchProgress(since int) <-chan struct{} {
+	w.progress.lock.Lock()
+	defer w.progress.lock.Unlock()
+	if since < w.progress.dropped+len(w.progress.entries) {
+		ready := make(chan struct{})
+		close(ready)
+
+		return ready
+	}
+	if w.progress.updated == nil {
+		w.progress.updated = make(chan struct{})
+	}
+
+	return w.progress.updated
+}
+
+// GetProgress returns the progress updates starting from the given position, and the position for the next read.
+func (w *Session) GetProgress(since int) ([]interface{}, int) {
+	w.progress.lock.Lock()
+	defer w.progress.lock.Unlock()
+	next := w.progress.dropped + len(w.progress.entries)
+	start := since - w.progress.dropped
+	if start < 0 {
+		start = 0
+	}
+	if start >= len(w.progress.entries) {
+		return []interface{}{}, next
+	}
+	ret := make([]interface{}, len(w.progress.entries)-start)
+	copy(ret, w.progress.entries[start:])
+
+	return ret, next
+}
+
+// RecordProgress records the progress update from a function call in the event triggered session.
+func (s *SessionStore) RecordProgress(sessionID string, msg *dipper.Message) {
+	defer dipper.SafeExitOnError("[workflow] error when recording progress for session %s", sessionID)
+	w := dipper.IDMapGet(&s.sessions, sessionID).(SessionHandler)
+	root := w
+	for root.GetParent() != "" {
+		root = dipper.IDMapGet(&s.sessions, root.GetParent()).(SessionHandler)
+	}
+	root.AddProgress(map[string]interface{}{
+		"session": sessionID,
+		"name":    w.GetName(),
+		"time":    time.Now().Format(time.RFC3339Nano),
+		"data":    msg.Payload,
+	})
+}
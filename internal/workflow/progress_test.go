@@ -0,0 +1,97 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package workflow
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/internal/workflow/mock_workflow"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionProgress(t *testing.T) {
+	orig := MaxProgressEntries
+	MaxProgressEntries = 3
+	defer func() { MaxProgressEntries = orig }()
+
+	w := &Session{progress: &progressLog{}}
+	progress, next := w.GetProgress(0)
+	assert.Empty(t, progress)
+	assert.Zero(t, next)
+
+	for i := 0; i < 5; i++ {
+		w.AddProgress(i)
+	}
+	progress, next = w.GetProgress(0)
+	assert.Equal(t, []interface{}{2, 3, 4}, progress, "should only keep the latest entries")
+	assert.Equal(t, 5, next)
+	progress, next = w.GetProgress(3)
+	assert.Equal(t, []interface{}{3, 4}, progress)
+	assert.Equal(t, 5, next)
+	progress, next = w.GetProgress(next)
+	assert.Empty(t, progress, "should return nothing when all read")
+	assert.Equal(t, 5, next)
+}
+
+func TestSessionWatchProgress(t *testing.T) {
+	w := &Session{progress: &progressLog{}}
+	updated := w.WatchProgress(0)
+	assert.Equal(t, updated, w.WatchProgress(0), "should share the channel among the watchers")
+	select {
+	case <-updated:
+		assert.Fail(t, "should not be closed before any update")
+	default:
+	}
+
+	w.AddProgress("first")
+	select {
+	case <-updated:
+	default:
+		assert.Fail(t, "should be closed after an update")
+	}
+
+	select {
+	case <-w.WatchProgress(0):
+	default:
+		assert.Fail(t, "should be closed when there are unread updates")
+	}
+	select {
+	case <-w.WatchProgress(1):
+		assert.Fail(t, "should not be closed when all updates are read")
+	default:
+	}
+}
+
+func TestRecordProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := NewSessionStore(mock_workflow.NewMockSessionStoreHelper(ctrl))
+	defer delete(dipper.IDMapMetadata, &s.sessions)
+
+	root := s.newSession("", "uuid", &config.Workflow{Name: "root"}).(*Session)
+	root.save()
+	child := s.newSession(root.ID, "uuid", &config.Workflow{Name: "child"}).(*Session)
+	child.save()
+
+	s.RecordProgress(child.ID, &dipper.Message{Payload: map[string]interface{}{"log": "line 1"}})
+	progress, _ := root.GetProgress(0)
+	assert.Len(t, progress, 1, "should record the progress in the root session")
+	assert.Equal(t, child.ID, dipper.MustGetMapDataStr(progress[0], "session"))
+	assert.Equal(t, "child", dipper.MustGetMapDataStr(progress[0], "name"))
+	assert.Equal(t, "line 1", dipper.MustGetMapDataStr(progress[0], "data.log"))
+	progress, _ = child.GetProgress(0)
+	assert.Empty(t, progress)
+
+	assert.NotPanics(t, func() { s.RecordProgress("missing", &dipper.Message{}) }, "should ignore unknown sessions")
+}
@@ -37,6 +37,7 @@ type Session struct {
 	exported       []map[string]interface{}
 	elseBranch     *config.Workflow
 	inFlyFunction  *config.Function
+	heldLock       *sessionLock
 	store          *SessionStore
 	loadedContexts []string
 	currentHook    string
@@ -47,6 +48,7 @@ type Session struct {
 	cancelFunc     context.CancelFunc
 	startTime      time.Time
 	completionTime time.Time
+	progress       *progressLog
 }
 
 // SessionHandler prepare and execute the session provides entry point for SessionStore to invoke and mock for testing.
@@ -65,6 +67,9 @@ type SessionHandler interface {
 	Watch() <-chan struct{}
 	GetStartTime() time.Time
 	GetCompletionTime() time.Time
+	AddProgress(entry interface{})
+	GetProgress(since int) ([]interface{}, int)
+	WatchProgress(since int) <-chan struct{}
 }
 
 const (
@@ -315,6 +320,14 @@ func (w *Session) interpolateWorkflow(msg *dipper.Message) {
 	ret.CallFunction = dipper.InterpolateStr(v.CallFunction, envData)
 	ret.CallDriver = dipper.InterpolateStr(v.CallDriver, envData)
 
+	if v.Lock != nil {
+		ret.Lock = &config.WorkflowLock{
+			Name:        dipper.InterpolateStr(v.Lock.Name, envData),
+			TTL:         dipper.InterpolateStr(v.Lock.TTL, envData),
+			WaitTimeout: dipper.InterpolateStr(v.Lock.WaitTimeout, envData),
+		}
+	}
+
 	ret.Iterate = dipper.Interpolate(v.Iterate, envData)
 	if ret.Iterate == nil && v.Iterate != nil {
 		ret.Iterate = []interface{}{}
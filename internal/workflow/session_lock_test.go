@@ -0,0 +1,190 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package workflow
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/honeydipper/honeydipper/internal/config"
+	"github.com/honeydipper/honeydipper/internal/workflow/mock_workflow"
+	"github.com/honeydipper/honeydipper/pkg/dipper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowLock(t *testing.T) {
+	testcase := map[string]interface{}{
+		"workflow": &config.Workflow{
+			Lock: &config.WorkflowLock{
+				Name: "deploy-{{ .ctx.env }}",
+			},
+			CallDriver: "foo.bar",
+		},
+		"msg": &dipper.Message{},
+		"ctx": map[string]interface{}{
+			"env": "prod",
+		},
+		"asserts": func() {
+			mockHelper.EXPECT().GetDaemonID().AnyTimes().Return("")
+			gomock.InOrder(
+				mockHelper.EXPECT().Call(gomock.Eq("locker"), gomock.Eq("lock"), gomock.Eq(map[string]interface{}{
+					"name":   "deploy-prod",
+					"expire": "5m0s",
+				})).Times(1).Return([]byte(`{"owner":"token1","fencing_token":7}`), nil),
+				mockHelper.EXPECT().SendMessage(gomock.Eq(&dipper.Message{
+					Channel: "eventbus",
+					Subject: "command",
+					Labels: map[string]string{
+						"sessionID": "0",
+					},
+					Payload: map[string]interface{}{
+						"ctx": map[string]interface{}{
+							"_meta_desc":   "",
+							"_meta_name":   "foo.bar",
+							"env":          "prod",
+							"resume_token": "//0",
+							"_lock": map[string]interface{}{
+								"name":          "deploy-prod",
+								"fencing_token": float64(7),
+							},
+						},
+						"data":  map[string]interface{}{},
+						"event": map[string]interface{}{},
+						"function": config.Function{
+							Driver:    "foo",
+							RawAction: "bar",
+						},
+						"labels": emptyLabels,
+					},
+				})).Times(1),
+			)
+		},
+		"steps": []map[string]interface{}{
+			{
+				"sessionID": "0",
+				"msg": &dipper.Message{
+					Channel: "eventbus",
+					Subject: "return",
+					Labels: map[string]string{
+						"sessionID": "0",
+						"status":    "success",
+					},
+				},
+				"ctx": map[string]interface{}{},
+				"asserts": func() {
+					mockHelper.EXPECT().Call(gomock.Eq("locker"), gomock.Eq("unlock"), gomock.Eq(map[string]interface{}{
+						"name":  "deploy-prod",
+						"owner": "token1",
+					})).Times(1).Return(nil, nil)
+				},
+			},
+		},
+	}
+
+	syntheticTest(t, configStr, testcase)
+}
+
+func TestWorkflowLockReleaseOnError(t *testing.T) {
+	testcase := map[string]interface{}{
+		"workflow": &config.Workflow{
+			Lock: &config.WorkflowLock{
+				Name: "deploy",
+				TTL:  "1m",
+			},
+			CallDriver: "foo.bar",
+		},
+		"msg": &dipper.Message{},
+		"ctx": map[string]interface{}{},
+		"asserts": func() {
+			mockHelper.EXPECT().GetDaemonID().AnyTimes().Return("")
+			mockHelper.EXPECT().Call(gomock.Eq("locker"), gomock.Eq("lock"), gomock.Eq(map[string]interface{}{
+				"name":   "deploy",
+				"expire": "1m0s",
+			})).Times(1).Return([]byte(`{"owner":"token2"}`), nil)
+			mockHelper.EXPECT().SendMessage(gomock.Any()).Times(1)
+		},
+		"steps": []map[string]interface{}{
+			{
+				"sessionID": "0",
+				"msg": &dipper.Message{
+					Channel: "eventbus",
+					Subject: "return",
+					Labels: map[string]string{
+						"sessionID": "0",
+						"status":    "error",
+						"reason":    "something is wrong",
+					},
+				},
+				"ctx": map[string]interface{}{},
+				"asserts": func() {
+					mockHelper.EXPECT().Call(gomock.Eq("locker"), gomock.Eq("unlock"), gomock.Eq(map[string]interface{}{
+						"name":  "deploy",
+						"owner": "token2",
+					})).Times(1).Return(nil, nil)
+				},
+			},
+		},
+	}
+
+	syntheticTest(t, configStr, testcase)
+}
+
+func TestWorkflowLockFailToAcquire(t *testing.T) {
+	testcase := map[string]interface{}{
+		"workflow": &config.Workflow{
+			Lock: &config.WorkflowLock{
+				Name: "deploy",
+			},
+			CallDriver: "foo.bar",
+		},
+		"msg": &dipper.Message{},
+		"ctx": map[string]interface{}{},
+		"asserts": func() {
+			mockHelper.EXPECT().GetDaemonID().AnyTimes().Return("")
+			mockHelper.EXPECT().Call(gomock.Eq("locker"), gomock.Eq("lock"), gomock.Any()).Times(1).Return(nil, errors.New("fail to lock"))
+		},
+		"steps": []map[string]interface{}{},
+	}
+
+	syntheticTest(t, configStr, testcase)
+}
+
+func TestWorkflowLockLost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockHelper := mock_workflow.NewMockSessionStoreHelper(ctrl)
+	s := NewSessionStore(mockHelper)
+	defer delete(dipper.IDMapMetadata, &s.sessions)
+
+	root := s.newSession("", "uuid", &config.Workflow{Name: "root"}).(*Session)
+	root.save()
+	child := s.newSession(root.ID, "uuid", &config.Workflow{Name: "child"}).(*Session)
+	child.save()
+	other := s.newSession("", "uuid", &config.Workflow{Name: "other"}).(*Session)
+	other.save()
+
+	held := &sessionLock{name: "deploy", owner: "token1", session: root.ID, ttl: 30 * time.Millisecond, done: make(chan struct{})}
+	root.heldLock = held
+	mockHelper.EXPECT().Call(gomock.Eq("locker"), gomock.Eq("renew"), gomock.Any()).Times(1).Return(nil, errors.New("not owner"))
+	go root.renewLock(held)
+	assert.Eventually(t, func() bool { return child.lostLock() == "deploy" }, time.Second, 10*time.Millisecond,
+		"should mark the lock as lost for the sessions under the lock")
+	assert.Equal(t, "deploy", root.lostLock())
+	assert.Equal(t, "", other.lostLock())
+
+	mockHelper.EXPECT().Call(gomock.Eq("locker"), gomock.Eq("unlock"), gomock.Any()).Times(1).Return(nil, nil)
+	root.continueExec(&dipper.Message{Labels: map[string]string{"status": SessionStatusSuccess}}, nil)
+	assert.Equal(t, SessionStatusError, root.savedMsg.Labels["status"], "should fail the session when the lock is lost")
+	assert.Equal(t, "lock [deploy] is lost", root.savedMsg.Labels["reason"])
+	assert.Nil(t, root.heldLock)
+}
@@ -21,6 +21,7 @@ type SessionStoreHelper interface {
 	GetConfig() *config.Config
 	SendMessage(msg *dipper.Message)
 	GetDaemonID() string
+	Call(feature string, method string, params interface{}) ([]byte, error)
 }
 
 // SessionStore stores session in memory and provides helper function for session to perform.
@@ -28,6 +29,7 @@ type SessionStore struct {
 	sessions          map[string]SessionHandler
 	suspendedSessions map[string]string
 	Helper            SessionStoreHelper
+	locksLock         sync.Mutex
 }
 
 // NewSessionStore initialize the session store.
@@ -55,6 +57,7 @@ func (s *SessionStore) newSession(parent string, eventUUID string, wf *config.Wo
 		EventID:   eventUUID,
 		workflow:  wf,
 		ctxLock:   &sync.Mutex{},
+		progress:  &progressLog{},
 		startTime: time.Now(),
 	}
 
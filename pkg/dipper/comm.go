@@ -194,6 +194,14 @@ func FetchRawMessage(in io.Reader) (msg *Message) {
 
 // SendMessage : send a message to the io.Writer, may change the message to raw.
 func SendMessage(out io.Writer, msg *Message) {
+	if pipe, ok := out.(*MessagePipe); ok {
+		if !pipe.Put(msg) {
+			panic(fmt.Errorf("%w: message pipe closed", ErrInProcDriver))
+		}
+
+		return
+	}
+
 	payload := []byte{}
 	if msg.Payload != nil {
 		if !msg.IsRaw {
@@ -226,6 +234,13 @@ func SendMessage(out io.Writer, msg *Message) {
 	}
 }
 
+// ForgetComm : remove the lock for a comm channel that is no longer used.
+func ForgetComm(out io.Writer) {
+	MasterCommLock.Lock()
+	defer MasterCommLock.Unlock()
+	delete(CommLocks, out)
+}
+
 // LockComm : Lock the comm channel.
 func LockComm(out io.Writer) {
 	var lock *sync.Mutex
@@ -22,6 +22,9 @@ const (
 	FAILURE = "failure"
 	// ERROR means the workflow run into errors and could not complete.
 	ERROR = "error"
+
+	// LabelProgress marks a message sent back to the caller as a progress update instead of the return.
+	LabelProgress = "progress"
 )
 
 // CommandProvider : an interface for providing Command handling feature.
@@ -89,6 +92,29 @@ func (p *CommandProvider) Return(call *Message, retval *Message) {
 	SendMessage(p.ReturnWriter, retMsg)
 }
 
+// Progress sends a progress update to the caller while the command is still running.
+func (p *CommandProvider) Progress(call *Message, payload interface{}) {
+	if _, ok := call.Labels["sessionID"]; !ok {
+		return
+	}
+
+	labels := map[string]string{}
+	for k, v := range call.Labels {
+		labels[k] = v
+	}
+	delete(labels, "backoff_ms")
+	delete(labels, "retry")
+	delete(labels, "timeout")
+	labels[LabelProgress] = "true"
+
+	SendMessage(p.ReturnWriter, &Message{
+		Channel: p.Channel,
+		Subject: p.Subject,
+		Labels:  labels,
+		Payload: payload,
+	})
+}
+
 type commandWrapper struct {
 	msg      *Message
 	method   string
@@ -189,3 +189,24 @@ func TestCommandRetryRougueFunction(t *testing.T) {
 	assert.Equal(t, "success", ret.Labels["status"], "should return error after retry error")
 	assert.Equal(t, "3", ret.Payload.(map[string]interface{})["counter"], "should return the final counter")
 }
+
+func TestCommandProgress(t *testing.T) {
+	b := bytes.Buffer{}
+	subject := CommandProvider{
+		ReturnWriter: &b,
+		Channel:      "test",
+		Subject:      "test",
+	}
+
+	subject.Progress(&Message{Labels: map[string]string{"method": "test"}}, "ignored")
+	assert.Zero(t, b.Len(), "should not send progress without session")
+
+	call := &Message{Labels: map[string]string{"method": "test", "sessionID": "1", "timeout": "10"}}
+	subject.Progress(call, map[string]interface{}{"log": "line 1"})
+	ret := DeserializePayload(FetchMessage(&b))
+	assert.Equal(t, "true", ret.Labels[LabelProgress])
+	assert.Equal(t, "1", ret.Labels["sessionID"])
+	assert.NotContains(t, ret.Labels, "timeout")
+	assert.Equal(t, map[string]interface{}{"log": "line 1"}, ret.Payload)
+	assert.Equal(t, "10", call.Labels["timeout"], "should not change the call labels")
+}
@@ -44,6 +44,10 @@ type Driver struct {
 	Reload          MessageHandler
 	ReadySignal     chan bool
 	APITimeout      time.Duration
+	Services        []string // the services the driver can be used in, all services if empty
+	Features        []string // the named features the driver provides, e.g. eventbus
+
+	inProc bool
 }
 
 // NewDriver : create a blank driver object.
@@ -78,6 +82,17 @@ func NewDriver(service string, name string) *Driver {
 
 // Run : start a loop to communicate with daemon.
 func (d *Driver) Run() {
+	if os.Getenv(RemoteListenEnv) != "" {
+		listener, err := ListenRemote()
+		if err != nil {
+			Logger.Fatalf("[%s] unable to serve as remote driver: %+v", d.Service, err)
+		}
+		Logger.Infof("[%s] remote driver listening on %s", d.Service, os.Getenv(RemoteListenEnv))
+		d.RunRemote(listener)
+
+		return
+	}
+
 	Logger.Infof("[%s] driver loaded", d.Service)
 	for {
 		func() {
@@ -87,19 +102,22 @@ func (d *Driver) Run() {
 			})
 			for {
 				msg := FetchRawMessage(d.In)
-				go func() {
-					defer SafeExitOnError("[%s] Continuing driver message loop", d.Service)
-					if handler, ok := d.MessageHandlers[msg.Channel+":"+msg.Subject]; ok {
-						handler(msg)
-					} else {
-						Logger.Infof("[%s] skipping message without handler: %s:%s", d.Service, msg.Channel, msg.Subject)
-					}
-				}()
+				go d.handle(msg)
 			}
 		}()
 	}
 }
 
+// handle : dispatch a message from daemon to its handler.
+func (d *Driver) handle(msg *Message) {
+	defer SafeExitOnError("[%s] Continuing driver message loop", d.Service)
+	if handler, ok := d.MessageHandlers[msg.Channel+":"+msg.Subject]; ok {
+		handler(msg)
+	} else {
+		Logger.Infof("[%s] skipping message without handler: %s:%s", d.Service, msg.Channel, msg.Subject)
+	}
+}
+
 // Ping : respond to daemon ping request with driver state.
 func (d *Driver) Ping(msg *Message) {
 	d.SendMessage(&Message{
@@ -114,8 +132,11 @@ func (d *Driver) ReceiveOptions(msg *Message) {
 	Recursive(msg.Payload, RegexParser)
 	DecryptAll(d, msg.Payload)
 	d.Options = msg.Payload
-	Logger = nil
-	d.GetLogger()
+	if !d.inProc {
+		// in-process drivers share the logger with the daemon
+		Logger = nil
+		d.GetLogger()
+	}
 	d.APITimeout = DefaultAPITimeout
 	apiTimeoutStr, ok := d.GetOptionStr("data.api_timeout")
 	if ok {
@@ -144,6 +165,7 @@ func (d *Driver) start(msg *Message) {
 		}
 		d.State = "alive"
 	}
+	d.sendHandshake()
 	d.Ping(msg)
 }
 
@@ -189,7 +211,12 @@ func (d *Driver) GetLogger() *logging.Logger {
 			levelstr = "INFO"
 		}
 		if logFile == nil {
-			logFile = os.NewFile(DriverLogDescriptor, "log")
+			if os.Getenv(RemoteListenEnv) != "" {
+				// remote drivers are not started by the daemon, there is no log descriptor passed down
+				logFile = os.Stderr
+			} else {
+				logFile = os.NewFile(DriverLogDescriptor, "log")
+			}
 		}
 
 		return GetLogger(d.Name, levelstr, logFile)
@@ -0,0 +1,121 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package dipper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// ProtocolVersion is the version of the protocol between the daemon and the drivers built with this package.
+	ProtocolVersion = 1
+
+	// MinProtocolVersion is the oldest driver protocol version accepted by the daemon.
+	MinProtocolVersion = 1
+
+	// StateHandshake is the subject on the state channel used by the drivers to announce their capabilities.
+	StateHandshake = "handshake"
+)
+
+// ErrIncompatibleDriver means the driver can not work with the daemon.
+var ErrIncompatibleDriver = errors.New("incompatible driver")
+
+// Handshake is announced by the driver when it starts, so the daemon knows what the driver can do.
+type Handshake struct {
+	Protocol int      `json:"protocol"`
+	Name     string   `json:"name,omitempty"`
+	Services []string `json:"services,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+	RPCs     []string `json:"rpcs,omitempty"`
+	Features []string `json:"features,omitempty"`
+}
+
+// Handshake : assemble the handshake from the commands and rpc methods registered in the driver.
+func (d *Driver) Handshake() *Handshake {
+	h := &Handshake{
+		Protocol: ProtocolVersion,
+		Name:     d.Name,
+		Services: d.Services,
+		Features: d.Features,
+	}
+	for name := range d.Commands {
+		h.Commands = append(h.Commands, name)
+	}
+	sort.Strings(h.Commands)
+	for name := range d.RPCHandlers {
+		h.RPCs = append(h.RPCs, name)
+	}
+	sort.Strings(h.RPCs)
+
+	return h
+}
+
+func (d *Driver) sendHandshake() {
+	d.SendMessage(&Message{
+		Channel: ChannelState,
+		Subject: StateHandshake,
+		Payload: d.Handshake(),
+	})
+}
+
+// ParseHandshake : decode the handshake from the message sent by the driver.
+func ParseHandshake(msg *Message) (*Handshake, error) {
+	msg = DeserializePayload(msg)
+	if h, ok := msg.Payload.(*Handshake); ok {
+		return h, nil
+	}
+
+	h := &Handshake{}
+	data, err := json.Marshal(msg.Payload)
+	if err == nil {
+		err = json.Unmarshal(data, h)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed handshake: %v", ErrIncompatibleDriver, err)
+	}
+
+	return h, nil
+}
+
+// Check : make sure the driver is able to work with the daemon in the given service and feature.
+func (h *Handshake) Check(service string, feature string) error {
+	if h.Protocol < MinProtocolVersion || h.Protocol > ProtocolVersion {
+		return fmt.Errorf("%w: driver speaks protocol version %d, daemon supports %d to %d", ErrIncompatibleDriver, h.Protocol, MinProtocolVersion, ProtocolVersion)
+	}
+	if len(h.Services) > 0 && !contains(h.Services, service) {
+		return fmt.Errorf("%w: driver only supports services %s, not %s", ErrIncompatibleDriver, strings.Join(h.Services, ","), service)
+	}
+	if len(h.Features) > 0 && !strings.HasPrefix(feature, "driver:") && !contains(h.Features, feature) {
+		return fmt.Errorf("%w: driver only provides features %s, not %s", ErrIncompatibleDriver, strings.Join(h.Features, ","), feature)
+	}
+
+	return nil
+}
+
+// HasCommand : check if the driver handles the command, always true for the drivers without handshake.
+func (h *Handshake) HasCommand(name string) bool {
+	return h == nil || contains(h.Commands, name)
+}
+
+// HasRPC : check if the driver handles the rpc method, always true for the drivers without handshake.
+func (h *Handshake) HasRPC(name string) bool {
+	return h == nil || contains(h.RPCs, name)
+}
+
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package dipper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriverHandshake(t *testing.T) {
+	d := NewDriver("operator", "test")
+	d.Features = []string{"eventbus"}
+	d.Commands["b"] = func(*Message) {}
+	d.Commands["a"] = func(*Message) {}
+	d.RPCHandlers["echo"] = func(*Message) {}
+
+	h := d.Handshake()
+	assert.Equal(t, &Handshake{
+		Protocol: ProtocolVersion,
+		Name:     "test",
+		Commands: []string{"a", "b"},
+		RPCs:     []string{"echo"},
+		Features: []string{"eventbus"},
+	}, h)
+
+	buf := &bytes.Buffer{}
+	SendMessage(buf, &Message{Channel: ChannelState, Subject: StateHandshake, Payload: h})
+	parsed, err := ParseHandshake(FetchRawMessage(buf))
+	assert.Nil(t, err)
+	assert.Equal(t, h, parsed, "handshake should survive serialization")
+
+	parsed, err = ParseHandshake(&Message{Payload: h})
+	assert.Nil(t, err)
+	assert.Same(t, h, parsed)
+
+	_, err = ParseHandshake(&Message{Payload: map[string]interface{}{"protocol": "one"}})
+	assert.ErrorIs(t, err, ErrIncompatibleDriver)
+}
+
+func TestHandshakeCheck(t *testing.T) {
+	assert.Nil(t, (&Handshake{Protocol: ProtocolVersion}).Check("operator", "eventbus"))
+	assert.EqualError(t, (&Handshake{Protocol: ProtocolVersion + 1}).Check("operator", "eventbus"),
+		"incompatible driver: driver speaks protocol version 2, daemon supports 1 to 1")
+	assert.ErrorIs(t, (&Handshake{}).Check("operator", "eventbus"), ErrIncompatibleDriver)
+
+	h := &Handshake{Protocol: ProtocolVersion, Services: []string{"receiver"}, Features: []string{"eventbus"}}
+	assert.Nil(t, h.Check("receiver", "eventbus"))
+	assert.Nil(t, h.Check("receiver", "driver:redisqueue"), "any driver can be loaded with driver: prefix")
+	assert.EqualError(t, h.Check("operator", "eventbus"), "incompatible driver: driver only supports services receiver, not operator")
+	assert.EqualError(t, h.Check("receiver", "emitter"), "incompatible driver: driver only provides features eventbus, not emitter")
+}
+
+func TestHandshakeCapabilities(t *testing.T) {
+	var legacy *Handshake
+	assert.True(t, legacy.HasCommand("anything"), "drivers without handshake are not validated")
+	assert.True(t, legacy.HasRPC("anything"), "drivers without handshake are not validated")
+
+	h := &Handshake{Protocol: ProtocolVersion, Commands: []string{"recycleDeployment"}, RPCs: []string{"decrypt"}}
+	assert.True(t, h.HasCommand("recycleDeployment"))
+	assert.False(t, h.HasCommand("decrypt"))
+	assert.True(t, h.HasRPC("decrypt"))
+	assert.False(t, h.HasRPC("recycleDeployment"))
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package dipper
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrInProcDriver is the base for all in-process driver related errors.
+var ErrInProcDriver = errors.New("in-process driver error")
+
+// InProcFactory creates a driver for the given service to run in the daemon process.
+type InProcFactory func(service string) *Driver
+
+var (
+	inProcDrivers     = map[string]InProcFactory{}
+	inProcDriversLock sync.Mutex
+)
+
+// RegisterInProcDriver makes a driver available as an in-process driver, usually called from init
+// function of the package implementing the driver.
+func RegisterInProcDriver(name string, factory InProcFactory) {
+	inProcDriversLock.Lock()
+	defer inProcDriversLock.Unlock()
+	if _, ok := inProcDrivers[name]; ok {
+		panic(fmt.Errorf("%w: driver already registered: %s", ErrInProcDriver, name))
+	}
+	inProcDrivers[name] = factory
+}
+
+// GetInProcDriver returns the factory of a registered in-process driver.
+func GetInProcDriver(name string) (InProcFactory, bool) {
+	inProcDriversLock.Lock()
+	defer inProcDriversLock.Unlock()
+	factory, ok := inProcDrivers[name]
+
+	return factory, ok
+}
+
+// MessagePipe passes messages over a go channel without serializing them.  It can be used
+// as the io.Writer in SendMessage, and it is safe to send to a closed pipe.
+type MessagePipe struct {
+	c      chan<- *Message
+	lock   sync.RWMutex
+	once   sync.Once
+	done   chan struct{}
+	closed bool
+}
+
+// NewMessagePipe creates a pipe that delivers the messages to the channel.
+func NewMessagePipe(c chan<- *Message) *MessagePipe {
+	return &MessagePipe{
+		c:    c,
+		done: make(chan struct{}),
+	}
+}
+
+// Write is not supported, the messages have to be sent using Put or SendMessage.
+func (p *MessagePipe) Write(b []byte) (int, error) {
+	return 0, fmt.Errorf("%w: raw bytes can not be written to message pipe", ErrInProcDriver)
+}
+
+// Put delivers a copy of the message to the channel, so the receiver can modify the message
+// the same way as the one fetched from the wire. Returns false if the pipe is closed.
+func (p *MessagePipe) Put(msg *Message) bool {
+	m := *msg
+	m.Reply = nil
+	m.ReturnTo = nil
+	if msg.Labels != nil {
+		m.Labels = make(map[string]string, len(msg.Labels))
+		for k, v := range msg.Labels {
+			m.Labels[k] = v
+		}
+	}
+	if !m.IsRaw && m.Payload != nil {
+		m.Payload = MustDeepCopy(m.Payload)
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.c <- &m:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// Done returns a channel that is closed when the pipe is closed.
+func (p *MessagePipe) Done() <-chan struct{} {
+	return p.done
+}
+
+// Close closes the pipe and the underlying channel.
+func (p *MessagePipe) Close() {
+	p.once.Do(func() {
+		// unblock the pending senders before waiting for the lock
+		close(p.done)
+
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		p.closed = true
+		close(p.c)
+	})
+}
+
+// RunInProc : start a loop to communicate with daemon in the same process over go channels.
+func (d *Driver) RunInProc(in <-chan *Message, out *MessagePipe) {
+	d.inProc = true
+	d.In = nil
+	d.Out = out
+	d.RPCProvider.DefaultReturn = out
+	d.CommandProvider.ReturnWriter = out
+
+	Logger.Infof("[%s] in-process driver %s loaded", d.Service, d.Name)
+	for msg := range in {
+		go d.handle(msg)
+	}
+	Logger.Warningf("[%s] in-process driver %s closed", d.Service, d.Name)
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package dipper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterInProcDriver(t *testing.T) {
+	defer delete(inProcDrivers, "test-register")
+	RegisterInProcDriver("test-register", func(service string) *Driver { return nil })
+	_, ok := GetInProcDriver("test-register")
+	assert.True(t, ok, "registered driver should be found")
+	_, ok = GetInProcDriver("test-missing")
+	assert.False(t, ok, "unregistered driver should not be found")
+
+	assert.Panics(t, func() {
+		RegisterInProcDriver("test-register", func(service string) *Driver { return nil })
+	}, "registering the same driver twice should panic")
+}
+
+func TestMessagePipe(t *testing.T) {
+	c := make(chan *Message, 1)
+	pipe := NewMessagePipe(c)
+
+	payload := map[string]interface{}{"key": []interface{}{"val"}}
+	msg := &Message{Channel: "eventbus", Subject: "command", Labels: map[string]string{"sessionID": "1"}, Payload: payload}
+	SendMessage(pipe, msg)
+	received := <-c
+	assert.Equal(t, msg.Labels, received.Labels)
+	assert.Equal(t, msg.Payload, received.Payload)
+	assert.False(t, received.IsRaw, "payload should be passed without serialization")
+
+	received.Labels["retry"] = "3"
+	received.Payload.(map[string]interface{})["key"].([]interface{})[0] = "changed"
+	assert.Equal(t, map[string]string{"sessionID": "1"}, msg.Labels, "labels should be copied")
+	assert.Equal(t, "val", payload["key"].([]interface{})[0], "payload should be copied")
+
+	pipe.Close()
+	_, ok := <-c
+	assert.False(t, ok, "channel should be closed with the pipe")
+	_, ok = <-pipe.Done()
+	assert.False(t, ok, "done should be signaled when the pipe is closed")
+	assert.False(t, pipe.Put(msg), "put into closed pipe should fail")
+	assert.Panics(t, func() { SendMessage(pipe, msg) }, "sending to closed pipe should panic")
+	assert.NotPanics(t, pipe.Close, "closing pipe twice should be safe")
+}
+
+func TestRunInProc(t *testing.T) {
+	in := make(chan *Message, 1)
+	out := make(chan *Message, 1)
+	d := NewDriver("test", "test-inproc")
+	done := make(chan struct{})
+	go func() {
+		d.RunInProc(in, NewMessagePipe(out))
+		close(done)
+	}()
+
+	in <- &Message{Channel: "command", Subject: "ping"}
+	state := <-out
+	assert.Equal(t, ChannelState, state.Channel, "in-process driver should respond to ping")
+	assert.Equal(t, "loaded", state.Subject)
+
+	close(in)
+	<-done
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+package dipper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// RemoteHelloSubject is the subject of the first message sent by the daemon on a remote driver connection.
+	RemoteHelloSubject = "hello"
+
+	// RemoteListenEnv is the environment variable for the address, tcp://host:port or unix:///path, that a driver
+	// listens on for the daemon to connect, instead of being started by the daemon.
+	RemoteListenEnv = "HONEYDIPPER_DRIVER_LISTEN"
+	// RemoteCAEnv is the environment variable for the CA file used for verifying the daemon certificates.
+	RemoteCAEnv = "HONEYDIPPER_DRIVER_TLS_CA"
+	// RemoteCertEnv is the environment variable for the certificate file of the remote driver.
+	RemoteCertEnv = "HONEYDIPPER_DRIVER_TLS_CERT"
+	// RemoteKeyEnv is the environment variable for the key file of the remote driver.
+	RemoteKeyEnv = "HONEYDIPPER_DRIVER_TLS_KEY"
+
+	// RemoteHelloTimeout is the time limit for the daemon to finish the TLS handshake and send the hello message.
+	RemoteHelloTimeout = 10 * time.Second
+)
+
+// ErrRemoteDriver is the base for all remote driver related errors.
+var ErrRemoteDriver = errors.New("remote driver error")
+
+// RemoteTLSConfig creates a TLS config for mutual TLS between the daemon and a remote driver.
+func RemoteTLSConfig(caFile string, certFile string, keyFile string, isServer bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load key pair: %v", ErrRemoteDriver, err)
+	}
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load CA: %v", ErrRemoteDriver, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("%w: no valid CA cert found in %s", ErrRemoteDriver, caFile)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if isServer {
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// ListenRemote listens on the address in the environment variables for the daemon to connect, mutual TLS is
+// required, and the CA, certificate and key files are also specified in the environment variables.
+func ListenRemote() (net.Listener, error) {
+	network, address := "tcp", os.Getenv(RemoteListenEnv)
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", address[len("unix://"):]
+	case strings.HasPrefix(address, "tcp://"):
+		address = address[len("tcp://"):]
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("%w: unsupported address to listen on: %s", ErrRemoteDriver, address)
+	}
+
+	caFile, certFile, keyFile := os.Getenv(RemoteCAEnv), os.Getenv(RemoteCertEnv), os.Getenv(RemoteKeyEnv)
+	if caFile == "" || certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("%w: mutual TLS is required, %s, %s and %s should be set", ErrRemoteDriver, RemoteCAEnv, RemoteCertEnv, RemoteKeyEnv)
+	}
+	cfg, err := RemoteTLSConfig(caFile, certFile, keyFile, true)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to listen on %s: %v", ErrRemoteDriver, address, err)
+	}
+
+	return tls.NewListener(listener, cfg), nil
+}
+
+// RunRemote serves the connections from the daemon one at a time using the driver, the service of the driver is
+// set from the hello message of each connection.  The daemon keeps retrying while the driver is busy with another
+// connection, so a remote driver should be deployed for each daemon service that uses it.
+func (d *Driver) RunRemote(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			Logger.Warningf("[%s] failed to accept connection: %+v", d.Service, err)
+
+			continue
+		}
+		serveRemoteConn(conn, func(service string) *Driver {
+			d.Service = service
+
+			return d
+		})
+	}
+}
+
+// ServeRemote accepts connections from daemons and runs a driver session for each of them. The newDriver
+// function is called with the service name from the hello message to create the driver for the session.
+func ServeRemote(listener net.Listener, newDriver func(service string) *Driver) {
+	if logFile == nil {
+		// remote drivers are not started by the daemon, there is no log descriptor passed down
+		logFile = os.Stderr
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			Logger.Warningf("[remote] failed to accept connection: %+v", err)
+
+			continue
+		}
+		go serveRemoteConn(conn, newDriver)
+	}
+}
+
+func serveRemoteConn(conn net.Conn, newDriver func(service string) *Driver) {
+	defer conn.Close()
+	defer ForgetComm(conn)
+	defer SafeExitOnError("[remote] closing connection from %s", conn.RemoteAddr())
+	defer CatchError(io.EOF, func() {
+		Logger.Warningf("[remote] daemon closed connection from %s", conn.RemoteAddr())
+	})
+
+	_ = conn.SetReadDeadline(time.Now().Add(RemoteHelloTimeout))
+	hello := FetchRawMessage(conn)
+	_ = conn.SetReadDeadline(time.Time{})
+	if hello.Channel != "command" || hello.Subject != RemoteHelloSubject {
+		panic(fmt.Errorf("%w: expecting hello, got %s:%s", ErrRemoteDriver, hello.Channel, hello.Subject))
+	}
+	service := hello.Labels["service"]
+	Logger.Infof("[remote] accepted connection from %s for service %s", conn.RemoteAddr(), service)
+
+	d := newDriver(service)
+	d.In = conn
+	d.Out = conn
+	d.RPCProvider.DefaultReturn = conn
+	d.CommandProvider.ReturnWriter = conn
+	for {
+		msg := FetchRawMessage(conn)
+		go d.handle(msg)
+	}
+}
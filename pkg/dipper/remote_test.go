@@ -0,0 +1,75 @@
+// Copyright 2023 PayPal Inc.
+
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT License was not distributed with this file,
+// you can obtain one at https://mit-license.org/.
+
+//go:build !integration
+// +build !integration
+
+package dipper
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeRemote(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	services := make(chan string, 1)
+	served := make(chan struct{})
+	go func() {
+		ServeRemote(listener, func(service string) *Driver {
+			services <- service
+
+			return NewDriver(service, "test-remote")
+		})
+		close(served)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	SendMessage(conn, &Message{Channel: "command", Subject: RemoteHelloSubject, Labels: map[string]string{"service": "engine"}})
+	SendMessage(conn, &Message{Channel: "command", Subject: "ping"})
+	assert.Equal(t, "engine", <-services, "driver should be created for the service in hello message")
+
+	assert.Nil(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	state := FetchRawMessage(conn)
+	assert.Equal(t, ChannelState, state.Channel, "remote driver should respond to ping")
+	assert.Equal(t, "loaded", state.Subject)
+
+	listener.Close()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		assert.Fail(t, "ServeRemote should return after listener is closed")
+	}
+}
+
+func TestRemoteTLSConfig(t *testing.T) {
+	_, err := RemoteTLSConfig("no-ca.pem", "no-cert.pem", "no-key.pem", true)
+	assert.ErrorIs(t, err, ErrRemoteDriver, "should fail when the key pair is missing")
+}
+
+func TestListenRemote(t *testing.T) {
+	t.Setenv(RemoteListenEnv, "tcp://127.0.0.1:0")
+	_, err := ListenRemote()
+	assert.ErrorIs(t, err, ErrRemoteDriver, "should require mutual TLS")
+
+	t.Setenv(RemoteCAEnv, "no-ca.pem")
+	t.Setenv(RemoteCertEnv, "no-cert.pem")
+	t.Setenv(RemoteKeyEnv, "no-key.pem")
+	_, err = ListenRemote()
+	assert.ErrorIs(t, err, ErrRemoteDriver, "should fail when the key pair is missing")
+
+	t.Setenv(RemoteListenEnv, "udp://127.0.0.1:0")
+	_, err = ListenRemote()
+	assert.ErrorIs(t, err, ErrRemoteDriver, "should fail with unsupported address")
+}